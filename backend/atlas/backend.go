@@ -77,6 +77,18 @@ func (b *Backend) State(name string) (state.State, error) {
 	return &remote.State{Client: b.stateClient}, nil
 }
 
+// StateLocation implements backend.StateLocator.
+func (b *Backend) StateLocation(name string) (string, error) {
+	if name != backend.DefaultStateName {
+		return "", backend.ErrNamedStatesNotSupported
+	}
+	if b.stateClient == nil {
+		return "", nil
+	}
+
+	return "atlas:" + b.stateClient.User + "/" + b.stateClient.Name, nil
+}
+
 // Colorize returns the Colorize structure that can be used for colorizing
 // output. This is gauranteed to always return a non-nil value and so is useful
 // as a helper to wrap any potentially colored strings.
@@ -52,6 +52,14 @@ type Backend interface {
 	States() ([]string, error)
 }
 
+// StateLocator is an optional interface a Backend can implement to describe
+// where it stores each named state, such as the bucket and key of an S3
+// object. This is only used for diagnostics, so the location must not
+// include credentials or anything else that would be unsafe to log.
+type StateLocator interface {
+	StateLocation(name string) (string, error)
+}
+
 // Enhanced implements additional behavior on top of a normal backend.
 //
 // Enhanced backends allow customizing the behavior of Terraform operations.
@@ -206,6 +206,20 @@ func (b *Local) State(name string) (state.State, error) {
 	return s, nil
 }
 
+// StateLocation implements backend.StateLocator. If the state is stored in
+// another backend, the location is taken from that backend.
+func (b *Local) StateLocation(name string) (string, error) {
+	if b.Backend != nil {
+		if l, ok := b.Backend.(backend.StateLocator); ok {
+			return l.StateLocation(name)
+		}
+		return "", nil
+	}
+
+	statePath, _, _ := b.StatePaths(name)
+	return "local:" + statePath, nil
+}
+
 // Operation implements backend.Enhanced
 //
 // This will initialize an in-memory terraform.Context to perform the
@@ -18,6 +18,7 @@ func TestLocal_impl(t *testing.T) {
 	var _ backend.Enhanced = new(Local)
 	var _ backend.Local = new(Local)
 	var _ backend.CLI = new(Local)
+	var _ backend.StateLocator = new(Local)
 }
 
 func TestLocal_backend(t *testing.T) {
@@ -87,6 +88,27 @@ func TestLocal_StatePaths(t *testing.T) {
 
 }
 
+func TestLocal_StateLocation(t *testing.T) {
+	b := &Local{}
+
+	loc, err := b.StateLocation(backend.DefaultStateName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "local:" + DefaultStateFilename; loc != expected {
+		t.Fatalf("expected %q, got %q", expected, loc)
+	}
+
+	loc, err = b.StateLocation("test_env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "local:" + filepath.Join(DefaultEnvDir, "test_env", DefaultStateFilename)
+	if loc != expected {
+		t.Fatalf("expected %q, got %q", expected, loc)
+	}
+}
+
 func TestLocal_addAndRemoveStates(t *testing.T) {
 	defer testTmpDir(t)()
 	dflt := backend.DefaultStateName
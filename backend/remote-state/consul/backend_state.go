@@ -147,6 +147,15 @@ func (b *Backend) State(name string) (state.State, error) {
 	return stateMgr, nil
 }
 
+// StateLocation implements backend.StateLocator.
+func (b *Backend) StateLocation(name string) (string, error) {
+	if b.configData == nil {
+		return "", nil
+	}
+
+	return "consul:" + b.path(name), nil
+}
+
 func (b *Backend) path(name string) string {
 	path := b.configData.Get("path").(string)
 	if name != backend.DefaultStateName {
@@ -3,6 +3,7 @@ package inmem
 import (
 	"crypto/md5"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/hashicorp/terraform/state"
@@ -15,6 +16,9 @@ type RemoteClient struct {
 	MD5  []byte
 
 	LockInfo *state.LockInfo
+
+	// Snapshots are the named snapshots of Data.
+	Snapshots map[string][]byte
 }
 
 func (c *RemoteClient) Get() (*remote.Payload, error) {
@@ -42,6 +46,24 @@ func (c *RemoteClient) Delete() error {
 	return nil
 }
 
+func (c *RemoteClient) Snapshot(name string) (string, error) {
+	if c.Data == nil {
+		return "", errors.New("no state to snapshot")
+	}
+	if _, ok := c.Snapshots[name]; ok {
+		return "", fmt.Errorf("snapshot %q already exists", name)
+	}
+
+	if c.Snapshots == nil {
+		c.Snapshots = make(map[string][]byte)
+	}
+	data := make([]byte, len(c.Data))
+	copy(data, c.Data)
+	c.Snapshots[name] = data
+
+	return name, nil
+}
+
 func (c *RemoteClient) Lock(info *state.LockInfo) (string, error) {
 	lockErr := &state.LockError{
 		Info: &state.LockInfo{},
@@ -11,6 +11,7 @@ import (
 func TestRemoteClient_impl(t *testing.T) {
 	var _ remote.Client = new(RemoteClient)
 	var _ remote.ClientLocker = new(RemoteClient)
+	var _ remote.ClientSnapshotter = new(RemoteClient)
 }
 
 func TestRemoteClient(t *testing.T) {
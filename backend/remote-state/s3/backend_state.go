@@ -148,6 +148,11 @@ func (b *Backend) State(name string) (state.State, error) {
 	return stateMgr, nil
 }
 
+// StateLocation implements backend.StateLocator.
+func (b *Backend) StateLocation(name string) (string, error) {
+	return "s3:" + b.bucketName + "/" + b.path(name), nil
+}
+
 func (b *Backend) client() *RemoteClient {
 	return &RemoteClient{}
 }
@@ -0,0 +1,232 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugAnimateCommand is a Command implementation that extracts the graphs
+// of a debug archive as the frames of an animation, viewed in a web browser.
+type DebugAnimateCommand struct {
+	Meta
+}
+
+// debugAnimateFrame is a single frame of the animation in index.html.
+type debugAnimateFrame struct {
+	Title string
+
+	// Dot is the name of the frame's dot file, and Image the name of the
+	// image rendered from it, or empty if it wasn't rendered, in which case
+	// Source is shown instead.
+	Dot    string
+	Image  string
+	Source string
+}
+
+func (c *DebugAnimateCommand) Run(args []string) int {
+	var graphviz string
+	args = c.Meta.process(args, true)
+	cmdFlags := c.Meta.flagSet("debug animate")
+	cmdFlags.StringVar(&graphviz, "graphviz", "dot", "graphviz")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 2 {
+		c.Ui.Error("The debug animate command expects exactly two arguments.")
+		return cli.RunResultHelp
+	}
+	dir := args[1]
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	r, err := terraform.NewDebugArchiveReader(f)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading debug archive: %s", err))
+		return 1
+	}
+
+	graphs := r.GraphFrames()
+	if len(graphs) == 0 {
+		c.Ui.Error(strings.TrimSpace(errDebugAnimateNoGraphs))
+		return 1
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating output directory: %s", err))
+		return 1
+	}
+
+	// images are only rendered if graphviz is installed
+	render := true
+	if _, err := exec.LookPath(graphviz); err != nil {
+		render = false
+	}
+
+	frames := make([]*debugAnimateFrame, len(graphs))
+	rendered := 0
+	for i, g := range graphs {
+		base := strings.TrimSuffix(path.Base(g.Name), ".dot")
+		frame := &debugAnimateFrame{
+			Title:  fmt.Sprintf("%d/%d: %s (step %d, %s)", i+1, len(graphs), g.Name, g.Step, g.Phase),
+			Dot:    fmt.Sprintf("%03d-%s.dot", i, base),
+			Source: string(g.Data),
+		}
+		frames[i] = frame
+
+		dotPath := filepath.Join(dir, frame.Dot)
+		if err := ioutil.WriteFile(dotPath, g.Data, 0644); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error writing %s: %s", dotPath, err))
+			return 1
+		}
+
+		if !render {
+			continue
+		}
+
+		image := fmt.Sprintf("%03d-%s.svg", i, base)
+		out, err := exec.Command(graphviz, "-Tsvg", "-o", filepath.Join(dir, image), dotPath).CombinedOutput()
+		if err != nil {
+			c.Ui.Warn(fmt.Sprintf(
+				"Error rendering %s, its dot source is shown instead: %s\n%s",
+				g.Name, err, strings.TrimSpace(string(out))))
+			continue
+		}
+		frame.Image = image
+		frame.Source = ""
+		rendered++
+	}
+
+	var buf bytes.Buffer
+	if err := debugAnimateTemplate.Execute(&buf, frames); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error writing index.html: %s", err))
+		return 1
+	}
+	index := filepath.Join(dir, "index.html")
+	if err := ioutil.WriteFile(index, buf.Bytes(), 0644); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error writing index.html: %s", err))
+		return 1
+	}
+
+	if !render {
+		c.Ui.Output(fmt.Sprintf(
+			"Graphviz %q wasn't found, so the %d graph(s) weren't rendered as images.\n"+
+				"Their dot files are shown in %s instead.",
+			graphviz, len(frames), index))
+		return 0
+	}
+
+	c.Ui.Output(fmt.Sprintf("Rendered %d of %d graph(s), open %s to view them.", rendered, len(frames), index))
+	return 0
+}
+
+func (c *DebugAnimateCommand) Help() string {
+	helpText := `
+Usage: terraform debug animate [options] ARCHIVE DIR
+
+  Extract the graphs written to a debug archive into DIR as the frames of
+  an animation, to see how the graphs change over the course of a run.
+
+  Each dot file in the graphs directory of the archive is written to DIR
+  in the order it was written to the archive, and rendered to an SVG
+  image if Graphviz is installed. An index.html is written to flip through
+  the frames in a web browser, with the arrow keys or its buttons. Without
+  Graphviz, index.html shows the source of each dot file instead.
+
+Options:
+
+  -graphviz=path  The Graphviz dot executable used to render the images.
+                  Defaults to "dot", found in the PATH.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugAnimateCommand) Synopsis() string {
+	return "Extract the graphs of a debug archive as an animation"
+}
+
+const errDebugAnimateNoGraphs = `
+The debug archive has no graphs to animate.
+
+Graphs are written when the debug archive is enabled with the TF_DEBUG
+environment variable, unless the archive's level is "summary" or only the
+events of failed resources are recorded. Run the command being investigated
+with TF_DEBUG set and the default level to record them.
+`
+
+var debugAnimateTemplate = template.Must(template.New("index.html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Terraform graphs</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+.frame { display: none; }
+.frame.current { display: block; }
+.frame img { max-width: 100%; }
+pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+</style>
+</head>
+<body>
+<p>
+<button id="first">&laquo;</button>
+<button id="prev">&lsaquo;</button>
+<button id="play">Play</button>
+<button id="next">&rsaquo;</button>
+<button id="last">&raquo;</button>
+</p>
+{{range $i, $f := .}}<div class="frame{{if eq $i 0}} current{{end}}">
+<h2>{{$f.Title}}</h2>
+<p><a href="{{$f.Dot}}">{{$f.Dot}}</a></p>
+{{if $f.Image}}<img src="{{$f.Image}}" alt="{{$f.Title}}">{{else}}<pre>{{$f.Source}}</pre>{{end}}
+</div>
+{{end}}<script>
+var frames = document.querySelectorAll(".frame");
+var current = 0;
+var timer = null;
+function show(i) {
+  if (i < 0 || i >= frames.length) { return; }
+  frames[current].className = "frame";
+  current = i;
+  frames[current].className = "frame current";
+}
+function play() {
+  if (timer) {
+    clearInterval(timer);
+    timer = null;
+    document.getElementById("play").textContent = "Play";
+    return;
+  }
+  document.getElementById("play").textContent = "Pause";
+  timer = setInterval(function() { show((current + 1) % frames.length); }, 1000);
+}
+document.getElementById("first").onclick = function() { show(0); };
+document.getElementById("prev").onclick = function() { show(current - 1); };
+document.getElementById("play").onclick = play;
+document.getElementById("next").onclick = function() { show(current + 1); };
+document.getElementById("last").onclick = function() { show(frames.length - 1); };
+document.onkeydown = function(e) {
+  if (e.keyCode == 37) { show(current - 1); }
+  if (e.keyCode == 39) { show(current + 1); }
+};
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,163 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func testDebugAnimateArchive(t *testing.T, path string) {
+	testDebugArchive(t, path, []testDebugEntry{
+		{"graphs/2-validate-validate.dot", []byte("digraph { validate }\n")},
+		{"graphs/3-validate-validate.json", []byte("{}")},
+		{"graphs/7-plan-plan.dot", []byte("digraph { plan }\n")},
+		{"8-plan-timings.json", []byte("[]")},
+	})
+}
+
+func TestDebugAnimate_noGraphviz(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugAnimateArchive(t, path)
+	out := filepath.Join(td, "frames")
+
+	ui := new(cli.MockUi)
+	c := &DebugAnimateCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	args := []string{"-graphviz", filepath.Join(td, "missing-dot"), path, out}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	for name, expected := range map[string]string{
+		"000-validate.dot": "digraph { validate }\n",
+		"001-plan.dot":     "digraph { plan }\n",
+	} {
+		actual, err := ioutil.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != expected {
+			t.Fatalf("bad %s: %q", name, actual)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(out, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 2 frames and index.html, got: %v", files)
+	}
+
+	index, err := ioutil.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"1/2: graphs/validate.dot (step 2, validate)",
+		"2/2: graphs/plan.dot (step 7, plan)",
+		"<pre>digraph { plan }",
+	} {
+		if !strings.Contains(string(index), s) {
+			t.Fatalf("index.html doesn't contain %q:\n%s", s, index)
+		}
+	}
+
+	if !strings.Contains(ui.OutputWriter.String(), "weren't rendered") {
+		t.Fatalf("bad output: %s", ui.OutputWriter.String())
+	}
+}
+
+func TestDebugAnimate_graphviz(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake graphviz is a shell script")
+	}
+
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugAnimateArchive(t, path)
+	out := filepath.Join(td, "frames")
+
+	// a stand-in for dot that writes the name of its input to its output
+	graphviz := filepath.Join(td, "dot")
+	script := "#!/bin/sh\necho \"<svg>$4</svg>\" > \"$3\"\n"
+	if err := ioutil.WriteFile(graphviz, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &DebugAnimateCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{"-graphviz", graphviz, path, out}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	for _, name := range []string{"000-validate.svg", "001-plan.svg"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	index, err := ioutil.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `<img src="001-plan.svg"`) || strings.Contains(string(index), "<pre>") {
+		t.Fatalf("bad index.html:\n%s", index)
+	}
+
+	if actual := ui.OutputWriter.String(); !strings.Contains(actual, "Rendered 2 of 2 graph(s)") {
+		t.Fatalf("bad output: %s", actual)
+	}
+}
+
+func TestDebugAnimate_noGraphs(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"8-plan-timings.json", []byte("[]")},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugAnimateCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{path, filepath.Join(td, "frames")}); code != 1 {
+		t.Fatalf("expected failure: \n%s", ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "no graphs to animate") {
+		t.Fatalf("bad error: %s", ui.ErrorWriter.String())
+	}
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// defaultDebugCompactThreshold is the default similarity at which a graph is
+// considered a near duplicate of the graph before it.
+const defaultDebugCompactThreshold = 0.95
+
+// DebugCompactCommand is a Command implementation that shrinks a debug
+// archive by removing graphs that barely differ from the one before them.
+type DebugCompactCommand struct {
+	Meta
+}
+
+func (c *DebugCompactCommand) Run(args []string) int {
+	var threshold float64
+	args = c.Meta.process(args, true)
+	cmdFlags := c.Meta.flagSet("debug compact")
+	cmdFlags.Float64Var(&threshold, "threshold", defaultDebugCompactThreshold, "threshold")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 2 {
+		c.Ui.Error("The debug compact command expects exactly two arguments.")
+		return cli.RunResultHelp
+	}
+
+	if threshold < 0 || threshold > 1 {
+		c.Ui.Error(fmt.Sprintf(
+			"Invalid -threshold value %v: must be between 0 and 1.", threshold))
+		return 1
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(args[1], os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating compacted archive: %s", err))
+		return 1
+	}
+	defer out.Close()
+
+	result, err := terraform.CompactDebugArchive(in, out, threshold)
+	if err != nil {
+		out.Close()
+		os.Remove(args[1])
+		c.Ui.Error(fmt.Sprintf("Error compacting debug archive: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf(
+		"Removed %d of %d graph(s), %d bytes, writing %s.",
+		result.Removed, result.Graphs, result.RemovedBytes, args[1]))
+	return 0
+}
+
+func (c *DebugCompactCommand) Help() string {
+	helpText := `
+Usage: terraform debug compact [options] INPUT OUTPUT
+
+  Write a smaller copy of a debug archive, without the graphs that are
+  nearly identical to an earlier graph of the same name.
+
+  The graphs written while a graph is built and walked often change very
+  little from one to the next. Each graph in the INPUT archive is compared
+  with the last graph of the same name that was kept, and is left out of the
+  OUTPUT archive if it's at least as similar as the threshold. Everything
+  else in the archive is copied unchanged.
+
+  OUTPUT must not already exist.
+
+Options:
+
+  -threshold=0.95     The similarity, between 0 and 1, at which a graph is
+                      removed. Similarity is the proportion of the nodes and
+                      edges of the two graphs that are unchanged. A threshold
+                      of 1 only removes exact duplicates.
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugCompactCommand) Synopsis() string {
+	return "Remove near-duplicate graphs from a debug archive"
+}
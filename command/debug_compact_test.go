@@ -0,0 +1,82 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugCompact(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	inPath := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, inPath, []testDebugEntry{
+		{"graphs/0-plan-plan.dot", []byte("digraph {\n\t\"a\"\n}\n")},
+		{"graphs/1-plan-plan.dot", []byte("digraph {\n\t\"a\"\n}\n")},
+		{"graphs/2-plan-plan.dot", []byte("digraph {\n\t\"b\"\n}\n")},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugCompactCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	outPath := filepath.Join(td, "compact.tar.gz")
+	if code := c.Run([]string{"-threshold=1", inPath, outPath}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "Removed 1 of 3 graph(s)") {
+		t.Fatalf("bad output: %s", output)
+	}
+
+	out, err := os.Open(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	r, err := terraform.NewDebugArchiveReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []int
+	for _, f := range r.Files {
+		if f.Name == "graphs/plan.dot" {
+			steps = append(steps, f.Step)
+		}
+	}
+	if len(steps) != 2 || steps[0] != 0 || steps[1] != 2 {
+		t.Fatalf("bad graph steps: %v", steps)
+	}
+}
+
+func TestDebugCompact_badThreshold(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &DebugCompactCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	if code := c.Run([]string{"-threshold=2", "in.tar.gz", "out.tar.gz"}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "Invalid -threshold") {
+		t.Fatalf("bad error: %s", ui.ErrorWriter.String())
+	}
+}
@@ -0,0 +1,226 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugCompareCommand is a Command implementation that compares the timings
+// recorded in two debug archives.
+type DebugCompareCommand struct {
+	Meta
+}
+
+// debugTimingChange is the change in the total duration of one operation
+// between two archives.
+type debugTimingChange struct {
+	Operation string
+	Address   string
+	Before    time.Duration
+	After     time.Duration
+	Delta     time.Duration
+}
+
+// debugTimingComparison is the result of comparing the timings of two
+// archives. Operations recorded in only one of the archives are listed
+// separately, since they can't be compared.
+type debugTimingComparison struct {
+	Changes    []debugTimingChange
+	OnlyBefore []debugTimingChange
+	OnlyAfter  []debugTimingChange
+}
+
+func (c *DebugCompareCommand) Run(args []string) int {
+	args = c.Meta.process(args, true)
+
+	var jsonOutput bool
+	cmdFlags := c.Meta.flagSet("debug compare")
+	cmdFlags.BoolVar(&jsonOutput, "json", false, "json")
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 2 {
+		c.Ui.Error("The debug compare command expects exactly two arguments.")
+		return cli.RunResultHelp
+	}
+
+	before, err := c.readTimings(args[0])
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	after, err := c.readTimings(args[1])
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	result := compareDebugTimings(before, after)
+
+	if jsonOutput {
+		js, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error encoding comparison: %s", err))
+			return 1
+		}
+		c.Ui.Output(string(js))
+		return 0
+	}
+
+	c.Ui.Output(formatDebugTimingComparison(result))
+	return 0
+}
+
+// readTimings reads the timings recorded in the debug archive at path.
+func (c *DebugCompareCommand) readTimings(path string) ([]terraform.DebugTiming, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening debug archive: %s", err)
+	}
+	defer f.Close()
+
+	r, err := terraform.NewDebugArchiveReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading debug archive %s: %s", path, err)
+	}
+
+	timings, err := r.Timings()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading timings from %s: %s", path, err)
+	}
+	if len(timings) == 0 {
+		return nil, fmt.Errorf("No timings were recorded in %s.", path)
+	}
+
+	return timings, nil
+}
+
+// compareDebugTimings aligns the timings of two runs by operation and
+// address. An operation repeated within a run, such as a refresh in both
+// the plan and apply phases, is compared by its total duration. The changes
+// are sorted by the biggest regression first.
+func compareDebugTimings(before, after []terraform.DebugTiming) *debugTimingComparison {
+	total := func(timings []terraform.DebugTiming) map[string]*debugTimingChange {
+		result := make(map[string]*debugTimingChange)
+		for _, t := range timings {
+			key := t.Operation + " " + t.Address
+			if result[key] == nil {
+				result[key] = &debugTimingChange{
+					Operation: t.Operation,
+					Address:   t.Address,
+				}
+			}
+			result[key].Before += t.Duration
+		}
+		return result
+	}
+
+	b := total(before)
+	a := total(after)
+
+	result := &debugTimingComparison{}
+	for key, change := range b {
+		other, ok := a[key]
+		if !ok {
+			result.OnlyBefore = append(result.OnlyBefore, *change)
+			continue
+		}
+
+		change.After = other.Before
+		change.Delta = change.After - change.Before
+		result.Changes = append(result.Changes, *change)
+	}
+	for key, change := range a {
+		if _, ok := b[key]; !ok {
+			change.After = change.Before
+			change.Before = 0
+			result.OnlyAfter = append(result.OnlyAfter, *change)
+		}
+	}
+
+	sort.Slice(result.Changes, func(i, j int) bool {
+		ci, cj := result.Changes[i], result.Changes[j]
+		if ci.Delta != cj.Delta {
+			return ci.Delta > cj.Delta
+		}
+		return ci.Operation+" "+ci.Address < cj.Operation+" "+cj.Address
+	})
+	byName := func(s []debugTimingChange) {
+		sort.Slice(s, func(i, j int) bool {
+			return s[i].Operation+" "+s[i].Address < s[j].Operation+" "+s[j].Address
+		})
+	}
+	byName(result.OnlyBefore)
+	byName(result.OnlyAfter)
+
+	return result
+}
+
+func formatDebugTimingComparison(result *debugTimingComparison) string {
+	round := func(d time.Duration) time.Duration {
+		return d - d%time.Millisecond
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("Timing changes, largest regression first:\n\n")
+	for _, c := range result.Changes {
+		sign := "+"
+		if c.Delta < 0 {
+			sign = ""
+		}
+		buf.WriteString(fmt.Sprintf(
+			"  %s%s  %s %s (%s -> %s)\n",
+			sign, round(c.Delta), c.Operation, c.Address,
+			round(c.Before), round(c.After)))
+	}
+
+	if len(result.OnlyBefore) > 0 {
+		buf.WriteString("\nOnly in the first archive:\n\n")
+		for _, c := range result.OnlyBefore {
+			buf.WriteString(fmt.Sprintf(
+				"  %s %s (%s)\n", c.Operation, c.Address, round(c.Before)))
+		}
+	}
+
+	if len(result.OnlyAfter) > 0 {
+		buf.WriteString("\nOnly in the second archive:\n\n")
+		for _, c := range result.OnlyAfter {
+			buf.WriteString(fmt.Sprintf(
+				"  %s %s (%s)\n", c.Operation, c.Address, round(c.After)))
+		}
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+func (c *DebugCompareCommand) Help() string {
+	helpText := `
+Usage: terraform debug compare [options] BEFORE AFTER
+
+  Compare the timings recorded in two debug archives.
+
+  The timing of each resource operation and phase in the BEFORE archive is
+  matched by address to the same operation in the AFTER archive, and the
+  differences are listed with the biggest regression first. Operations that
+  were only recorded in one of the archives are listed separately.
+
+Options:
+
+  -json    Output the comparison as JSON. Durations are in nanoseconds.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugCompareCommand) Synopsis() string {
+	return "Compare the timings of two debug archives"
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugCompare(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	testDebugCompareArchive(t, filepath.Join(td, "before.tar.gz"), []terraform.DebugTiming{
+		{Operation: "apply", Address: "aws_instance.foo", Duration: time.Second},
+		{Operation: "apply", Address: "aws_instance.bar", Duration: 2 * time.Second},
+		{Operation: "apply", Address: "aws_instance.old", Duration: time.Second},
+	})
+	testDebugCompareArchive(t, filepath.Join(td, "after.tar.gz"), []terraform.DebugTiming{
+		{Operation: "apply", Address: "aws_instance.foo", Duration: 3 * time.Second},
+		{Operation: "apply", Address: "aws_instance.bar", Duration: time.Second},
+		{Operation: "apply", Address: "aws_instance.new", Duration: time.Second},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugCompareCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-json",
+		filepath.Join(td, "before.tar.gz"),
+		filepath.Join(td, "after.tar.gz"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	var actual debugTimingComparison
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &actual); err != nil {
+		t.Fatalf("bad output: %s\n%s", err, ui.OutputWriter.String())
+	}
+
+	expected := debugTimingComparison{
+		Changes: []debugTimingChange{
+			{
+				Operation: "apply",
+				Address:   "aws_instance.foo",
+				Before:    time.Second,
+				After:     3 * time.Second,
+				Delta:     2 * time.Second,
+			},
+			{
+				Operation: "apply",
+				Address:   "aws_instance.bar",
+				Before:    2 * time.Second,
+				After:     time.Second,
+				Delta:     -time.Second,
+			},
+		},
+		OnlyBefore: []debugTimingChange{
+			{Operation: "apply", Address: "aws_instance.old", Before: time.Second},
+		},
+		OnlyAfter: []debugTimingChange{
+			{Operation: "apply", Address: "aws_instance.new", After: time.Second},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected:\n%#v\n\ngot:\n%#v", expected, actual)
+	}
+
+	// the human readable output leads with the biggest regression
+	ui = new(cli.MockUi)
+	c = &DebugCompareCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run(args[1:]); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+	lines := strings.Split(ui.OutputWriter.String(), "\n")
+	if len(lines) < 3 || !strings.Contains(lines[2], "+2s  apply aws_instance.foo") {
+		t.Fatalf("bad output:\n%s", ui.OutputWriter.String())
+	}
+}
+
+func TestDebugCompare_noTimings(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "empty.tar.gz")
+	testDebugCompareArchive(t, path, nil)
+
+	ui := new(cli.MockUi)
+	c := &DebugCompareCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	if code := c.Run([]string{path, path}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "No timings") {
+		t.Fatalf("bad error: %s", ui.ErrorWriter.String())
+	}
+}
+
+// testDebugCompareArchive writes a debug archive containing the given
+// timings to path.
+func testDebugCompareArchive(t *testing.T, path string, timings []terraform.DebugTiming) {
+	var entries []testDebugEntry
+	if len(timings) > 0 {
+		js, err := json.Marshal(timings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, testDebugEntry{"timings.json", js})
+	}
+
+	testDebugArchive(t, path, entries)
+}
+
+// testDebugEntry is an entry of an archive written by testDebugArchive. The
+// name is relative to the archive root, including any step and phase prefix.
+type testDebugEntry struct {
+	Name string
+	Data []byte
+}
+
+// testDebugArchive writes a debug archive with the given entries to path,
+// after the format-version entry.
+func testDebugArchive(t *testing.T, path string, entries []testDebugEntry) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	entries = append([]testDebugEntry{{"format-version", []byte("2\n")}}, entries...)
+	for _, e := range entries {
+		tw.WriteHeader(&tar.Header{Name: "debug-test/" + e.Name, Mode: 0644, Size: int64(len(e.Data))})
+		tw.Write(e.Data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
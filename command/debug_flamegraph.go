@@ -0,0 +1,91 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugFlamegraphCommand is a Command implementation that converts the
+// timings in a debug archive to the collapsed stack format of flamegraph.pl.
+type DebugFlamegraphCommand struct {
+	Meta
+}
+
+func (c *DebugFlamegraphCommand) Run(args []string) int {
+	args = c.Meta.process(args, true)
+
+	cmdFlags := c.Meta.flagSet("debug flamegraph")
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 1 {
+		c.Ui.Error("The debug flamegraph command expects exactly one argument.")
+		return cli.RunResultHelp
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	r, err := terraform.NewDebugArchiveReader(f)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading debug archive: %s", err))
+		return 1
+	}
+
+	var buf bytes.Buffer
+	ok, err := r.WriteCollapsedStacks(&buf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading timings: %s", err))
+		return 1
+	}
+	if !ok {
+		c.Ui.Error(strings.TrimSpace(errDebugFlamegraphNoTimings))
+		return 1
+	}
+
+	c.Ui.Output(strings.TrimSuffix(buf.String(), "\n"))
+	return 0
+}
+
+func (c *DebugFlamegraphCommand) Help() string {
+	helpText := `
+Usage: terraform debug flamegraph ARCHIVE
+
+  Output the operation timings recorded in a debug archive in the collapsed
+  stack format read by flamegraph.pl, for example:
+
+      terraform debug flamegraph debug.tar.gz | flamegraph.pl > apply.svg
+
+  Each stack is the phase, the modules of the resource, the resource, and
+  the operation, i.e. "apply;module.child;aws_instance.web;apply", followed
+  by the total time spent on it in microseconds. Operations run
+  concurrently, so the graph shows the time spent on each resource rather
+  than the wall clock time of the run.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugFlamegraphCommand) Synopsis() string {
+	return "Convert debug archive timings for flamegraph.pl"
+}
+
+const errDebugFlamegraphNoTimings = `
+The debug archive has no timings to convert.
+
+Timings are recorded when resources are refreshed, diffed, applied or
+provisioned while the debug archive is enabled with the TF_DEBUG environment
+variable. Archives from commands that don't perform any of these operations,
+and from versions of Terraform that didn't record timings, have none. Run the
+plan or apply being investigated with TF_DEBUG set to record them.
+`
@@ -0,0 +1,77 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugFlamegraph(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"9-apply-timings.json", []byte(`[
+  {"Operation": "phase", "Address": "apply", "Phase": "apply", "Duration": 9000000000},
+  {"Operation": "refresh", "Address": "aws_instance.foo", "Phase": "refresh", "Duration": 1500000},
+  {"Operation": "apply", "Address": "module.child.aws_instance.bar.0", "Phase": "apply", "Duration": 2000000000},
+  {"Operation": "provision", "Address": "module.child.aws_instance.bar.0", "Phase": "apply", "Duration": 250000},
+  {"Operation": "refresh", "Address": "aws_instance.foo", "Phase": "refresh", "Duration": 500000}
+]`)},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugFlamegraphCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{path}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	expected := strings.Join([]string{
+		"apply;module.child;aws_instance.bar.0;apply 2000000",
+		"apply;module.child;aws_instance.bar.0;provision 250",
+		"refresh;aws_instance.foo;refresh 2000",
+	}, "\n") + "\n"
+	if actual := ui.OutputWriter.String(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestDebugFlamegraph_noTimings(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"graphs/0-plan-plan.dot", []byte("digraph {}")},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugFlamegraphCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{path}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "TF_DEBUG") {
+		t.Fatalf("bad error:\n%s", ui.ErrorWriter.String())
+	}
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugGraphsCommand is a Command implementation that extracts the dot files
+// of the graphs in a debug archive.
+type DebugGraphsCommand struct {
+	Meta
+}
+
+func (c *DebugGraphsCommand) Run(args []string) int {
+	args = c.Meta.process(args, true)
+
+	cmdFlags := c.Meta.flagSet("debug graphs")
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 2 {
+		c.Ui.Error("The debug graphs command expects exactly two arguments.")
+		return cli.RunResultHelp
+	}
+	dir := args[1]
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	r, err := terraform.NewDebugArchiveReader(f)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading debug archive: %s", err))
+		return 1
+	}
+
+	graphs := r.GraphFrames()
+	if len(graphs) == 0 {
+		c.Ui.Output("No graphs found in the debug archive.")
+		return 0
+	}
+
+	for _, g := range graphs {
+		p := filepath.Join(dir, filepath.FromSlash(debugGraphsName(g)))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error creating output directory: %s", err))
+			return 1
+		}
+		if err := ioutil.WriteFile(p, g.Data, 0644); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error writing %s: %s", p, err))
+			return 1
+		}
+	}
+
+	c.Ui.Output(fmt.Sprintf("Extracted %d graph(s) to %s.", len(graphs), dir))
+	return 0
+}
+
+// debugGraphsName returns the name a graph is extracted as, relative to the
+// output directory: its name within the graphs directory of the archive, with
+// the step and phase it was written at, as they're named in the archive,
+// i.e. "7-plan-plan.dot" for "graphs/plan.dot".
+func debugGraphsName(g *terraform.DebugArchiveFile) string {
+	name := strings.TrimPrefix(g.Name, "graphs/")
+	dir, base := path.Split(name)
+	if g.Phase == "" {
+		return fmt.Sprintf("%s%d-%s", dir, g.Step, base)
+	}
+	return fmt.Sprintf("%s%d-%s-%s", dir, g.Step, g.Phase, base)
+}
+
+func (c *DebugGraphsCommand) Help() string {
+	helpText := `
+Usage: terraform debug graphs ARCHIVE DIR
+
+  Extract the dot files of the graphs written to a debug archive into DIR,
+  for working with the diagrams without unpacking the whole archive.
+
+  Each graph is written with the step and phase it was written at, as
+  it's named in the archive, i.e. "7-plan-plan.dot", so the files sort
+  in the order the graphs were built. An archive without graphs isn't an
+  error: nothing is written.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugGraphsCommand) Synopsis() string {
+	return "Extract the graphs of a debug archive"
+}
@@ -0,0 +1,89 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugGraphs(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"graphs/2-validate-validate.dot", []byte("digraph { validate }\n")},
+		{"graphs/3-validate-validate.json", []byte("{}")},
+		{"graphs/7-plan-plan.dot", []byte("digraph { plan }\n")},
+		{"graphs/split/9-apply-apply-1.dot", []byte("digraph { apply }\n")},
+		{"8-plan-timings.json", []byte("[]")},
+	})
+	out := filepath.Join(td, "graphs")
+
+	ui := new(cli.MockUi)
+	c := &DebugGraphsCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{path, out}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+	if msg := ui.OutputWriter.String(); !strings.Contains(msg, "Extracted 3 graph(s)") {
+		t.Fatalf("bad output: %s", msg)
+	}
+
+	expected := map[string]string{
+		"2-validate-validate.dot":   "digraph { validate }\n",
+		"7-plan-plan.dot":           "digraph { plan }\n",
+		"split/9-apply-apply-1.dot": "digraph { apply }\n",
+	}
+	for name, data := range expected {
+		actual, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != data {
+			t.Fatalf("bad %s: %q", name, actual)
+		}
+	}
+}
+
+func TestDebugGraphs_none(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"8-plan-timings.json", []byte("[]")},
+	})
+	out := filepath.Join(td, "graphs")
+
+	ui := new(cli.MockUi)
+	c := &DebugGraphsCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{path, out}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+	if msg := ui.OutputWriter.String(); !strings.Contains(msg, "No graphs found") {
+		t.Fatalf("bad output: %s", msg)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be written, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugGrepCommand is a Command implementation that searches the entries of
+// a debug archive for a regular expression.
+type DebugGrepCommand struct {
+	Meta
+}
+
+func (c *DebugGrepCommand) Run(args []string) int {
+	args = c.Meta.process(args, true)
+
+	var all bool
+	var context int
+	cmdFlags := c.Meta.flagSet("debug grep")
+	cmdFlags.BoolVar(&all, "all", false, "all")
+	cmdFlags.IntVar(&context, "context", 2, "lines")
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 2 {
+		c.Ui.Error("The debug grep command expects exactly two arguments.")
+		return cli.RunResultHelp
+	}
+	if context < 0 {
+		c.Ui.Error("The -context value must not be negative.")
+		return 1
+	}
+
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error parsing pattern: %s", err))
+		return 1
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	matches := 0
+	err = terraform.WalkDebugArchive(f, func(entry *terraform.DebugArchiveFile) error {
+		if !all && !debugGrepText(entry) {
+			return nil
+		}
+
+		out := debugGrepEntry(entry, re, context)
+		if out != "" {
+			if matches > 0 {
+				c.Ui.Output("")
+			}
+			c.Ui.Output(out)
+			matches++
+		}
+		return nil
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading debug archive: %s", err))
+		return 1
+	}
+
+	if matches == 0 {
+		c.Ui.Output("No matching entries found.")
+	}
+
+	return 0
+}
+
+// debugGrepText returns true if the entry should be searched by default.
+// Graphs are skipped since they repeat every resource address many times,
+// and anything that isn't valid UTF-8 text is skipped as binary.
+func debugGrepText(f *terraform.DebugArchiveFile) bool {
+	if strings.HasPrefix(f.Name, "graphs/") || strings.HasSuffix(f.Name, ".dot") {
+		return false
+	}
+
+	return utf8.Valid(f.Data) && bytes.IndexByte(f.Data, 0) < 0
+}
+
+// debugGrepEntry returns the lines of the entry matching re, with the given
+// number of lines of context around each, or an empty string if nothing in
+// the entry matches. Matching lines are marked with ">".
+func debugGrepEntry(f *terraform.DebugArchiveFile, re *regexp.Regexp, context int) string {
+	lines := strings.Split(strings.TrimSuffix(string(f.Data), "\n"), "\n")
+
+	var matched []int
+	for i, line := range lines {
+		if re.MatchString(line) {
+			matched = append(matched, i)
+		}
+	}
+	if len(matched) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if f.Step >= 0 {
+		buf.WriteString(fmt.Sprintf("%s (step %d, %s)\n", f.Name, f.Step, f.Phase))
+	} else {
+		buf.WriteString(f.Name + "\n")
+	}
+
+	width := len(fmt.Sprint(len(lines)))
+	last := -1
+	for _, m := range matched {
+		start := m - context
+		if start <= last+1 && last >= 0 {
+			start = last + 1
+		} else if last >= 0 {
+			buf.WriteString("  --\n")
+		}
+		if start < 0 {
+			start = 0
+		}
+
+		end := m + context
+		if end >= len(lines) {
+			end = len(lines) - 1
+		}
+
+		for i := start; i <= end; i++ {
+			// later matches within this context are marked when reached
+			if i > m && re.MatchString(lines[i]) {
+				break
+			}
+
+			marker := " "
+			if i == m {
+				marker = ">"
+			}
+			buf.WriteString(fmt.Sprintf("%s %*d: %s\n", marker, width, i+1, lines[i]))
+			last = i
+		}
+	}
+
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (c *DebugGrepCommand) Help() string {
+	helpText := `
+Usage: terraform debug grep [options] PATTERN ARCHIVE
+
+  Search the entries of a debug archive for a regular expression.
+
+  The name of each entry containing a line that matches PATTERN is output,
+  followed by the matching lines and the lines around them. PATTERN uses
+  Go regular expression syntax; prefix it with "(?i)" to ignore case.
+
+  The archive is read one entry at a time, so large archives can be
+  searched without extracting them. Graphs and binary entries are skipped
+  unless -all is given.
+
+Options:
+
+  -all           Also search graphs and binary entries.
+
+  -context=n     The number of lines to show before and after each
+                 matching line. Defaults to 2.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugGrepCommand) Synopsis() string {
+	return "Search a debug archive for a pattern"
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugGrep(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"graphs/0-plan-plan.dot", []byte(`"aws_instance.foo"`)},
+		{"1-apply-hook-PreApply", []byte("one\ntwo\naws_instance.foo\nthree\nfour\nfive\nsix\naws_instance.foo\n")},
+		{"2-apply-hook-PostApply", []byte("aws_instance.bar\n")},
+		{"3-apply-binary", []byte("aws_instance.foo\x00")},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugGrepCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-context", "1", `instance\.foo`, path}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	expected := strings.TrimSpace(`
+hook-PreApply (step 1, apply)
+  2: two
+> 3: aws_instance.foo
+  4: three
+  --
+  7: six
+> 8: aws_instance.foo
+`)
+	actual := strings.TrimSpace(ui.OutputWriter.String())
+	if actual != expected {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", expected, actual)
+	}
+
+	// graphs and binary entries are only searched with -all
+	ui = new(cli.MockUi)
+	c = &DebugGrepCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	args = []string{"-all", "-context", "0", `instance\.foo`, path}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+	output := ui.OutputWriter.String()
+	for _, name := range []string{"graphs/plan.dot", "hook-PreApply", "binary"} {
+		if !strings.Contains(output, name+" (step") {
+			t.Fatalf("expected %s in output:\n%s", name, output)
+		}
+	}
+	if strings.Contains(output, "hook-PostApply") {
+		t.Fatalf("unexpected match:\n%s", output)
+	}
+}
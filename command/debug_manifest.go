@@ -0,0 +1,87 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugManifestCommand is a Command implementation that lists the files in
+// a debug archive.
+type DebugManifestCommand struct {
+	Meta
+}
+
+func (c *DebugManifestCommand) Run(args []string) int {
+	args = c.Meta.process(args, true)
+
+	var csvOutput bool
+	cmdFlags := c.Meta.flagSet("debug manifest")
+	cmdFlags.BoolVar(&csvOutput, "csv", false, "csv")
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 1 {
+		c.Ui.Error("The debug manifest command expects exactly one argument.")
+		return cli.RunResultHelp
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	r, err := terraform.NewDebugArchiveReader(f)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading debug archive: %s", err))
+		return 1
+	}
+
+	if csvOutput {
+		var buf bytes.Buffer
+		if err := r.WriteManifestCSV(&buf); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error encoding manifest: %s", err))
+			return 1
+		}
+		c.Ui.Output(strings.TrimSuffix(buf.String(), "\n"))
+		return 0
+	}
+
+	js, err := json.MarshalIndent(r.Manifest(), "", "  ")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error encoding manifest: %s", err))
+		return 1
+	}
+	c.Ui.Output(string(js))
+	return 0
+}
+
+func (c *DebugManifestCommand) Help() string {
+	helpText := `
+Usage: terraform debug manifest [options] ARCHIVE
+
+  List the files in a debug archive as JSON, with the step and phase each
+  was written in, its size, SHA-256 checksum and modification time, and the
+  correlation ID of the run that wrote it.
+
+Options:
+
+  -csv    Output the manifest as CSV, with a header row. Values that aren't
+          known, such as the modification times in archives written by
+          older versions of Terraform, are left empty.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugManifestCommand) Synopsis() string {
+	return "List the files in a debug archive"
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugManifest(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"graphs/0-plan-plan.dot", []byte("digraph {}")},
+		{"1-apply-hook-PostApply", []byte("aws_instance.foo")},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugManifestCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{path}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	var entries []terraform.DebugManifestEntry
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &entries); err != nil {
+		t.Fatalf("bad output: %s\n%s", err, ui.OutputWriter.String())
+	}
+	if len(entries) != 3 {
+		t.Fatalf("bad: %#v", entries)
+	}
+	if e := entries[2]; e.Name != "hook-PostApply" || e.Step != 1 || e.Phase != "apply" || e.Size != 16 {
+		t.Fatalf("bad entry: %#v", e)
+	}
+}
+
+func TestDebugManifest_csv(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"graphs/0-plan-plan.dot", []byte("digraph {}")},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugManifestCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{"-csv", path}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(ui.OutputWriter.String()), "\n")
+	if len(lines) != 3 || lines[0] != "name,step,phase,size,checksum,modtime,correlation_id" {
+		t.Fatalf("bad output: %q", lines)
+	}
+	if !strings.HasPrefix(lines[2], "graphs/plan.dot,0,plan,10,") || !strings.HasSuffix(lines[2], ",") {
+		t.Fatalf("bad entry: %q", lines[2])
+	}
+}
@@ -0,0 +1,88 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugRecoverCommand is a Command implementation that rebuilds a complete
+// debug archive from one that wasn't closed, such as after a crash.
+type DebugRecoverCommand struct {
+	Meta
+}
+
+func (c *DebugRecoverCommand) Run(args []string) int {
+	args = c.Meta.process(args, true)
+	cmdFlags := c.Meta.flagSet("debug recover")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 2 {
+		c.Ui.Error("The debug recover command expects exactly two arguments.")
+		return cli.RunResultHelp
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer in.Close()
+
+	// never overwrite an existing file, which may be the only copy of the
+	// data being recovered
+	out, err := os.OpenFile(args[1], os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating recovered archive: %s", err))
+		return 1
+	}
+	defer out.Close()
+
+	result, err := terraform.RecoverDebugArchive(in, out)
+	if err != nil {
+		out.Close()
+		os.Remove(args[1])
+		c.Ui.Error(fmt.Sprintf("Error recovering debug archive: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf(
+		"Recovered %d file(s), %d bytes, to %s.",
+		result.Entries, result.Bytes, args[1]))
+	if result.Err != nil {
+		c.Ui.Output(fmt.Sprintf(
+			"The rest of the archive could not be read: %s", result.Err))
+	} else {
+		c.Ui.Output("The entire archive was read.")
+	}
+
+	return 0
+}
+
+func (c *DebugRecoverCommand) Help() string {
+	helpText := `
+Usage: terraform debug recover INPUT OUTPUT
+
+  Rebuild a complete debug archive from one that was never closed.
+
+  If Terraform exits without closing the debug archive, for example after a
+  crash, the archive is left without a proper ending and some tools can't
+  read it. This command reads every intact file from the INPUT archive and
+  writes them to a new, complete archive at OUTPUT. Reading stops at the
+  first file that can't be read in full.
+
+  OUTPUT must not already exist.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugRecoverCommand) Synopsis() string {
+	return "Rebuild a debug archive that was never closed"
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugRecover(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	// write an archive that was never closed
+	in, err := os.Create(filepath.Join(td, "crashed.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(in)
+	tw := tar.NewWriter(gz)
+	version := []byte("2\n")
+	tw.WriteHeader(&tar.Header{Name: "debug-test/format-version", Mode: 0644, Size: int64(len(version))})
+	tw.Write(version)
+	data := []byte("digraph {}")
+	tw.WriteHeader(&tar.Header{Name: "debug-test/graphs/0-plan-plan.dot", Mode: 0644, Size: int64(len(data))})
+	tw.Write(data)
+	tw.Flush()
+	gz.Flush()
+	in.Close()
+
+	ui := new(cli.MockUi)
+	c := &DebugRecoverCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	outPath := filepath.Join(td, "recovered.tar.gz")
+	args := []string{filepath.Join(td, "crashed.tar.gz"), outPath}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "Recovered 1 file(s)") {
+		t.Fatalf("bad output: %s", output)
+	}
+
+	out, err := os.Open(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	r, err := terraform.NewDebugArchiveReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := r.File("graphs/plan.dot"); f == nil || string(f.Data) != string(data) {
+		t.Fatalf("missing recovered file: %#v", r.Files)
+	}
+
+	// the output is never overwritten
+	ui = new(cli.MockUi)
+	c.Meta.Ui = ui
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if _, err := ioutil.ReadFile(outPath); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,179 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugStateDiffCommand is a Command implementation that compares the state
+// captured in a debug archive with the current state of the backend.
+type DebugStateDiffCommand struct {
+	Meta
+	StateMeta
+}
+
+func (c *DebugStateDiffCommand) Run(args []string) int {
+	args = c.Meta.process(args, true)
+
+	cmdFlags := c.Meta.flagSet("debug state-diff")
+	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 1 {
+		c.Ui.Error("The debug state-diff command expects exactly one argument.")
+		return cli.RunResultHelp
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	r, err := terraform.NewDebugArchiveReader(f)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading debug archive: %s", err))
+		return 1
+	}
+
+	captured, err := r.State()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the state in the debug archive: %s", err))
+		return 1
+	}
+	if captured == nil {
+		c.Ui.Error(strings.TrimSpace(errDebugStateDiffNoState))
+		return 1
+	}
+
+	stateMgr, err := c.StateMeta.State(&c.Meta)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
+		return 1
+	}
+	if err := stateMgr.RefreshState(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to load state: %s", err))
+		return 1
+	}
+	current := stateMgr.State()
+	if current == nil {
+		current = terraform.NewState()
+	}
+
+	added, removed, changed := debugStateDiff(captured, current)
+	if len(added)+len(removed)+len(changed) == 0 {
+		c.Ui.Output(fmt.Sprintf(
+			"No resources changed since the debug archive was captured (serial %d).",
+			captured.Serial))
+		return 0
+	}
+
+	var lines []string
+	for _, addr := range added {
+		lines = append(lines, "+ "+addr)
+	}
+	for _, addr := range removed {
+		lines = append(lines, "- "+addr)
+	}
+	for _, addr := range changed {
+		lines = append(lines, "~ "+addr)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i][2:] < lines[j][2:]
+	})
+
+	c.Ui.Output(fmt.Sprintf(
+		"Resources changed since the debug archive was captured (serial %d, now %d):\n\n  %s\n\n"+
+			"%d added, %d removed, %d changed.",
+		captured.Serial, current.Serial, strings.Join(lines, "\n  "),
+		len(added), len(removed), len(changed)))
+	return 0
+}
+
+// debugStateDiff returns the sorted addresses of the resources that are only
+// in current, only in captured, and in both but differ.
+func debugStateDiff(captured, current *terraform.State) ([]string, []string, []string) {
+	before := debugStateResources(captured)
+	after := debugStateResources(current)
+
+	var added, removed, changed []string
+	for addr, r := range after {
+		old, ok := before[addr]
+		switch {
+		case !ok:
+			added = append(added, addr)
+		case !old.Equal(r):
+			changed = append(changed, addr)
+		}
+	}
+	for addr := range before {
+		if _, ok := after[addr]; !ok {
+			removed = append(removed, addr)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+// debugStateResources returns the resources of s by address.
+func debugStateResources(s *terraform.State) map[string]*terraform.ResourceState {
+	result := make(map[string]*terraform.ResourceState)
+
+	filter := &terraform.StateFilter{State: s}
+	results, err := filter.Filter()
+	if err != nil {
+		return result
+	}
+	for _, r := range results {
+		if rs, ok := r.Value.(*terraform.ResourceState); ok {
+			result[r.Address] = rs
+		}
+	}
+
+	return result
+}
+
+func (c *DebugStateDiffCommand) Help() string {
+	helpText := `
+Usage: terraform debug state-diff [options] ARCHIVE
+
+  Compare the state captured in a debug archive with the current state,
+  listing the resources that were added (+), removed (-) or changed (~)
+  since the archive was captured.
+
+  The state is only captured in the archive if TF_DEBUG_STATE was set
+  when it was written. The current state is read from the configured
+  backend, as with the other state commands.
+
+Options:
+
+  -state=statefile    Path to a Terraform state file to compare with, if
+                      the local backend is used. Defaults to
+                      "terraform.tfstate".
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugStateDiffCommand) Synopsis() string {
+	return "Compare the state in a debug archive with the current state"
+}
+
+const errDebugStateDiffNoState = `
+The debug archive doesn't contain a snapshot of the state.
+
+The state is only written to the debug archive if the TF_DEBUG_STATE
+environment variable is set. Run the command being investigated with
+TF_DEBUG and TF_DEBUG_STATE set to capture it.
+`
@@ -0,0 +1,110 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugStateDiff(t *testing.T) {
+	td := tempDir(t)
+	os.MkdirAll(td, 0755)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	resource := func(id string) *terraform.ResourceState {
+		return &terraform.ResourceState{
+			Type:    "test_instance",
+			Primary: &terraform.InstanceState{ID: id},
+		}
+	}
+	captured := &terraform.State{
+		Version: terraform.StateVersion,
+		Serial:  3,
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.kept":    resource("kept"),
+					"test_instance.changed": resource("old"),
+					"test_instance.removed": resource("removed"),
+				},
+			},
+		},
+	}
+	current := captured.DeepCopy()
+	current.Serial = 5
+	mod := current.RootModule()
+	mod.Resources["test_instance.changed"] = resource("new")
+	mod.Resources["test_instance.added"] = resource("added")
+	delete(mod.Resources, "test_instance.removed")
+
+	var buf bytes.Buffer
+	if err := terraform.WriteState(captured, &buf); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, archive, []testDebugEntry{
+		{"8-apply-timings.json", []byte("[]")},
+		{"9-apply-state.tfstate", buf.Bytes()},
+	})
+
+	statePath := testStateFile(t, current)
+
+	ui := new(cli.MockUi)
+	c := &DebugStateDiffCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{"-state", statePath, archive}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := strings.TrimSpace(`
+Resources changed since the debug archive was captured (serial 3, now 5):
+
+  + test_instance.added
+  ~ test_instance.changed
+  - test_instance.removed
+
+1 added, 1 removed, 1 changed.
+`)
+	if actual := strings.TrimSpace(ui.OutputWriter.String()); actual != expected {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestDebugStateDiff_noState(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	archive := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, archive, []testDebugEntry{
+		{"8-apply-timings.json", []byte("[]")},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugStateDiffCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{archive}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "TF_DEBUG_STATE") {
+		t.Fatalf("bad error:\n%s", ui.ErrorWriter.String())
+	}
+}
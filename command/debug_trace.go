@@ -0,0 +1,92 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// DebugTraceCommand is a Command implementation that converts the timings in
+// a debug archive to the Chrome Trace Event Format.
+type DebugTraceCommand struct {
+	Meta
+}
+
+func (c *DebugTraceCommand) Run(args []string) int {
+	args = c.Meta.process(args, true)
+
+	cmdFlags := c.Meta.flagSet("debug trace")
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 1 {
+		c.Ui.Error("The debug trace command expects exactly one argument.")
+		return cli.RunResultHelp
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening debug archive: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	r, err := terraform.NewDebugArchiveReader(f)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading debug archive: %s", err))
+		return 1
+	}
+
+	var buf bytes.Buffer
+	ok, err := r.WriteChromeTrace(&buf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading timings: %s", err))
+		return 1
+	}
+	if !ok {
+		c.Ui.Error(strings.TrimSpace(errDebugTraceNoTimings))
+		return 1
+	}
+
+	c.Ui.Output(strings.TrimSuffix(buf.String(), "\n"))
+	return 0
+}
+
+func (c *DebugTraceCommand) Help() string {
+	helpText := `
+Usage: terraform debug trace ARCHIVE
+
+  Output the operation timings recorded in a debug archive as a trace in
+  the Chrome Trace Event Format, to explore the run interactively in
+  chrome://tracing, for example:
+
+      terraform debug trace debug.tar.gz > trace.json
+
+  The phases of the run are shown on one track. If the archive was written
+  with TF_DEBUG_WORKERS set, the vertices each worker evaluated are shown on
+  a track per worker, with the operations on each resource within them.
+  Otherwise the operations are laid out on as few tracks as possible.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugTraceCommand) Synopsis() string {
+	return "Convert debug archive timings to a Chrome trace"
+}
+
+const errDebugTraceNoTimings = `
+The debug archive has no timings to convert.
+
+Timings are recorded when resources are refreshed, diffed, applied or
+provisioned while the debug archive is enabled with the TF_DEBUG environment
+variable. Archives from commands that don't perform any of these operations,
+and from versions of Terraform that didn't record timings, have none. Run the
+plan or apply being investigated with TF_DEBUG set to record them, and with
+TF_DEBUG_WORKERS set to see which worker evaluated each resource.
+`
@@ -0,0 +1,152 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+// testDebugTraceEvent is the part of a Chrome trace event checked by the
+// tests.
+type testDebugTraceEvent struct {
+	Name  string            `json:"name"`
+	Phase string            `json:"ph"`
+	Time  int64             `json:"ts"`
+	Dur   int64             `json:"dur"`
+	TID   int               `json:"tid"`
+	Args  map[string]string `json:"args"`
+}
+
+// testDebugTrace runs the debug trace command on an archive with the given
+// entries, and returns the resulting events as "TRACK | NAME | TS+DUR"
+// strings.
+func testDebugTrace(t *testing.T, entries []testDebugEntry) []string {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, entries)
+
+	ui := new(cli.MockUi)
+	c := &DebugTraceCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{path}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	var trace struct {
+		TraceEvents []testDebugTraceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &trace); err != nil {
+		t.Fatalf("bad trace: %s\n%s", err, ui.OutputWriter.String())
+	}
+
+	tracks := make(map[int]string)
+	for _, e := range trace.TraceEvents {
+		if e.Phase == "M" && e.Name == "thread_name" {
+			tracks[e.TID] = e.Args["name"]
+		}
+	}
+
+	var result []string
+	for _, e := range trace.TraceEvents {
+		if e.Phase != "X" {
+			continue
+		}
+		result = append(result, fmt.Sprintf("%s | %s | %d+%d", tracks[e.TID], e.Name, e.Time, e.Dur))
+	}
+	sort.Strings(result)
+	return result
+}
+
+const testDebugTraceTimings = `[
+  {"Operation": "phase", "Address": "apply", "Phase": "apply", "Start": "2017-01-01T00:00:00Z", "Duration": 10000000},
+  {"Operation": "apply", "Address": "aws_instance.foo", "Phase": "apply", "Start": "2017-01-01T00:00:00.001Z", "Duration": 4000000},
+  {"Operation": "apply", "Address": "aws_instance.bar.0", "Phase": "apply", "Start": "2017-01-01T00:00:00.002Z", "Duration": 4000000},
+  {"Operation": "apply", "Address": "aws_instance.bar.1", "Phase": "apply", "Start": "2017-01-01T00:00:00.006Z", "Duration": 2000000}
+]`
+
+func TestDebugTrace(t *testing.T) {
+	actual := testDebugTrace(t, []testDebugEntry{
+		{"9-apply-timings.json", []byte(testDebugTraceTimings)},
+	})
+
+	// without worker assignments, the operations are packed onto as few
+	// tracks as possible
+	expected := []string{
+		"operations 0 | apply aws_instance.bar.1 | 6000+2000",
+		"operations 0 | apply aws_instance.foo | 1000+4000",
+		"operations 1 | apply aws_instance.bar.0 | 2000+4000",
+		"phases | apply | 0+10000",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestDebugTrace_workers(t *testing.T) {
+	actual := testDebugTrace(t, []testDebugEntry{
+		{"9-apply-timings.json", []byte(testDebugTraceTimings)},
+		{"10-apply-worker-assignments.json", []byte(`{
+  "Workers": [{"Worker": 0}, {"Worker": 1}],
+  "Assignments": [
+    {"Operation": "walkApply", "Vertex": "aws_instance.foo", "Worker": 0, "Start": "2017-01-01T00:00:00.0005Z", "Duration": 5000000},
+    {"Operation": "walkApply", "Vertex": "aws_instance.bar", "Worker": 1, "Start": "2017-01-01T00:00:00.0015Z", "Duration": 8000000}
+  ]
+}`)},
+	})
+
+	expected := []string{
+		"phases | apply | 0+10000",
+		"worker 0 | apply aws_instance.foo | 1000+4000",
+		"worker 0 | aws_instance.foo | 500+5000",
+		"worker 1 | apply aws_instance.bar.0 | 2000+4000",
+		"worker 1 | apply aws_instance.bar.1 | 6000+2000",
+		"worker 1 | aws_instance.bar | 1500+8000",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestDebugTrace_noTimings(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "debug.tar.gz")
+	testDebugArchive(t, path, []testDebugEntry{
+		{"graphs/0-plan-plan.dot", []byte("digraph {}")},
+	})
+
+	ui := new(cli.MockUi)
+	c := &DebugTraceCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{path}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "no timings") {
+		t.Fatalf("bad error:\n%s", ui.ErrorWriter.String())
+	}
+}
@@ -125,6 +125,7 @@ func (m *Meta) Backend(opts *BackendOpts) (backend.Enhanced, error) {
 	// If the result of loading the backend is an enhanced backend,
 	// then return that as-is. This works even if b == nil (it will be !ok).
 	if enhanced, ok := b.(backend.Enhanced); ok {
+		m.backendDebugLocation(enhanced)
 		return enhanced, nil
 	}
 
@@ -143,9 +144,38 @@ func (m *Meta) Backend(opts *BackendOpts) (backend.Enhanced, error) {
 		panic(err)
 	}
 
+	m.backendDebugLocation(local)
 	return local, nil
 }
 
+// backendDebugLocation records where the backend stores the state for the
+// current environment in the debug archive, if the backend can describe it.
+// This is purely diagnostic, so failures are only logged.
+func (m *Meta) backendDebugLocation(b backend.Backend) {
+	l, ok := b.(backend.StateLocator)
+	if !ok {
+		return
+	}
+
+	env := m.Env()
+	loc, err := l.StateLocation(env)
+	if err != nil {
+		log.Printf("[WARN] command: can't determine state location: %s", err)
+		return
+	}
+	if loc == "" {
+		return
+	}
+
+	err = terraform.SetDebugStateLocation(terraform.DebugStateLocation{
+		Workspace: env,
+		Location:  loc,
+	})
+	if err != nil {
+		log.Printf("[WARN] command: error recording state location: %s", err)
+	}
+}
+
 // IsLocalBackend returns true if the backend is a local backend. We use this
 // for some checks that require a remote backend.
 func (m *Meta) IsLocalBackend(b backend.Backend) bool {
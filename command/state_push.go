@@ -1,42 +1,208 @@
 package command
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/state/remote"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
 
+// statePushFetchTimeout is the maximum time allowed to fetch a source state
+// given as an HTTP(S) URL.
+const statePushFetchTimeout = 60 * time.Second
+
+// stateLineagePattern matches a valid lineage, which is a UUID in the
+// canonical lower case form Terraform generates.
+var stateLineagePattern = regexp.MustCompile(
+	`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// stateSnapshotPattern matches a valid snapshot name, which must be usable
+// as part of a file name or object key by any backend.
+var stateSnapshotPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// errStateSnapshotUnsupported is returned by stateSnapshot if the storage of
+// the state can't create snapshots.
+var errStateSnapshotUnsupported = errors.New("snapshots are not supported")
+
 // StatePushCommand is a Command implementation that shows a single resource.
 type StatePushCommand struct {
 	Meta
 	StateMeta
+
+	// progressInterval is the interval between progress messages while the
+	// state is being written. Defaults to defaultPeriodicUiTimer.
+	progressInterval time.Duration
+
+	// streamWriter is where the progress events of -json-stream are
+	// written. Defaults to os.Stderr.
+	streamWriter io.Writer
 }
 
-func (c *StatePushCommand) Run(args []string) int {
+func (c *StatePushCommand) Run(args []string) (code int) {
 	args = c.Meta.process(args, true)
 
-	var flagForce bool
+	var flagForce, flagNormalize, flagOutputsOnly, flagPatch, flagQuiet, flagUpgrade bool
+	var flagJSONStream, flagValidateRefs, flagVerifyIdempotent bool
+	var flagConsumers, flagExclude, flagPreserve []string
+	var flagAuditLog, flagLineage, flagModule, flagSnapshot, flagStateDest string
+	var flagMinVersion, flagStrategy string
 	cmdFlags := c.Meta.flagSet("state push")
+	cmdFlags.StringVar(&flagAuditLog, "audit-log", os.Getenv(StatePushAuditLogEnvVar), "path")
 	cmdFlags.BoolVar(&flagForce, "force", false, "")
+	cmdFlags.BoolVar(&flagJSONStream, "json-stream", false, "")
+	cmdFlags.BoolVar(&flagNormalize, "normalize", false, "")
+	cmdFlags.BoolVar(&flagOutputsOnly, "outputs-only", false, "")
+	cmdFlags.BoolVar(&flagPatch, "patch", false, "")
+	cmdFlags.BoolVar(&flagQuiet, "quiet", false, "")
+	cmdFlags.BoolVar(&flagUpgrade, "upgrade", false, "")
+	cmdFlags.BoolVar(&flagValidateRefs, "validate-refs", false, "")
+	cmdFlags.BoolVar(&flagVerifyIdempotent, "verify-idempotent", false, "")
+	cmdFlags.StringVar(&flagMinVersion, "min-version", "", "version")
+	cmdFlags.StringVar(&flagModule, "module", "", "module")
+	cmdFlags.StringVar(&flagLineage, "set-lineage", "", "lineage")
+	cmdFlags.StringVar(&flagSnapshot, "snapshot", "", "name")
+	cmdFlags.StringVar(&flagStateDest, "state-dest", "", "path")
+	cmdFlags.StringVar(&flagStrategy, "strategy", "", "strategy")
+	cmdFlags.Var((*FlagStringSlice)(&flagConsumers), "consumer", "dir")
+	cmdFlags.Var((*FlagStringSlice)(&flagExclude), "exclude", "address")
+	cmdFlags.Var((*FlagStringSlice)(&flagPreserve), "preserve", "address")
 	if err := cmdFlags.Parse(args); err != nil {
 		return cli.RunResultHelp
 	}
 	args = cmdFlags.Args()
 
+	// Stream the progress events, ending with whether the push succeeded
+	var stream *statePushStream
+	if flagJSONStream {
+		stream = &statePushStream{w: c.streamWriter}
+		if stream.w == nil {
+			stream.w = os.Stderr
+		}
+
+		ui := &statePushAuditUi{Ui: c.Ui}
+		c.Ui = ui
+		defer func() {
+			c.Ui = ui.Ui
+			stream.end(code, ui.errors)
+		}()
+	}
+
+	// Record every attempt in the audit log, including those that are
+	// blocked, with the errors that blocked them.
+	var audit *statePushAudit
+	if flagAuditLog != "" {
+		source := ""
+		if len(args) > 0 {
+			source = args[0]
+		}
+		audit = newStatePushAudit(source, flagForce)
+
+		ui := &statePushAuditUi{Ui: c.Ui}
+		c.Ui = ui
+		defer func() {
+			c.Ui = ui.Ui
+			if err := audit.write(flagAuditLog, code, ui.errors); err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Failed to write the audit log %q: %s", flagAuditLog, err))
+				code = 1
+			}
+		}()
+	}
+
 	if len(args) != 1 {
 		c.Ui.Error("Exactly one argument expected: path to state to push")
 		return 1
 	}
 
-	// Determine our reader for the input state. This is the filepath
-	// or stdin if "-" is given.
+	if flagOutputsOnly && flagModule != "" {
+		c.Ui.Error("The -outputs-only and -module flags can't be used together")
+		return 1
+	}
+
+	if len(flagPreserve) > 0 && (flagOutputsOnly || flagModule != "") {
+		c.Ui.Error("The -preserve flag can't be used with -outputs-only or -module")
+		return 1
+	}
+
+	if flagPatch && (len(flagExclude) > 0 || flagModule != "" || flagNormalize ||
+		flagOutputsOnly || len(flagPreserve) > 0 || flagLineage != "" ||
+		flagStrategy != "" || flagUpgrade || flagValidateRefs) {
+		c.Ui.Error("The -patch flag can't be used with -exclude, -module, -normalize, " +
+			"-outputs-only, -preserve, -set-lineage, -strategy, -upgrade or -validate-refs")
+		return 1
+	}
+
+	if err := validateStatePushStrategy(flagStrategy); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	if flagStrategy != "" && (flagForce || flagOutputsOnly || flagModule != "") {
+		c.Ui.Error("The -strategy flag can't be used with -force, -outputs-only or -module")
+		return 1
+	}
+
+	if flagLineage != "" && !stateLineagePattern.MatchString(flagLineage) {
+		c.Ui.Error(fmt.Sprintf(
+			"Invalid lineage %q: the lineage must be a lower case UUID, "+
+				"i.e. \"9ab1e3f4-52a6-4c4a-a2f1-2b0c2a1e4f5d\"", flagLineage))
+		return 1
+	}
+
+	if flagSnapshot != "" && !stateSnapshotPattern.MatchString(flagSnapshot) {
+		c.Ui.Error(fmt.Sprintf(
+			"Invalid snapshot name %q: the name may only contain letters, "+
+				"digits, \"_\", \".\" and \"-\"", flagSnapshot))
+		return 1
+	}
+
+	var minVersion *version.Version
+	if flagMinVersion != "" {
+		if flagPatch {
+			c.Ui.Error("The -min-version flag can't be used with -patch")
+			return 1
+		}
+		var err error
+		minVersion, err = version.NewVersion(flagMinVersion)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -min-version %q: %s", flagMinVersion, err))
+			return 1
+		}
+	}
+
+	var consumers []*stateConsumer
+	for _, v := range flagConsumers {
+		consumer, err := parseStateConsumer(v)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		consumers = append(consumers, consumer)
+	}
+
+	// Determine our reader for the input state. This is the filepath,
+	// an HTTP(S) URL, or stdin if "-" is given.
 	var r io.Reader = os.Stdin
 	if args[0] != "-" {
-		f, err := os.Open(args[0])
+		var err error
+		r, err = c.openSource(args[0])
 		if err != nil {
 			c.Ui.Error(err.Error())
 			return 1
@@ -44,12 +210,29 @@ func (c *StatePushCommand) Run(args []string) int {
 
 		// Note: we don't need to defer a Close here because we do a close
 		// automatically below directly after the read.
-
-		r = f
 	}
 
-	// Read the state
-	sourceState, err := terraform.ReadState(r)
+	// Fail clearly on a source that looks encrypted, rather than with the
+	// error parsing it.
+	src, err := stateCheckEncrypted(r)
+
+	// Read the state. Keep a copy of the raw state if we're normalizing,
+	// since reading a state that isn't in the canonical format increments
+	// the serial, or upgrading, since reading it upgrades the format.
+	var raw bytes.Buffer
+	sr := src
+	if flagNormalize || flagUpgrade {
+		sr = io.TeeReader(src, &raw)
+	}
+	// A patch is a sparse state, which is merged into the destination
+	// rather than replacing it.
+	var sourceState *terraform.State
+	var patch *statePatch
+	if err == nil && flagPatch {
+		patch, err = readStatePatch(src)
+	} else if err == nil {
+		sourceState, err = terraform.ReadState(sr)
+	}
 	if c, ok := r.(io.Closer); ok {
 		// Close the reader if possible right now since we're done with it.
 		c.Close()
@@ -58,58 +241,989 @@ func (c *StatePushCommand) Run(args []string) int {
 		c.Ui.Error(fmt.Sprintf("Error reading source state %q: %s", args[0], err))
 		return 1
 	}
+	audit.setSource(sourceState)
+	stream.stageState("source_read", sourceState)
 
-	// Load the backend
-	b, err := c.Backend(nil)
-	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Failed to load backend: %s", err))
-		return 1
+	// Check the version of Terraform that wrote the source, before anything
+	// changes it
+	if minVersion != nil {
+		if err := stateCheckMinVersion(sourceState, minVersion); err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
 	}
 
-	// Get the state
-	env := c.Env()
-	state, err := b.State(env)
-	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Failed to load destination state: %s", err))
-		return 1
+	// Strip any excluded resources before pushing
+	if len(flagExclude) > 0 {
+		n, err := stateExclude(sourceState, flagExclude)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error excluding resources: %s", err))
+			return 1
+		}
+		if !flagQuiet {
+			c.Ui.Output(fmt.Sprintf(
+				"Excluded %d resource(s) from the state to push.", n))
+		}
+	}
+
+	// Round trip the state through the canonical serialization
+	if flagNormalize {
+		sourceState, err = stateNormalize(sourceState, raw.Bytes())
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error normalizing source state: %s", err))
+			return 1
+		}
+	}
+
+	// Upgrade the state as the next apply would, so it doesn't happen
+	// unexpectedly later
+	if flagUpgrade {
+		from, to, err := stateUpgrade(sourceState, raw.Bytes())
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error upgrading source state: %s", err))
+			return 1
+		}
+		if !flagQuiet {
+			if from == to {
+				c.Ui.Output(fmt.Sprintf("The source state is already current (%s).", to))
+			} else {
+				c.Ui.Output(fmt.Sprintf("Upgraded the source state from %s to %s.", from, to))
+			}
+		}
+	}
+
+	// Replace the lineage before the safety checks, so the checks compare the
+	// destination with the state that will actually be written.
+	if flagLineage != "" {
+		c.Ui.Warn(fmt.Sprintf(
+			strings.TrimSpace(warnStatePushSetLineage)+"\n",
+			sourceState.Lineage, flagLineage))
+		sourceState.Lineage = flagLineage
+	}
+
+	// Check that the dependencies recorded in the state resolve, so a state
+	// edited by hand doesn't fail later on a resource that isn't there.
+	if flagValidateRefs {
+		dangling := stateDanglingRefs(sourceState)
+		if len(dangling) > 0 {
+			var buf bytes.Buffer
+			for _, ref := range dangling {
+				buf.WriteString(fmt.Sprintf("\n  %s depends on %s", ref.Resource, ref.Dependency))
+			}
+			if !flagForce {
+				c.Ui.Error(fmt.Sprintf(
+					strings.TrimSpace(errStatePushDanglingRefs), buf.String()))
+				return 1
+			}
+			c.Ui.Warn(fmt.Sprintf(
+				strings.TrimSpace(warnStatePushDanglingRefs)+"\n", buf.String()))
+		} else if !flagQuiet {
+			c.Ui.Output("All dependencies in the state resolve.")
+		}
 	}
-	if err := state.RefreshState(); err != nil {
+
+	// Get the destination state. This is a local file if -state-dest is
+	// given, bypassing the backend entirely.
+	var stateMgr state.State
+	if flagStateDest != "" {
+		stateMgr = &state.LocalState{
+			Path: strings.TrimPrefix(flagStateDest, "file://"),
+		}
+	} else {
+		// Load the backend
+		b, err := c.Backend(nil)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to load backend: %s", err))
+			return 1
+		}
+
+		// Get the state
+		env := c.Env()
+		stateMgr, err = b.State(env)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to load destination state: %s", err))
+			return 1
+		}
+	}
+
+	if err := stateMgr.RefreshState(); err != nil {
 		c.Ui.Error(fmt.Sprintf("Failed to load destination state: %s", err))
 		return 1
 	}
-	dstState := state.State()
+	dstState := stateMgr.State()
+	audit.setDestination(dstState)
+	stream.stageState("destination_read", dstState)
 
-	// If we're not forcing, then perform safety checks
-	if !flagForce && !dstState.Empty() {
+	// resolved is set if a conflict was resolved by pushing anyway
+	resolved := false
+
+	// A patch updates the given resources of the destination, which keeps
+	// its own lineage, and the serial is incremented for the change. Instead
+	// of the safety checks, -force is required to replace resources that may
+	// have changed since the patch was made.
+	if flagPatch {
+		merged := terraform.NewState()
+		if dstState != nil {
+			merged = dstState.DeepCopy()
+		}
+
+		patched, err := stateApplyPatch(merged, patch, flagForce)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		if !flagQuiet {
+			msg := fmt.Sprintf(
+				"Patched %d resource(s) in the destination state.", len(patched))
+			if len(patched) > 0 {
+				msg = fmt.Sprintf(
+					"Patched %d resource(s) in the destination state:\n\n  %s\n",
+					len(patched), strings.Join(patched, "\n  "))
+			}
+			c.Ui.Output(msg)
+		}
+
+		merged.Serial++
+		sourceState = merged
+	} else if flagModule != "" {
+		// A targeted push merges the module's resources into the
+		// destination, which keeps its own lineage and serial, so the safety
+		// checks don't apply. Instead, -force is required to replace
+		// existing resources.
+		merged := terraform.NewState()
+		if dstState != nil {
+			merged = dstState.DeepCopy()
+		}
+
+		n, err := stateMergeModule(merged, sourceState, flagModule, flagForce)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		if !flagQuiet {
+			c.Ui.Output(fmt.Sprintf(
+				"Merged %d resource(s) from module %s into the destination state.",
+				n, flagModule))
+		}
+
+		if flagLineage != "" {
+			merged.Lineage = flagLineage
+		}
+		sourceState = merged
+	} else if flagOutputsOnly {
+		// Only the root outputs are replaced, so like a targeted push the
+		// destination keeps its own lineage, and the serial is incremented
+		// for the change.
+		merged := terraform.NewState()
+		if dstState != nil {
+			merged = dstState.DeepCopy()
+		}
+
+		n, err := stateMergeOutputs(merged, sourceState)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		if !flagQuiet {
+			c.Ui.Output(fmt.Sprintf(
+				"Updated %d output(s) in the destination state.", n))
+		}
+
+		merged.Serial++
+		if flagLineage != "" {
+			merged.Lineage = flagLineage
+		}
+		sourceState = merged
+	} else if !flagForce && !dstState.Empty() {
+		// If we're not forcing, then perform safety checks
+		conflict := ""
 		if !dstState.SameLineage(sourceState) {
-			c.Ui.Error(strings.TrimSpace(errStatePushLineage))
+			conflict = errStatePushLineage
+		} else {
+			age, err := dstState.CompareAges(sourceState)
+			if err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+			if age == terraform.StateAgeReceiverNewer {
+				conflict = errStatePushSerialNewer
+			}
+		}
+
+		// A conflict blocks the push, unless a strategy resolves it
+		switch {
+		case conflict == "":
+		case flagStrategy == "":
+			c.Ui.Error(statePushBlocked(conflict, sourceState, dstState))
 			return 1
+		case statePushResolve(flagStrategy, sourceState, dstState):
+			resolved = true
+			if !flagQuiet {
+				c.Ui.Output(fmt.Sprintf(
+					"Resolved the conflict with the %q strategy: pushing the source "+
+						"state (lineage %q, serial %d).",
+					flagStrategy, sourceState.Lineage, sourceState.Serial))
+			}
+		default:
+			if !flagQuiet {
+				c.Ui.Output(fmt.Sprintf(
+					"Resolved the conflict with the %q strategy: keeping the "+
+						"destination state (lineage %q, serial %d). The state was "+
+						"not pushed.",
+					flagStrategy, dstState.Lineage, dstState.Serial))
+			}
+			return 0
 		}
+	}
+
+	stream.stage("checked")
 
-		age, err := dstState.CompareAges(sourceState)
+	// Keep the preserved resources of the destination, rather than those of
+	// the source
+	if len(flagPreserve) > 0 {
+		preserved, err := statePreserve(sourceState, dstState, flagPreserve, flagForce)
 		if err != nil {
 			c.Ui.Error(err.Error())
 			return 1
 		}
-		if age == terraform.StateAgeReceiverNewer {
-			c.Ui.Error(strings.TrimSpace(errStatePushSerialNewer))
+		if !flagQuiet {
+			msg := fmt.Sprintf(
+				"Preserved %d resource(s) of the destination state.", len(preserved))
+			if len(preserved) > 0 {
+				msg = fmt.Sprintf(
+					"Preserved %d resource(s) of the destination state:\n\n  %s\n",
+					len(preserved), strings.Join(preserved, "\n  "))
+			}
+			c.Ui.Output(msg)
+		}
+	}
+
+	// Check that the consumers of the state's outputs will still find the
+	// outputs they reference, in the state as it will be written
+	if len(consumers) > 0 {
+		var dest *stateLocation
+		if flagStateDest != "" {
+			dest, err = newStateLocation("local", map[string]interface{}{
+				"path": strings.TrimPrefix(flagStateDest, "file://"),
+			}, "", ".")
+		} else {
+			dest, err = stateBackendLocation(c.DataDir(), c.Env())
+		}
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error reading the destination backend: %s", err))
 			return 1
 		}
+
+		broken, err := stateBrokenConsumerRefs(sourceState, dstState, dest, consumers)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		if len(broken) > 0 {
+			var buf bytes.Buffer
+			for _, b := range broken {
+				buf.WriteString(fmt.Sprintf("\n  %s: %s (%s)", b.Consumer, b.Ref, b.Reason))
+			}
+			if !flagForce {
+				c.Ui.Error(fmt.Sprintf(
+					strings.TrimSpace(errStatePushBreaksConsumers), buf.String()))
+				return 1
+			}
+			c.Ui.Warn(fmt.Sprintf(
+				strings.TrimSpace(warnStatePushBreaksConsumers)+"\n", buf.String()))
+		} else if !flagQuiet {
+			c.Ui.Output(fmt.Sprintf(
+				"The state provides every output referenced by its %d consumer(s).",
+				len(consumers)))
+		}
 	}
 
-	// Overwrite it
-	if err := state.WriteState(sourceState); err != nil {
-		c.Ui.Error(fmt.Sprintf("Failed to write state: %s", err))
-		return 1
+	// Overwrite it. Large states on slow backends can take a while, so
+	// show that we're still working, unless the progress is streamed.
+	stopProgress := func() {}
+	if !flagQuiet && !flagJSONStream {
+		stopProgress = c.showProgress(stateProgresser(stateMgr))
+	}
+	audit.setWritten(sourceState)
+	err = stateMgr.WriteState(sourceState)
+	if err == nil {
+		if path := stateBackupPath(stateMgr); path != "" {
+			stream.emit(statePushEvent{Stage: "backup_written", Path: path})
+		}
+		stream.stageState("state_written", sourceState)
+		err = stateMgr.PersistState()
 	}
-	if err := state.PersistState(); err != nil {
+	stopProgress()
+	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Failed to write state: %s", err))
 		return 1
 	}
+	stream.stage("persisted")
+	if resolved && !flagQuiet {
+		if s := stateMgr.State(); s != nil {
+			c.Ui.Output(fmt.Sprintf(
+				"The destination state now has lineage %q and serial %d.",
+				s.Lineage, s.Serial))
+		}
+	}
+
+	if flagVerifyIdempotent {
+		diff, err := stateVerifyIdempotent(stateMgr)
+		if err != nil || diff != "" {
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error verifying the pushed state: %s", err))
+			} else {
+				c.Ui.Error(fmt.Sprintf(strings.TrimSpace(errStatePushNotIdempotent), diff))
+			}
+
+			// Don't leave the result of the experiment behind
+			restore := dstState
+			if restore == nil {
+				restore = terraform.NewState()
+			}
+			err = stateMgr.WriteState(restore)
+			if err == nil {
+				err = stateMgr.PersistState()
+			}
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Failed to restore the destination state: %s", err))
+			} else {
+				c.Ui.Error("The destination state was restored to its contents before the push.")
+			}
+			return 1
+		}
+
+		if !flagQuiet {
+			c.Ui.Output("The destination stored the state identically when it was pushed again.")
+		}
+		stream.stage("verified")
+	}
+
+	// Snapshot what was pushed, now that it's known to be stored
+	if flagSnapshot != "" {
+		id, err := stateSnapshot(stateMgr, flagSnapshot)
+		switch {
+		case err == errStateSnapshotUnsupported:
+			c.Ui.Warn(fmt.Sprintf(
+				strings.TrimSpace(warnStatePushSnapshotUnsupported), flagSnapshot))
+		case err != nil:
+			c.Ui.Error(fmt.Sprintf(
+				"The state was pushed, but creating snapshot %q failed: %s",
+				flagSnapshot, err))
+			return 1
+		default:
+			c.Ui.Output(fmt.Sprintf("Created snapshot %q: %s", flagSnapshot, id))
+			stream.emit(statePushEvent{Stage: "snapshot_created", Snapshot: id})
+		}
+	}
 
 	return 0
 }
 
+// showProgress periodically outputs a message until the returned function is
+// called. No more messages are output once that function returns. The
+// elapsed time is always shown, along with the percentage of the state
+// written if p is non-nil and reports a write in progress.
+func (c *StatePushCommand) showProgress(p state.Progresser) func() {
+	interval := c.progressInterval
+	if interval == 0 {
+		interval = defaultPeriodicUiTimer
+	}
+
+	start := time.Now().Round(time.Second)
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(interval):
+			}
+
+			elapsed := time.Now().Round(time.Second).Sub(start)
+			if p != nil {
+				if written, total := p.Progress(); total > 0 {
+					c.Ui.Output(fmt.Sprintf(
+						"Still pushing state... (%d%%, %s elapsed)",
+						written*100/total, elapsed))
+					continue
+				}
+			}
+			c.Ui.Output(fmt.Sprintf("Still pushing state... (%s elapsed)", elapsed))
+		}
+	}()
+
+	return func() {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// openSource opens the state to push at path. If path is a URL it is
+// fetched over HTTP(S), otherwise it is opened as a local file.
+func (c *StatePushCommand) openSource(path string) (io.ReadCloser, error) {
+	if !strings.Contains(path, "://") {
+		return os.Open(path)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing source URL %q: %s", path, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf(
+			"Unsupported source URL scheme %q: only http and https are supported",
+			u.Scheme)
+	}
+
+	// The cleanhttp client respects the standard proxy environment
+	// variables, the same as the rest of Terraform's HTTP clients.
+	client := cleanhttp.DefaultClient()
+	client.Timeout = statePushFetchTimeout
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching source state: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf(
+			"Error fetching source state: unexpected status %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// stateExclude removes the resources matching any of the given addresses
+// from s, returning the number of resources removed. Addresses are in the
+// resource addressing syntax, and may contain "*" wildcards which match any
+// sequence of characters, i.e. "module.*.aws_instance.foo".
+func stateExclude(s *terraform.State, addrs []string) (int, error) {
+	results, err := stateMatchResources(s, addrs)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	remove := make([]string, len(results))
+	for i, r := range results {
+		remove[i] = r.Address
+	}
+
+	return len(remove), s.Remove(remove...)
+}
+
+// stateMatchResources returns the resources of s matching any of the given
+// addresses, each once. Addresses are in the resource addressing syntax, and
+// may contain "*" wildcards as for stateExclude.
+func stateMatchResources(s *terraform.State, addrs []string) ([]*terraform.StateFilterResult, error) {
+	filter := &terraform.StateFilter{State: s}
+	all, err := filter.Filter()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*terraform.StateFilterResult
+	seen := make(map[string]bool)
+	for _, addr := range addrs {
+		var results []*terraform.StateFilterResult
+		if strings.Contains(addr, "*") {
+			for _, r := range all {
+				if stateWildcardMatch(addr, r.Address) {
+					results = append(results, r)
+				}
+			}
+		} else {
+			results, err = filter.Filter(addr)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, r := range results {
+			if _, ok := r.Value.(*terraform.ResourceState); !ok {
+				continue
+			}
+			if !seen[r.Address] {
+				seen[r.Address] = true
+				result = append(result, r)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// stateWildcardEscaper escapes everything path.Match treats specially but
+// "*", so the brackets of an index, as in "aws_instance.web[0]", match
+// themselves rather than a character class.
+var stateWildcardEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`?`, `\?`,
+)
+
+// stateWildcardMatch returns whether addr matches pattern, in which "*"
+// matches any sequence of characters, and everything else matches itself.
+func stateWildcardMatch(pattern, addr string) bool {
+	// with everything else escaped the pattern is always well formed
+	match, _ := path.Match(stateWildcardEscaper.Replace(pattern), addr)
+	return match
+}
+
+// statePreserve replaces the resources of src matching any of the given
+// addresses with the matching resources of dst, so that pushing src leaves
+// them intact. Matching resources that are only in src are removed from it.
+// Unless force is set, it's an error for any resource in src to match. It
+// returns the sorted addresses of the resources preserved.
+func statePreserve(src, dst *terraform.State, addrs []string, force bool) ([]string, error) {
+	srcResults, err := stateMatchResources(src, addrs)
+	if err != nil {
+		return nil, err
+	}
+	if len(srcResults) > 0 {
+		var conflicts []string
+		for _, r := range srcResults {
+			conflicts = append(conflicts, r.Address)
+		}
+		sort.Strings(conflicts)
+
+		if !force {
+			return nil, fmt.Errorf(
+				"The following resources to preserve are also in the source "+
+					"state:\n\n  %s\n\nUse -force to keep the destination's "+
+					"resources and push the rest of the source state.",
+				strings.Join(conflicts, "\n  "))
+		}
+		if err := src.Remove(conflicts...); err != nil {
+			return nil, err
+		}
+	}
+
+	if dst == nil {
+		return nil, nil
+	}
+	dstResults, err := stateMatchResources(dst, addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	var preserved []string
+	for _, r := range dstResults {
+		path := append([]string{"root"}, r.Path...)
+		for k, rs := range dst.ModuleByPath(path).Resources {
+			if rs != r.Value {
+				continue
+			}
+			m := src.ModuleByPath(path)
+			if m == nil {
+				m = src.AddModule(path)
+			}
+			m.Resources[k] = rs
+			preserved = append(preserved, r.Address)
+		}
+	}
+	sort.Strings(preserved)
+
+	return preserved, nil
+}
+
+// stateNormalize returns a copy of s that has been written with Terraform's
+// canonical serialization and read back, which sorts the modules and
+// resources and drops anything that doesn't survive a round trip. The
+// lineage, and the serial recorded in raw, are preserved so the safety
+// checks are unaffected.
+func stateNormalize(s *terraform.State, raw []byte) (*terraform.State, error) {
+	var recorded struct {
+		Serial *int64 `json:"serial"`
+	}
+	if err := json.Unmarshal(raw, &recorded); err == nil && recorded.Serial != nil {
+		s.Serial = *recorded.Serial
+	}
+
+	var buf bytes.Buffer
+	if err := terraform.WriteState(s, &buf); err != nil {
+		return nil, err
+	}
+
+	result, err := terraform.ReadState(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Lineage != s.Lineage || result.Serial != s.Serial {
+		return nil, fmt.Errorf(
+			"lineage or serial changed during normalization (%q, %d != %q, %d)",
+			result.Lineage, result.Serial, s.Lineage, s.Serial)
+	}
+	if !result.Equal(s) {
+		return nil, fmt.Errorf("state contents changed during normalization")
+	}
+
+	return result, nil
+}
+
+// stateUpgrade upgrades s to the current state format and Terraform version,
+// the same as applying it would. raw is the state as it was read, since
+// ReadState has already upgraded the format in memory. It returns
+// descriptions of the versions before and after the upgrade, which are equal
+// if the state was already current.
+func stateUpgrade(s *terraform.State, raw []byte) (string, string, error) {
+	var recorded struct {
+		Version   int    `json:"version"`
+		TFVersion string `json:"terraform_version"`
+	}
+	if err := json.Unmarshal(raw, &recorded); err != nil {
+		return "", "", err
+	}
+
+	if s.FromFutureTerraform() {
+		return "", "", fmt.Errorf(
+			"the state was written by Terraform %s, and can't be upgraded by "+
+				"the older Terraform %s", s.TFVersion, terraform.Version)
+	}
+
+	describe := func(format int, tfVersion string) string {
+		if tfVersion == "" {
+			tfVersion = "unknown"
+		}
+		return fmt.Sprintf("format version %d, Terraform version %s", format, tfVersion)
+	}
+
+	from := describe(recorded.Version, recorded.TFVersion)
+	if s.TFVersion != terraform.Version {
+		// applying the state would increment the serial for this change
+		s.TFVersion = terraform.Version
+		s.Serial++
+	}
+	s.Version = terraform.StateVersion
+
+	return from, describe(s.Version, s.TFVersion), nil
+}
+
+// stateCheckMinVersion returns an error if s wasn't written by Terraform min
+// or later, according to the version recorded in it. A state that doesn't
+// record the version is rejected too, since it can't be shown to be recent
+// enough.
+func stateCheckMinVersion(s *terraform.State, min *version.Version) error {
+	if s.TFVersion == "" {
+		return fmt.Errorf(strings.TrimSpace(errStatePushMinVersion), "an unknown version", min)
+	}
+
+	v, err := version.NewVersion(s.TFVersion)
+	if err != nil {
+		return fmt.Errorf("Invalid Terraform version %q in the source state: %s", s.TFVersion, err)
+	}
+	if v.LessThan(min) {
+		return fmt.Errorf(strings.TrimSpace(errStatePushMinVersion), "Terraform "+v.String(), min)
+	}
+	return nil
+}
+
+// stateMergeModule copies the resources of the named module, and of any
+// modules nested within it, from src into dst. The name is dot separated for
+// nested modules, i.e. "foo.bar". Resources that already exist in dst are
+// only replaced if force is set. It returns the number of resources copied.
+func stateMergeModule(dst, src *terraform.State, name string, force bool) (int, error) {
+	prefix := append([]string{"root"}, strings.Split(name, ".")...)
+
+	var mods []*terraform.ModuleState
+	var collisions []string
+	for _, m := range src.Modules {
+		if len(m.Path) < len(prefix) || !reflect.DeepEqual(m.Path[:len(prefix)], prefix) {
+			continue
+		}
+		mods = append(mods, m)
+
+		dm := dst.ModuleByPath(m.Path)
+		if dm == nil {
+			continue
+		}
+		for k := range m.Resources {
+			if _, ok := dm.Resources[k]; ok {
+				collisions = append(collisions, fmt.Sprintf(
+					"module.%s.%s", strings.Join(m.Path[1:], ".module."), k))
+			}
+		}
+	}
+
+	if len(mods) == 0 {
+		return 0, fmt.Errorf("Module %s was not found in the source state.", name)
+	}
+	if len(collisions) > 0 && !force {
+		sort.Strings(collisions)
+		return 0, fmt.Errorf(
+			"The following resources already exist in the destination state:\n\n"+
+				"  %s\n\nUse -force to replace them.",
+			strings.Join(collisions, "\n  "))
+	}
+
+	n := 0
+	for _, m := range mods {
+		dm := dst.AddModule(m.Path)
+		for k, r := range m.Resources {
+			dm.Resources[k] = r
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// stateMergeOutputs copies the root module outputs of src into dst,
+// replacing any existing outputs with the same names, and returns the number
+// of outputs copied. The resources of both states must be identical, so that
+// the push can't change anything but outputs.
+func stateMergeOutputs(dst, src *terraform.State) (int, error) {
+	var differences []string
+	paths := make(map[string][]string)
+	for _, s := range []*terraform.State{dst, src} {
+		for _, m := range s.Modules {
+			paths[strings.Join(m.Path, ".")] = m.Path
+		}
+	}
+	for _, p := range paths {
+		var srcResources, dstResources map[string]*terraform.ResourceState
+		if m := src.ModuleByPath(p); m != nil {
+			srcResources = m.Resources
+		}
+		if m := dst.ModuleByPath(p); m != nil {
+			dstResources = m.Resources
+		}
+
+		prefix := ""
+		if len(p) > 1 {
+			prefix = "module." + strings.Join(p[1:], ".module.") + "."
+		}
+		for k, r := range srcResources {
+			if d, ok := dstResources[k]; !ok || !r.Equal(d) {
+				differences = append(differences, prefix+k)
+			}
+		}
+		for k := range dstResources {
+			if _, ok := srcResources[k]; !ok {
+				differences = append(differences, prefix+k)
+			}
+		}
+	}
+	if len(differences) > 0 {
+		sort.Strings(differences)
+		return 0, fmt.Errorf(
+			"The resources of the source and destination states differ, so only "+
+				"pushing the outputs would lose changes:\n\n  %s\n\n"+
+				"Push the whole state instead, or remove the differences from "+
+				"the source state.",
+			strings.Join(differences, "\n  "))
+	}
+
+	root := src.RootModule()
+	if root == nil || len(root.Outputs) == 0 {
+		return 0, fmt.Errorf("The source state has no root module outputs to push.")
+	}
+
+	dstRoot := dst.RootModule()
+	if dstRoot == nil {
+		dstRoot = dst.AddModule(terraform.RootModulePath)
+	}
+	if dstRoot.Outputs == nil {
+		dstRoot.Outputs = make(map[string]*terraform.OutputState)
+	}
+	for k, o := range root.Outputs {
+		dstRoot.Outputs[k] = o
+	}
+
+	return len(root.Outputs), nil
+}
+
+// stateVerifyIdempotent reads back the state just persisted by mgr, writes
+// what was read back, and reads it back again. It returns a diff of the two
+// read-backs, which is empty if the backend stored the state identically
+// both times.
+func stateVerifyIdempotent(mgr state.State) (string, error) {
+	first, err := stateReadBack(mgr)
+	if err != nil {
+		return "", err
+	}
+
+	if err := mgr.WriteState(mgr.State()); err != nil {
+		return "", err
+	}
+	if err := mgr.PersistState(); err != nil {
+		return "", err
+	}
+
+	second, err := stateReadBack(mgr)
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.Equal(first, second) {
+		return "", nil
+	}
+	return stateLineDiff(first, second), nil
+}
+
+// stateReadBack refreshes mgr and returns the state it stores. For local and
+// remote states the stored data is returned as is, so that any reformatting
+// by the backend is detected. Otherwise the state is serialized again, which
+// is deterministic.
+func stateReadBack(mgr state.State) ([]byte, error) {
+	if err := mgr.RefreshState(); err != nil {
+		return nil, err
+	}
+
+	inner := mgr
+	for {
+		switch s := inner.(type) {
+		case *state.BackupState:
+			inner = s.Real
+			continue
+		case *state.LockDisabled:
+			inner = s.Inner
+			continue
+		case *state.LocalState:
+			path := s.PathOut
+			if path == "" {
+				path = s.Path
+			}
+			return ioutil.ReadFile(path)
+		case *remote.State:
+			payload, err := s.Client.Get()
+			if err != nil || payload == nil {
+				return nil, err
+			}
+			return payload.Data, nil
+		}
+		break
+	}
+
+	var buf bytes.Buffer
+	if err := terraform.WriteState(mgr.State(), &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// stateSnapshot creates a snapshot of the state stored by mgr, returning
+// its identifier, or errStateSnapshotUnsupported if the storage can't create
+// snapshots.
+func stateSnapshot(mgr state.State, name string) (string, error) {
+	inner := mgr
+	for {
+		switch s := inner.(type) {
+		case *state.BackupState:
+			inner = s.Real
+			continue
+		case *state.LockDisabled:
+			inner = s.Inner
+			continue
+		case *remote.State:
+			if c, ok := s.Client.(remote.ClientSnapshotter); ok {
+				return c.Snapshot(name)
+			}
+			return "", errStateSnapshotUnsupported
+		case state.Snapshotter:
+			return s.Snapshot(name)
+		}
+		return "", errStateSnapshotUnsupported
+	}
+}
+
+// stateProgresser returns what reports the progress of persisting the
+// state stored by mgr, or nil if its storage doesn't report it.
+func stateProgresser(mgr state.State) state.Progresser {
+	inner := mgr
+	for {
+		switch s := inner.(type) {
+		case *state.BackupState:
+			inner = s.Real
+			continue
+		case *state.LockDisabled:
+			inner = s.Inner
+			continue
+		case *remote.State:
+			if c, ok := s.Client.(remote.ClientProgresser); ok {
+				return c
+			}
+			return nil
+		case state.Progresser:
+			return s
+		}
+		return nil
+	}
+}
+
+// stateLineDiff returns a line diff of a and b, with lines only in a
+// prefixed by "-" and lines only in b by "+". The common lines before the
+// first difference and after the last are left out.
+func stateLineDiff(a, b []byte) string {
+	as := strings.Split(string(a), "\n")
+	bs := strings.Split(string(b), "\n")
+
+	for len(as) > 0 && len(bs) > 0 && as[0] == bs[0] {
+		as, bs = as[1:], bs[1:]
+	}
+	for len(as) > 0 && len(bs) > 0 && as[len(as)-1] == bs[len(bs)-1] {
+		as, bs = as[:len(as)-1], bs[:len(bs)-1]
+	}
+
+	var buf bytes.Buffer
+	writeLines := func(prefix string, lines []string) {
+		for _, l := range lines {
+			buf.WriteString(prefix + l + "\n")
+		}
+	}
+
+	// Align the remaining lines by their longest common subsequence, unless
+	// that would take too long, in which case all of a is shown as removed
+	// and all of b as added.
+	if len(as)*len(bs) > 1000000 {
+		writeLines("-", as)
+		writeLines("+", bs)
+		return buf.String()
+	}
+
+	lcs := make([][]int, len(as)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bs)+1)
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(as) && j < len(bs) {
+		switch {
+		case as[i] == bs[j]:
+			writeLines(" ", as[i:i+1])
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			writeLines("-", as[i:i+1])
+			i++
+		default:
+			writeLines("+", bs[j:j+1])
+			j++
+		}
+	}
+	writeLines("-", as[i:])
+	writeLines("+", bs[j:])
+
+	return buf.String()
+}
+
 func (c *StatePushCommand) Help() string {
 	helpText := `
 Usage: terraform state push [options] PATH
@@ -128,10 +1242,132 @@ Usage: terraform state push [options] PATH
   Data from stdin is not streamed to the backend: it is loaded completely
   (until pipe close), verified, and then pushed.
 
+  If PATH is an http:// or https:// URL, the state is downloaded from that
+  URL before being verified and pushed. The standard HTTP_PROXY, HTTPS_PROXY
+  and NO_PROXY environment variables are respected.
+
 Options:
 
+  -audit-log=PATH     Append a JSON record of the push attempt to the audit
+                      log at PATH, whether or not the state is pushed. The
+                      record includes the operator, the source and
+                      destination lineages and serials, whether -force was
+                      used, and the result. Defaults to the value of the
+                      TF_STATE_PUSH_AUDIT_LOG environment variable.
+
+  -consumer=DIR       Check that the state to push still provides the root
+                      outputs referenced through terraform_remote_state
+                      data sources by the root module of the configuration
+                      in DIR, refusing to push unless -force is given. Each
+                      missing output, or output whose type changes, is
+                      listed with the consumer. If the configuration has
+                      more than one terraform_remote_state data source, the
+                      one configured with the backend the state is pushed
+                      to is checked. Use DIR:NAME to check
+                      data.terraform_remote_state.NAME instead, which is
+                      required if that isn't exactly one. This flag can be
+                      used multiple times.
+
+  -exclude=ADDR       Remove the resources matching ADDR from the state
+                      before pushing it. ADDR may be module qualified and
+                      may contain "*" wildcards. This flag can be used
+                      multiple times.
+
   -force              Write the state even if lineages don't match or the
-                      remote serial is higher.
+                      remote serial is higher. With -module, replace
+                      resources that already exist in the destination.
+                      With -preserve, push the source state even if it has
+                      resources to preserve, keeping the destination's.
+                      With -patch, replace resources that may have changed
+                      since the patch was made. With -validate-refs, push
+                      the state even if it has dangling dependencies. With
+                      -consumer, push the state even if it breaks them.
+
+  -json-stream        Write a line of JSON to stderr as each stage of the push
+                      completes, such as reading the source, writing and
+                      persisting the state, ending with whether the push
+                      succeeded. Each event has a "time" and a "stage".
+                      The periodic progress messages aren't output.
+
+  -min-version=VER    Refuse to push the state unless it was written by
+                      Terraform VER or later, according to the version
+                      recorded in it. A state that doesn't record the
+                      version is refused too. This can't be overridden by
+                      -force.
+
+  -module=NAME        Only push the resources of the module NAME, and the
+                      modules nested within it, merging them into the
+                      destination state rather than replacing it. Nested
+                      modules are separated by dots, i.e. "foo.bar".
+
+  -normalize          Re-encode the state with Terraform's canonical
+                      serialization before pushing it, verifying that it
+                      parses fully. Lineage and serial are preserved.
+
+  -outputs-only       Only update the root module outputs of the destination
+                      state with those of the state to push, leaving
+                      everything else intact. The resources of both states
+                      must be identical.
+
+  -patch              PATH is a state patch containing only the resources to
+                      add or replace, by address, which are merged into the
+                      destination state rather than replacing it. The
+                      patched resources are listed.
+
+  -preserve=ADDR      Keep the resources matching ADDR in the destination
+                      state intact, rather than replacing them with those
+                      of the state to push. ADDR may be module qualified and
+                      may contain "*" wildcards. Unless -force is given, the
+                      state to push must not have any matching resources.
+                      This flag can be used multiple times.
+
+  -quiet              Don't output progress or informational messages.
+                      Errors are still output. Progress is shown as the
+                      time elapsed writing the state, and the percentage
+                      written for backends that report it, such as http.
+
+  -set-lineage=ID     Replace the lineage of the state with ID before
+                      pushing it. ID must be a lower case UUID. This is
+                      dangerous: the state will no longer be recognized as
+                      related to other copies of it. Only use this for
+                      deliberate recovery, such as after forking an
+                      environment.
+
+  -snapshot=NAME      After pushing, create a named, immutable snapshot of the
+                      destination state, for backends that support them, and
+                      output its identifier. Local states are snapshotted to
+                      a read-only copy named PATH.NAME.snapshot. A warning is
+                      output for backends without snapshots.
+
+  -state-dest=PATH    Write the state to the local state file at PATH
+                      instead of the configured backend. PATH may be given
+                      as a file:// URL. The same safety checks apply.
+
+  -strategy=NAME      Resolve a conflict between the lineages or serials of
+                      the states rather than blocking the push: "ours"
+                      pushes the state anyway, "theirs" keeps the
+                      destination state without pushing, and
+                      "newer-serial" keeps whichever has the higher serial.
+                      The strategy applied is reported. Can't be used with
+                      -force, -module or -outputs-only.
+
+  -upgrade            Upgrade the state to the current state format and
+                      Terraform version before pushing it, as the next
+                      apply would. The versions before and after are
+                      reported. States that are already current are
+                      unchanged.
+
+  -validate-refs      Check that the dependencies recorded for each resource
+                      in the state refer to resources and modules that are
+                      in it, refusing to push unless -force is given. Each
+                      dangling dependency is listed with its resource.
+
+  -verify-idempotent  After pushing, read the state back, push what was read,
+                      and read it back again, failing with a diff unless
+                      both reads are identical. This detects backends that
+                      reformat or reorder the state. On failure the
+                      destination is restored to its contents before the
+                      push. This is a diagnostic, not for routine use.
 
 `
 	return strings.TrimSpace(helpText)
@@ -152,6 +1388,74 @@ Please verify you're pushing the correct state. If you're sure you are, you
 can force the behavior with the "-force" flag.
 `
 
+const errStatePushMinVersion = `
+The source state was written by %s, but -min-version requires
+Terraform %s or later. The state will not be pushed.
+
+Refresh or apply with a recent enough version of Terraform to update the
+version recorded in the state, then push it again.
+`
+
+const errStatePushDanglingRefs = `
+The state has dependencies on resources or modules that aren't in it. The
+state will not be pushed.
+
+The dangling dependencies are:
+%s
+
+This usually means the state was edited by hand. Fix the dependencies or
+add the missing resources, or use the -force flag to push the state anyway.
+`
+
+const warnStatePushDanglingRefs = `
+Pushing the state with dependencies on resources or modules that aren't in
+it, because -force was given. The dangling dependencies are:
+%s
+`
+
+const errStatePushBreaksConsumers = `
+The state to push would break the consumers of its outputs. The state will
+not be pushed.
+
+The outputs the consumers reference that the state wouldn't provide are:
+%s
+
+Add the missing outputs to the state, or update the consumers first, or use
+the -force flag to push the state anyway.
+`
+
+const warnStatePushBreaksConsumers = `
+Pushing a state that breaks the consumers of its outputs, because -force was
+given. The outputs the consumers reference that the state doesn't provide
+are:
+%s
+`
+
+const warnStatePushSetLineage = `
+WARNING: The lineage of the state is being changed from %q to %q.
+
+The lineage identifies every copy of a state as related to every other. Once
+it's changed, Terraform will refuse to push this state over the copies with
+the original lineage, and will refuse to push those copies over this one,
+without "-force". Make sure no one else is still using the original lineage.
+`
+
+const warnStatePushSnapshotUnsupported = `
+WARNING: The state was pushed, but snapshot %q was not created because the
+backend doesn't support snapshots.
+`
+
+const errStatePushNotIdempotent = `
+The destination didn't store the state identically when it was pushed again!
+
+The state read back after the push was pushed a second time, and read back
+again. The two reads differ:
+
+%s
+This usually means the backend reformats or reorders the state when storing
+it, so the state can't be relied upon to be stored exactly as written.
+`
+
 const errStatePushSerialNewer = `
 The destination state has a higher serial number! The state will not be pushed.
 
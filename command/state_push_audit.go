@@ -0,0 +1,136 @@
+package command
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// StatePushAuditLogEnvVar is the name of the environment variable that sets
+// the default audit log of state push, which can be overridden by the
+// -audit-log flag.
+const StatePushAuditLogEnvVar = "TF_STATE_PUSH_AUDIT_LOG"
+
+// statePushOperatorEnvVars are the environment variables the operator
+// identity is resolved from, in order of preference.
+var statePushOperatorEnvVars = []string{"TF_AUDIT_OPERATOR", "USER", "USERNAME"}
+
+// statePushAudit is a record in the state push audit log. A record is
+// written for every push attempt, whether or not the state was pushed.
+type statePushAudit struct {
+	Time     time.Time `json:"time"`
+	Operator string    `json:"operator,omitempty"`
+	Source   string    `json:"source"`
+	Force    bool      `json:"force"`
+
+	// The source lineage and serial are those of the state as it was
+	// pushed, or as it was read if the push was blocked.
+	SourceLineage      string `json:"source_lineage,omitempty"`
+	SourceSerial       *int64 `json:"source_serial,omitempty"`
+	DestinationLineage string `json:"destination_lineage,omitempty"`
+	DestinationSerial  *int64 `json:"destination_serial,omitempty"`
+
+	// Result is "pushed" if the push succeeded, "kept" if -strategy kept
+	// the destination state without pushing, "rejected" if it was blocked
+	// before the state was written, or "failed" if it failed while or
+	// after the state was written. Error has the reasons it didn't succeed.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+
+	// written is true once writing the state was attempted
+	written bool
+}
+
+// newStatePushAudit returns the audit record for a push attempt of the
+// state at source.
+func newStatePushAudit(source string, force bool) *statePushAudit {
+	a := &statePushAudit{
+		Time:   time.Now().UTC(),
+		Source: source,
+		Force:  force,
+	}
+	for _, k := range statePushOperatorEnvVars {
+		if v := os.Getenv(k); v != "" {
+			a.Operator = v
+			break
+		}
+	}
+	return a
+}
+
+// setSource records the lineage and serial of the state to push.
+func (a *statePushAudit) setSource(s *terraform.State) {
+	if a == nil || s == nil {
+		return
+	}
+	serial := s.Serial
+	a.SourceLineage, a.SourceSerial = s.Lineage, &serial
+}
+
+// setDestination records the lineage and serial of the destination state
+// before the push.
+func (a *statePushAudit) setDestination(s *terraform.State) {
+	if a == nil || s == nil {
+		return
+	}
+	serial := s.Serial
+	a.DestinationLineage, a.DestinationSerial = s.Lineage, &serial
+}
+
+// setWritten records that writing the state was attempted, along with the
+// state that was written.
+func (a *statePushAudit) setWritten(s *terraform.State) {
+	if a == nil {
+		return
+	}
+	a.setSource(s)
+	a.written = true
+}
+
+// write appends the record as a single line of JSON to the audit log at
+// path, given the exit code of the command and the errors it output.
+func (a *statePushAudit) write(path string, code int, errs []string) error {
+	switch {
+	case code == 0 && !a.written:
+		a.Result = "kept"
+	case code == 0:
+		a.Result = "pushed"
+	case a.written:
+		a.Result = "failed"
+	default:
+		a.Result = "rejected"
+	}
+	a.Error = strings.TrimSpace(strings.Join(errs, "\n"))
+
+	js, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(js, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// statePushAuditUi is a cli.Ui that records the errors output, so they can
+// be included in the audit record.
+type statePushAuditUi struct {
+	cli.Ui
+
+	errors []string
+}
+
+func (u *statePushAuditUi) Error(msg string) {
+	u.errors = append(u.errors, msg)
+	u.Ui.Error(msg)
+}
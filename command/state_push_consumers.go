@@ -0,0 +1,335 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/backend"
+	backendlocal "github.com/hashicorp/terraform/backend/local"
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// stateConsumerNamePattern matches the name of a data source given after the
+// directory of a consumer, i.e. "../network:vpc".
+var stateConsumerNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// stateRemoteStateAttrs are the attributes of a terraform_remote_state data
+// source that aren't outputs of the state it reads.
+var stateRemoteStateAttrs = map[string]bool{
+	"id":                       true,
+	"backend":                  true,
+	"config":                   true,
+	"environment":              true,
+	"__has_dynamic_attributes": true,
+}
+
+// stateConsumer is a configuration that reads the pushed state with a
+// terraform_remote_state data source, given to state push with -consumer.
+type stateConsumer struct {
+	// Dir is the directory of the configuration, and Name the name of the
+	// data source that reads the pushed state. If Name is empty, it's the
+	// only terraform_remote_state data source of the configuration, or the
+	// only one configured with the backend the state is pushed to.
+	Dir  string
+	Name string
+}
+
+// stateLocation is the backend configuration of a state, as it's configured
+// for state push or in a terraform_remote_state data source. The state file
+// of a local backend is resolved to an absolute path, and is its only config.
+type stateLocation struct {
+	Backend string
+	Config  map[string]interface{}
+	Env     string
+}
+
+// stateBrokenRef is an output of the pushed state referenced by a consumer
+// that it would no longer provide as the consumer expects.
+type stateBrokenRef struct {
+	Consumer string
+	Ref      string
+	Reason   string
+}
+
+// parseStateConsumer parses a -consumer value, DIR or DIR:NAME.
+func parseStateConsumer(v string) (*stateConsumer, error) {
+	c := &stateConsumer{Dir: v}
+	if i := strings.LastIndex(v, ":"); i > 0 && stateConsumerNamePattern.MatchString(v[i+1:]) {
+		c.Dir, c.Name = v[:i], v[i+1:]
+	}
+	if c.Dir == "" {
+		return nil, fmt.Errorf("Invalid consumer %q: the directory is empty", v)
+	}
+	return c, nil
+}
+
+// newStateLocation returns the location of the state of env in a backend of
+// the given type and configuration, with the paths of a local backend
+// relative to dir.
+func newStateLocation(backendType string, cfg map[string]interface{}, env, dir string) (*stateLocation, error) {
+	if env == "" {
+		env = backend.DefaultStateName
+	}
+	if backendType == "_local" {
+		backendType = "local"
+	}
+	if backendType != "local" {
+		return &stateLocation{Backend: backendType, Config: cfg, Env: env}, nil
+	}
+
+	// the environment is part of the path of a local state, so it's left
+	// out of the location
+	path := DefaultStateFilename
+	if p, ok := cfg["path"].(string); ok && p != "" {
+		path = p
+	}
+	if env != backend.DefaultStateName {
+		envDir := backendlocal.DefaultEnvDir
+		if p, ok := cfg["environment_dir"].(string); ok && p != "" {
+			envDir = p
+		}
+		path = filepath.Join(envDir, env, DefaultStateFilename)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stateLocation{
+		Backend: "local",
+		Config:  map[string]interface{}{"path": path},
+	}, nil
+}
+
+// stateBackendLocation returns the location of the state of env in the
+// backend initialized in the data directory dataDir, or of the default local
+// state if there's none.
+func stateBackendLocation(dataDir, env string) (*stateLocation, error) {
+	sMgr := &state.LocalState{Path: filepath.Join(dataDir, DefaultStateFilename)}
+	if err := sMgr.RefreshState(); err != nil {
+		return nil, err
+	}
+
+	if s := sMgr.State(); s != nil && !s.Backend.Empty() {
+		return newStateLocation(s.Backend.Type, s.Backend.Config, env, ".")
+	}
+	return newStateLocation("local", nil, env, ".")
+}
+
+// matches returns whether l and other are configured alike, so they're the
+// same state. Only the settings both have are compared, since a reader may
+// well use different credentials than the writer, but they must have at least
+// one in common unless neither has any.
+func (l *stateLocation) matches(other *stateLocation) bool {
+	if l.Backend != other.Backend || l.Env != other.Env {
+		return false
+	}
+
+	shared := 0
+	for k, v := range l.Config {
+		ov, ok := other.Config[k]
+		if !ok {
+			continue
+		}
+		if fmt.Sprint(v) != fmt.Sprint(ov) {
+			return false
+		}
+		shared++
+	}
+	return shared > 0 || (len(l.Config) == 0 && len(other.Config) == 0)
+}
+
+// remoteStateLocation returns the location of the state read by a
+// terraform_remote_state data source of the consumer, or nil if it isn't
+// known before the data source is read, because it's interpolated.
+func (c *stateConsumer) remoteStateLocation(r *config.Resource) (*stateLocation, error) {
+	raw := r.RawConfig
+	if raw == nil || len(raw.Variables) > 0 {
+		return nil, nil
+	}
+
+	backendType, _ := raw.Raw["backend"].(string)
+	env, _ := raw.Raw["environment"].(string)
+
+	// a config block is decoded as a list of maps
+	cfg := make(map[string]interface{})
+	switch v := raw.Raw["config"].(type) {
+	case map[string]interface{}:
+		cfg = v
+	case []map[string]interface{}:
+		for _, m := range v {
+			for k, v := range m {
+				cfg[k] = v
+			}
+		}
+	}
+
+	return newStateLocation(backendType, cfg, env, c.Dir)
+}
+
+// source returns the name of the terraform_remote_state data source of the
+// consumer that reads the pushed state: the one the consumer was given with,
+// the only one there is, or the only one configured with the backend the
+// state is pushed to, dest.
+func (c *stateConsumer) source(cfg *config.Config, dest *stateLocation) (string, error) {
+	var sources []*config.Resource
+	for _, r := range cfg.Resources {
+		if r.Mode == config.DataResourceMode && r.Type == "terraform_remote_state" {
+			sources = append(sources, r)
+		}
+	}
+
+	if c.Name != "" {
+		for _, r := range sources {
+			if r.Name == c.Name {
+				return c.Name, nil
+			}
+		}
+		return "", fmt.Errorf(
+			"Consumer %q has no data source data.terraform_remote_state.%s",
+			c.Dir, c.Name)
+	}
+
+	switch len(sources) {
+	case 0:
+		return "", fmt.Errorf(
+			"Consumer %q has no terraform_remote_state data sources", c.Dir)
+	case 1:
+		return sources[0].Name, nil
+	}
+
+	var matches []string
+	for _, r := range sources {
+		l, err := c.remoteStateLocation(r)
+		if err != nil {
+			return "", fmt.Errorf(
+				"Error reading data.terraform_remote_state.%s of consumer %q: %s",
+				r.Name, c.Dir, err)
+		}
+		if l != nil && dest != nil && l.matches(dest) {
+			matches = append(matches, r.Name)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	return "", fmt.Errorf(
+		"Consumer %q has %d terraform_remote_state data sources, and %d of them "+
+			"are configured with the backend the state is pushed to. Use "+
+			"-consumer=%s:NAME to choose the one that reads the pushed state.",
+		c.Dir, len(sources), len(matches), c.Dir)
+}
+
+// refs returns the outputs of the pushed state referenced by the root module
+// of the consumer's configuration, by the data source references to them,
+// i.e. "data.terraform_remote_state.vpc.subnet_id" for "subnet_id". The data
+// source that reads the pushed state is chosen by source.
+func (c *stateConsumer) refs(dest *stateLocation) (map[string]string, error) {
+	cfg, err := config.LoadDir(c.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading consumer %q: %s", c.Dir, err)
+	}
+
+	name, err := c.source(cfg, dest)
+	if err != nil {
+		return nil, err
+	}
+
+	var raws []*config.RawConfig
+	for _, r := range cfg.Resources {
+		raws = append(raws, r.RawCount, r.RawConfig)
+		for _, p := range r.Provisioners {
+			raws = append(raws, p.RawConfig, p.ConnInfo)
+		}
+	}
+	for _, m := range cfg.Modules {
+		raws = append(raws, m.RawConfig)
+	}
+	for _, p := range cfg.ProviderConfigs {
+		raws = append(raws, p.RawConfig)
+	}
+	for _, o := range cfg.Outputs {
+		raws = append(raws, o.RawConfig)
+	}
+
+	result := make(map[string]string)
+	for _, raw := range raws {
+		if raw == nil {
+			continue
+		}
+		for _, v := range raw.Variables {
+			rv, ok := v.(*config.ResourceVariable)
+			if !ok || rv.Mode != config.DataResourceMode ||
+				rv.Type != "terraform_remote_state" || rv.Name != name {
+				continue
+			}
+
+			// lists and maps are referenced by their elements, i.e.
+			// "subnet_ids.0", which are provided with the output
+			output := strings.SplitN(rv.Field, ".", 2)[0]
+			if output == "" || stateRemoteStateAttrs[output] {
+				continue
+			}
+			result[fmt.Sprintf("data.terraform_remote_state.%s.%s", rv.Name, output)] = output
+		}
+	}
+
+	return result, nil
+}
+
+// stateBrokenConsumerRefs returns the references of the consumers to outputs
+// that s doesn't provide, or whose type differs from that of the output in
+// the destination state dst, pushed to dest, sorted by consumer and
+// reference.
+func stateBrokenConsumerRefs(s, dst *terraform.State, dest *stateLocation, consumers []*stateConsumer) ([]stateBrokenRef, error) {
+	var outputs, prior map[string]*terraform.OutputState
+	if m := s.ModuleByPath(terraform.RootModulePath); m != nil {
+		outputs = m.Outputs
+	}
+	if m := dst.ModuleByPath(terraform.RootModulePath); m != nil {
+		prior = m.Outputs
+	}
+
+	var broken []stateBrokenRef
+	for _, c := range consumers {
+		refs, err := c.refs(dest)
+		if err != nil {
+			return nil, err
+		}
+
+		for ref, name := range refs {
+			o, ok := outputs[name]
+			reason := ""
+			switch {
+			case !ok || o == nil:
+				reason = fmt.Sprintf("output %q is missing", name)
+			case prior[name] != nil && prior[name].Type != o.Type:
+				reason = fmt.Sprintf(
+					"output %q changes from a %s to a %s", name, prior[name].Type, o.Type)
+			default:
+				continue
+			}
+			broken = append(broken, stateBrokenRef{
+				Consumer: c.Dir,
+				Ref:      ref,
+				Reason:   reason,
+			})
+		}
+	}
+
+	sort.Slice(broken, func(i, j int) bool {
+		if broken[i].Consumer != broken[j].Consumer {
+			return broken[i].Consumer < broken[j].Consumer
+		}
+		return broken[i].Ref < broken[j].Ref
+	})
+	return broken, nil
+}
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// stateEncryptionPeek is how much of a state is inspected to tell whether
+// it's encrypted.
+const stateEncryptionPeek = 512
+
+// errStateEncrypted is returned when the state to push looks encrypted.
+// None of the backends encrypt states on the client, so Terraform has no
+// way to decrypt one.
+var errStateEncrypted = errors.New(
+	"The state looks encrypted. Terraform can't read client-side encrypted\n" +
+		"states, so decrypt it with the tool that encrypted it before pushing it.")
+
+// stateLooksEncrypted returns whether head, the start of a serialized state,
+// looks encrypted: it's binary data that isn't the JSON of a state, or the
+// magic bytes of the legacy binary format, so it's likely raw ciphertext.
+func stateLooksEncrypted(head []byte) bool {
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] == '{' || bytes.HasPrefix(trimmed, []byte("tfstate")) {
+		return false
+	}
+	for _, b := range trimmed {
+		if b < 0x20 && b != '\t' && b != '\r' && b != '\n' || b == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// stateCheckEncrypted returns a reader of the source state read from r, or
+// errStateEncrypted if it looks encrypted.
+func stateCheckEncrypted(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(stateEncryptionPeek)
+	if stateLooksEncrypted(head) {
+		return nil, errStateEncrypted
+	}
+	return br, nil
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// statePatch is a sparse state, read by "state push -patch", containing only
+// the resources to update in the destination state.
+type statePatch struct {
+	// Lineage, if set, is the lineage of the state the patch applies to.
+	Lineage string `json:"lineage,omitempty"`
+
+	// Serial, if set, is the serial of the destination state the patch was
+	// made from. Resources that already exist in the destination can only be
+	// updated without -force if the destination hasn't been written since.
+	Serial *int64 `json:"serial,omitempty"`
+
+	// Resources are the resources to add or replace, by their address in the
+	// state, i.e. "module.child.aws_instance.web.1".
+	Resources map[string]*terraform.ResourceState `json:"resources"`
+}
+
+// statePatchFields are the top-level fields of a state patch. Anything else,
+// such as the modules of a full state given by mistake, is rejected.
+var statePatchFields = map[string]bool{
+	"lineage":   true,
+	"serial":    true,
+	"resources": true,
+}
+
+// readStatePatch reads a sparse state from r.
+func readStatePatch(r io.Reader) (*statePatch, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Reading state patch failed: %s", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("Decoding state patch failed: %s", err)
+	}
+	var unknown []string
+	for k := range raw {
+		if !statePatchFields[k] {
+			unknown = append(unknown, fmt.Sprintf("%q", k))
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf(
+			"Decoding state patch failed: unknown fields %s", strings.Join(unknown, ", "))
+	}
+
+	var p statePatch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("Decoding state patch failed: %s", err)
+	}
+	if len(p.Resources) == 0 {
+		return nil, fmt.Errorf("The state patch doesn't contain any resources.")
+	}
+
+	for addr, r := range p.Resources {
+		if r == nil {
+			return nil, fmt.Errorf("Resource %s in the state patch is empty.", addr)
+		}
+		if _, _, err := statePatchAddr(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// statePatchAddr splits the address of a resource in a state patch into the
+// path of its module and its key within the module's resources.
+func statePatchAddr(addr string) ([]string, string, error) {
+	path := []string{"root"}
+	parts := strings.Split(addr, ".")
+	for len(parts) >= 2 && parts[0] == "module" {
+		path = append(path, parts[1])
+		parts = parts[2:]
+	}
+
+	n := 2
+	if len(parts) > 0 && parts[0] == "data" {
+		n = 3
+	}
+	if len(parts) < n || len(parts) > n+1 {
+		return nil, "", fmt.Errorf("Invalid resource address %q in the state patch.", addr)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return nil, "", fmt.Errorf("Invalid resource address %q in the state patch.", addr)
+		}
+	}
+
+	return path, strings.Join(parts, "."), nil
+}
+
+// stateApplyPatch adds the resources of the patch p to dst, replacing any
+// existing resources with the same addresses, and returns the addresses
+// of the resources that were patched, sorted, prefixed with "+" if they were
+// added or "~" if they were replaced. Resources identical to those in dst
+// are left out.
+//
+// Replacing a resource is a conflict, unless the patch was made from the
+// destination at its current serial, and the lineages of the patch and dst
+// must match. Conflicts are errors unless force is set.
+func stateApplyPatch(dst *terraform.State, p *statePatch, force bool) ([]string, error) {
+	if !force && p.Lineage != "" && dst.Lineage != "" && p.Lineage != dst.Lineage {
+		return nil, fmt.Errorf(
+			"The lineage of the state patch, %q, doesn't match the lineage of "+
+				"the destination state, %q.\n\nUse -force to apply it anyway.",
+			p.Lineage, dst.Lineage)
+	}
+	current := p.Serial != nil && *p.Serial == dst.Serial
+
+	var patched, conflicts []string
+	for addr, r := range p.Resources {
+		path, key, err := statePatchAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		var existing *terraform.ResourceState
+		if m := dst.ModuleByPath(path); m != nil {
+			existing = m.Resources[key]
+		}
+		switch {
+		case existing == nil:
+			patched = append(patched, "+ "+addr)
+		case existing.Equal(r):
+		default:
+			patched = append(patched, "~ "+addr)
+			if !current {
+				conflicts = append(conflicts, addr)
+			}
+		}
+	}
+
+	if len(conflicts) > 0 && !force {
+		sort.Strings(conflicts)
+		base := "an unknown serial"
+		if p.Serial != nil {
+			base = fmt.Sprintf("serial %d", *p.Serial)
+		}
+		return nil, fmt.Errorf(
+			"The state patch was made from %s, but the destination state is at "+
+				"serial %d, so the following resources may have changed since:\n\n"+
+				"  %s\n\nUse -force to replace them.",
+			base, dst.Serial, strings.Join(conflicts, "\n  "))
+	}
+
+	for addr, r := range p.Resources {
+		path, key, _ := statePatchAddr(addr)
+		m := dst.ModuleByPath(path)
+		if m == nil {
+			m = dst.AddModule(path)
+		}
+		m.Resources[key] = r
+	}
+
+	sort.Slice(patched, func(i, j int) bool {
+		return patched[i][2:] < patched[j][2:]
+	})
+	return patched, nil
+}
@@ -0,0 +1,108 @@
+package command
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// stateDanglingRef is a dependency recorded for a resource in a state on a
+// resource or module that isn't in the state.
+type stateDanglingRef struct {
+	// Resource is the address of the resource with the dependency, and
+	// Dependency the dependency as recorded, relative to its module.
+	Resource   string
+	Dependency string
+}
+
+// stateDanglingRefs returns the dependencies of the resources in s on
+// resources or modules that aren't in s, sorted by resource. Dependencies on
+// a resource are satisfied by any of its instances, or by the instance with
+// the given index, such as "aws_instance.web.1", and dependencies on a module
+// by the module's state.
+func stateDanglingRefs(s *terraform.State) []stateDanglingRef {
+	if s == nil {
+		return nil
+	}
+
+	var result []stateDanglingRef
+	for _, m := range s.Modules {
+		prefix := ""
+		for _, name := range m.Path[1:] {
+			prefix += "module." + name + "."
+		}
+
+		for k, rs := range m.Resources {
+			if rs == nil {
+				continue
+			}
+			for _, dep := range rs.Dependencies {
+				if stateRefResolves(s, m, dep) {
+					continue
+				}
+				result = append(result, stateDanglingRef{
+					Resource:   prefix + k,
+					Dependency: dep,
+				})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Resource != result[j].Resource {
+			return result[i].Resource < result[j].Resource
+		}
+		return result[i].Dependency < result[j].Dependency
+	})
+	return result
+}
+
+// stateRefResolves returns whether the dependency dep of a resource in the
+// module m resolves to a resource or module in s.
+func stateRefResolves(s *terraform.State, m *terraform.ModuleState, dep string) bool {
+	parts := strings.Split(strings.TrimSuffix(dep, ".*"), ".")
+
+	// a dependency on a module, i.e. "module.foo"
+	if parts[0] == "module" {
+		if len(parts) < 2 {
+			return false
+		}
+		path := make([]string, len(m.Path), len(m.Path)+1)
+		copy(path, m.Path)
+		return s.ModuleByPath(append(path, parts[1])) != nil
+	}
+
+	// a dependency on a resource, i.e. "aws_instance.foo" or
+	// "data.aws_ami.ubuntu", optionally followed by an index
+	n := 2
+	if parts[0] == "data" {
+		n = 3
+	}
+	if len(parts) < n {
+		return false
+	}
+	key := strings.Join(parts[:n], ".")
+
+	if len(parts) > n {
+		index := parts[n]
+		if _, ok := m.Resources[key+"."+index]; ok {
+			return true
+		}
+		_, ok := m.Resources[key]
+		return ok && index == "0"
+	}
+
+	for k := range m.Resources {
+		if k == key {
+			return true
+		}
+		if strings.HasPrefix(k, key+".") {
+			if _, err := strconv.Atoi(k[len(key)+1:]); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// The strategies of -strategy, which resolve a conflict between the lineages
+// or serials of the source and destination states.
+const (
+	// statePushStrategyOurs pushes the source state anyway.
+	statePushStrategyOurs = "ours"
+
+	// statePushStrategyTheirs keeps the destination state, without pushing.
+	statePushStrategyTheirs = "theirs"
+
+	// statePushStrategyNewerSerial keeps whichever state has the higher
+	// serial, and the destination state if the serials are equal.
+	statePushStrategyNewerSerial = "newer-serial"
+)
+
+// validateStatePushStrategy returns an error if strategy isn't a valid
+// -strategy. The empty strategy blocks the push on a conflict.
+func validateStatePushStrategy(strategy string) error {
+	switch strategy {
+	case "", statePushStrategyOurs, statePushStrategyTheirs, statePushStrategyNewerSerial:
+		return nil
+	}
+	return fmt.Errorf(
+		"Invalid -strategy %q, must be one of %q, %q or %q",
+		strategy, statePushStrategyOurs, statePushStrategyTheirs,
+		statePushStrategyNewerSerial)
+}
+
+// statePushResolve returns whether src should be pushed over dst despite a
+// conflict, according to strategy.
+func statePushResolve(strategy string, src, dst *terraform.State) bool {
+	switch strategy {
+	case statePushStrategyOurs:
+		return true
+	case statePushStrategyNewerSerial:
+		return src.Serial > dst.Serial
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package command
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// statePushStream writes the progress events of a push as NDJSON, a single
+// line of JSON per event, as they happen. Nothing is written by a nil
+// stream.
+type statePushStream struct {
+	w io.Writer
+}
+
+// statePushEvent is a progress event of a push. Stage is one of:
+//
+//	source_read       the source state was read
+//	destination_read  the destination state was read
+//	checked           the safety checks passed, or didn't apply
+//	backup_written    a backup of the destination state was written
+//	state_written     the state was written to the destination
+//	persisted         the destination persisted the state
+//	verified          the state was pushed again identically
+//	snapshot_created  the snapshot named by -snapshot was created
+//	done              the push succeeded
+//	failed            the push failed or was blocked
+type statePushEvent struct {
+	Time  time.Time `json:"time"`
+	Stage string    `json:"stage"`
+
+	// Lineage and Serial are those of the state read or written, Path the
+	// path of the backup, and Snapshot the identifier of the snapshot.
+	Lineage  string `json:"lineage,omitempty"`
+	Serial   *int64 `json:"serial,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// emit writes the event for stage. Each event is written with a single
+// write, so it isn't held back until the push ends.
+func (s *statePushStream) emit(e statePushEvent) {
+	if s == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+
+	js, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.w.Write(append(js, '\n'))
+}
+
+// stage emits the event for a stage without details.
+func (s *statePushStream) stage(stage string) {
+	s.emit(statePushEvent{Stage: stage})
+}
+
+// stageState emits the event for a stage along with the lineage and serial
+// of st.
+func (s *statePushStream) stageState(stage string, st *terraform.State) {
+	e := statePushEvent{Stage: stage}
+	if st != nil {
+		serial := st.Serial
+		e.Lineage, e.Serial = st.Lineage, &serial
+	}
+	s.emit(e)
+}
+
+// end emits the final event of the push, given the exit code of the command
+// and the errors it output.
+func (s *statePushStream) end(code int, errs []string) {
+	if code == 0 {
+		s.stage("done")
+		return
+	}
+	s.emit(statePushEvent{
+		Stage: "failed",
+		Error: strings.TrimSpace(strings.Join(errs, "\n")),
+	})
+}
+
+// stateBackupPath returns the path mgr writes a backup of the destination
+// state to when the state is first written, or "" if it doesn't.
+func stateBackupPath(mgr state.State) string {
+	inner := mgr
+	for {
+		switch s := inner.(type) {
+		case *state.BackupState:
+			return s.Path
+		case *state.LockDisabled:
+			inner = s.Inner
+			continue
+		}
+		return ""
+	}
+}
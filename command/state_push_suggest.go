@@ -0,0 +1,111 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// statePushSuggestLimit is the maximum number of resource addresses listed
+// in a suggestion.
+const statePushSuggestLimit = 5
+
+// statePushBlocked returns the error msg for a push blocked by the safety
+// checks, followed by the next steps suggested by the lineages, serials and
+// resources of the source and destination states.
+func statePushBlocked(msg string, src, dst *terraform.State) string {
+	var buf bytes.Buffer
+	buf.WriteString(strings.TrimSpace(msg))
+	buf.WriteString("\n\nSuggested next steps:\n")
+	for _, s := range statePushSuggestions(src, dst) {
+		fmt.Fprintf(&buf, "\n  - %s", s)
+	}
+	return buf.String()
+}
+
+// statePushSuggestions returns the next steps to suggest when pushing src
+// over dst is blocked, either because the lineages don't match or because
+// the destination serial is higher.
+func statePushSuggestions(src, dst *terraform.State) []string {
+	var result []string
+
+	if !dst.SameLineage(src) {
+		result = append(result, fmt.Sprintf(
+			"The source state has lineage %q and the destination state has "+
+				"lineage %q, so they weren't copied from the same state. Check "+
+				"that the source file and the backend configuration are the "+
+				"ones you intended.",
+			src.Lineage, dst.Lineage))
+	} else if gap := dst.Serial - src.Serial; gap > 0 {
+		changes := "one change was written to it"
+		if gap > 1 {
+			changes = fmt.Sprintf("%d changes were written to it, possibly by concurrent runs,", gap)
+		}
+		result = append(result, fmt.Sprintf(
+			"The destination serial is %d higher than the source (%d, not %d), "+
+				"suggesting %s since the source state was copied.",
+			gap, dst.Serial, src.Serial, changes))
+	}
+
+	if lost := statePushLostResources(src, dst); len(lost) > 0 {
+		listed := lost
+		if len(listed) > statePushSuggestLimit {
+			listed = listed[:statePushSuggestLimit]
+		}
+		more := ""
+		if n := len(lost) - len(listed); n > 0 {
+			more = fmt.Sprintf(", and %d more", n)
+		}
+		result = append(result, fmt.Sprintf(
+			"%d resource(s) of the destination state aren't in the source "+
+				"state, and would be lost by pushing it: %s%s.",
+			len(lost), strings.Join(listed, ", "), more))
+	}
+
+	result = append(result,
+		fmt.Sprintf(
+			"Run \"terraform state pull\" to inspect the destination state "+
+				"(serial %d) and compare it with the source state.", dst.Serial),
+		"If replacing the destination state is intentional, run this command "+
+			"again with -force. To keep the destination's copies of specific "+
+			"resources, add -preserve for each of them.")
+
+	return result
+}
+
+// statePushLostResources returns the sorted addresses of the resources of
+// dst that aren't in src.
+func statePushLostResources(src, dst *terraform.State) []string {
+	srcAddrs := statePushResourceAddrs(src)
+
+	var result []string
+	for addr := range statePushResourceAddrs(dst) {
+		if !srcAddrs[addr] {
+			result = append(result, addr)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// statePushResourceAddrs returns the set of the resource addresses of s.
+func statePushResourceAddrs(s *terraform.State) map[string]bool {
+	result := make(map[string]bool)
+
+	filter := &terraform.StateFilter{State: s}
+	results, err := filter.Filter()
+	if err != nil {
+		return result
+	}
+	for _, r := range results {
+		if _, ok := r.Value.(*terraform.ResourceState); ok {
+			result[r.Address] = true
+		}
+	}
+
+	return result
+}
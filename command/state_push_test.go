@@ -2,10 +2,24 @@ package command
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform/backend/remote-state/inmem"
 	"github.com/hashicorp/terraform/helper/copy"
+	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/state/remote"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
@@ -104,6 +118,159 @@ func TestStatePush_replaceMatchStdin(t *testing.T) {
 	}
 }
 
+func TestStatePush_replaceMatchURL(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-replace-match"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "replace.tfstate")
+
+	// Serve the replacement state over HTTP
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		terraform.WriteState(expected, w)
+	}))
+	defer ts.Close()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{ts.URL + "/replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_badURLScheme(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-replace-match"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"ftp://example.com/replace.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "Unsupported source URL scheme") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_strategy(t *testing.T) {
+	cases := []struct {
+		Fixture  string
+		Strategy string
+		Pushed   bool
+	}{
+		{"state-push-serial-newer", "ours", true},
+		{"state-push-serial-newer", "theirs", false},
+		{"state-push-serial-newer", "newer-serial", false},
+		{"state-push-bad-lineage", "ours", true},
+		{"state-push-bad-lineage", "theirs", false},
+		{"state-push-bad-lineage", "newer-serial", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Fixture+"-"+tc.Strategy, func(t *testing.T) {
+			// Create a temporary working directory that is empty
+			td := tempDir(t)
+			copy.CopyDir(testFixturePath(tc.Fixture), td)
+			defer os.RemoveAll(td)
+			defer testChdir(t, td)()
+
+			src := testStateRead(t, "replace.tfstate")
+			dst := testStateRead(t, "local-state.tfstate")
+
+			p := testProvider()
+			ui := new(cli.MockUi)
+			c := &StatePushCommand{
+				Meta: Meta{
+					ContextOpts: testCtxConfig(p),
+					Ui:          ui,
+				},
+			}
+
+			args := []string{"-strategy=" + tc.Strategy, "replace.tfstate"}
+			if code := c.Run(args); code != 0 {
+				t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+			}
+
+			actual := testStateRead(t, "local-state.tfstate")
+			output := ui.OutputWriter.String()
+			if tc.Pushed {
+				if actual.Lineage != src.Lineage || actual.Serial < src.Serial {
+					t.Fatalf("expected the source state to be pushed, got: %#v", actual)
+				}
+				if !strings.Contains(output, "pushing the source state") ||
+					!strings.Contains(output, fmt.Sprintf("now has lineage %q", src.Lineage)) {
+					t.Fatalf("bad output:\n%s", output)
+				}
+			} else {
+				if !actual.Equal(dst) {
+					t.Fatalf("expected the destination state to be kept, got: %#v", actual)
+				}
+				if !strings.Contains(output, "keeping the destination state") {
+					t.Fatalf("bad output:\n%s", output)
+				}
+			}
+		})
+	}
+}
+
+func TestStatePush_strategyInvalid(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-serial-newer"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	for _, args := range [][]string{
+		{"-strategy=mine", "replace.tfstate"},
+		{"-strategy=ours", "-force", "replace.tfstate"},
+	} {
+		p := testProvider()
+		ui := new(cli.MockUi)
+		c := &StatePushCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(p),
+				Ui:          ui,
+			},
+		}
+		if code := c.Run(args); code != 1 {
+			t.Fatalf("%q: bad: %d\n\n%s", args, code, ui.OutputWriter.String())
+		}
+	}
+}
+
 func TestStatePush_lineageMismatch(t *testing.T) {
 	// Create a temporary working directory that is empty
 	td := tempDir(t)
@@ -126,6 +293,9 @@ func TestStatePush_lineageMismatch(t *testing.T) {
 	if code := c.Run(args); code != 1 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
+	if errOut := ui.ErrorWriter.String(); !strings.Contains(errOut, `lineage "hello" and the destination state has lineage "mismatch"`) {
+		t.Fatalf("expected the lineages in the suggestions, got:\n%s", errOut)
+	}
 
 	actual := testStateRead(t, "local-state.tfstate")
 	if !actual.Equal(expected) {
@@ -155,6 +325,11 @@ func TestStatePush_serialNewer(t *testing.T) {
 	if code := c.Run(args); code != 1 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
+	// reading the fixtures increments their serials, since they aren't in
+	// the canonical format
+	if errOut := ui.ErrorWriter.String(); !strings.Contains(errOut, "serial is 1 higher than the source (4, not 3)") {
+		t.Fatalf("expected the serial gap in the suggestions, got:\n%s", errOut)
+	}
 
 	actual := testStateRead(t, "local-state.tfstate")
 	if !actual.Equal(expected) {
@@ -162,6 +337,50 @@ func TestStatePush_serialNewer(t *testing.T) {
 	}
 }
 
+func TestStatePushSuggestions(t *testing.T) {
+	dst := &terraform.State{
+		Lineage: "hello",
+		Serial:  7,
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_instance.kept":    &terraform.ResourceState{Type: "aws_instance", Primary: &terraform.InstanceState{ID: "kept"}},
+					"aws_instance.lost":    &terraform.ResourceState{Type: "aws_instance", Primary: &terraform.InstanceState{ID: "lost"}},
+					"aws_instance.other":   &terraform.ResourceState{Type: "aws_instance", Primary: &terraform.InstanceState{ID: "lost-1"}},
+					"aws_instance.removed": &terraform.ResourceState{Type: "aws_instance", Primary: &terraform.InstanceState{ID: "removed"}},
+				},
+			},
+		},
+	}
+	src := dst.DeepCopy()
+	src.Serial = 4
+	delete(src.RootModule().Resources, "aws_instance.lost")
+	delete(src.RootModule().Resources, "aws_instance.other")
+
+	actual := statePushSuggestions(src, dst)
+	if len(actual) != 4 {
+		t.Fatalf("expected 4 suggestions, got:\n%s", strings.Join(actual, "\n"))
+	}
+	if !strings.Contains(actual[0], "3 higher than the source (7, not 4), suggesting 3 changes") {
+		t.Fatalf("bad serial suggestion: %s", actual[0])
+	}
+	if !strings.Contains(actual[1], "2 resource(s)") || !strings.Contains(actual[1], ": aws_instance.lost, aws_instance.other.") {
+		t.Fatalf("bad lost resources suggestion: %s", actual[1])
+	}
+
+	// nothing to lose, and a different lineage
+	src = dst.DeepCopy()
+	src.Lineage = "other"
+	actual = statePushSuggestions(src, dst)
+	if len(actual) != 3 {
+		t.Fatalf("expected 3 suggestions, got:\n%s", strings.Join(actual, "\n"))
+	}
+	if !strings.Contains(actual[0], `lineage "other" and the destination state has lineage "hello"`) {
+		t.Fatalf("bad lineage suggestion: %s", actual[0])
+	}
+}
+
 func TestStatePush_serialOlder(t *testing.T) {
 	// Create a temporary working directory that is empty
 	td := tempDir(t)
@@ -190,3 +409,1649 @@ func TestStatePush_serialOlder(t *testing.T) {
 		t.Fatalf("bad: %#v", actual)
 	}
 }
+
+func TestStatePush_exclude(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-exclude"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-exclude", "test_instance.bar",
+		"-exclude", "module.*.test_instance.foo",
+		"replace.tfstate",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Excluded 2 resource(s)") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if actual.RootModule().Resources["test_instance.foo"] == nil {
+		t.Fatalf("test_instance.foo should remain: %s", actual)
+	}
+	if actual.RootModule().Resources["test_instance.bar"] != nil {
+		t.Fatalf("test_instance.bar should be excluded: %s", actual)
+	}
+	child := actual.ModuleByPath([]string{"root", "child"})
+	if child == nil || child.Resources["test_instance.baz"] == nil {
+		t.Fatalf("module.child.test_instance.baz should remain: %s", actual)
+	}
+	if child.Resources["test_instance.foo"] != nil {
+		t.Fatalf("module.child.test_instance.foo should be excluded: %s", actual)
+	}
+}
+
+func TestStatePush_preserve(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-preserve"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-preserve", "module.shared.*", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Preserved 1 resource(s) of the destination state:\n\n  module.shared.test_instance.db") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if r := actual.RootModule().Resources["test_instance.foo"]; r == nil || r.Primary.ID != "src-foo" {
+		t.Fatalf("test_instance.foo should be pushed: %s", actual)
+	}
+	if actual.RootModule().Resources["test_instance.bar"] == nil {
+		t.Fatalf("test_instance.bar should be pushed: %s", actual)
+	}
+	shared := actual.ModuleByPath([]string{"root", "shared"})
+	if shared == nil || shared.Resources["test_instance.db"] == nil {
+		t.Fatalf("module.shared.test_instance.db should be preserved: %s", actual)
+	}
+}
+
+func TestStatePush_preserveInSource(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-preserve"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-preserve", "test_instance.foo", "replace.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "also in the source state:\n\n  test_instance.foo") {
+		t.Fatalf("bad error: %s", ui.ErrorWriter.String())
+	}
+	if actual := testStateRead(t, "local-state.tfstate"); !actual.Equal(expected) {
+		t.Fatalf("the destination should be unchanged: %s", actual)
+	}
+
+	// with -force the destination's resource is kept
+	ui = new(cli.MockUi)
+	c = &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{"-force", "-preserve", "test_instance.foo", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if r := actual.RootModule().Resources["test_instance.foo"]; r == nil || r.Primary.ID != "dst-foo" {
+		t.Fatalf("test_instance.foo should be preserved: %s", actual)
+	}
+	if actual.RootModule().Resources["test_instance.bar"] == nil {
+		t.Fatalf("test_instance.bar should be pushed: %s", actual)
+	}
+	if actual.ModuleByPath([]string{"root", "shared"}) != nil {
+		t.Fatalf("module.shared should be replaced: %s", actual)
+	}
+}
+
+func TestStatePush_stateDest(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-serial-older"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "replace.tfstate")
+	backendState := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-state-dest", "file://dest.tfstate", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "dest.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// the backend state is untouched
+	actual = testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(backendState) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_normalize(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-normalize"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-normalize", "-state-dest", "dest.tfstate", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// reading a state that isn't canonical increments the serial, which
+	// normalization undoes
+	expected := testStateRead(t, "replace.tfstate")
+	expected.Serial = 4
+	var buf bytes.Buffer
+	if err := terraform.WriteState(expected, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ioutil.ReadFile("dest.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, buf.Bytes()) {
+		t.Fatalf("state not normalized:\n%s", actual)
+	}
+
+	actualState := testStateRead(t, "dest.tfstate")
+	if actualState.Lineage != "hello" || actualState.Serial != 4 {
+		t.Fatalf("lineage or serial not preserved: %q, %d",
+			actualState.Lineage, actualState.Serial)
+	}
+}
+
+func TestStatePush_module(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-module"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-module", "child", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if actual.Lineage != "destination" {
+		t.Fatalf("destination lineage not preserved: %q", actual.Lineage)
+	}
+
+	// the root module is untouched
+	root := actual.RootModule()
+	if id := root.Resources["test_instance.foo"].Primary.ID; id != "dest-foo" {
+		t.Fatalf("root module was modified: %s", id)
+	}
+
+	// the module's resources, including nested modules, were merged
+	child := actual.ModuleByPath([]string{"root", "child"})
+	if len(child.Resources) != 2 || child.Resources["test_instance.new"] == nil {
+		t.Fatalf("bad child module: %s", child)
+	}
+	nested := actual.ModuleByPath([]string{"root", "child", "grandchild"})
+	if nested == nil || nested.Resources["test_instance.nested"] == nil {
+		t.Fatalf("nested module not merged:\n%s", actual)
+	}
+}
+
+func TestStatePush_moduleCollision(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-module"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-module", "child", "collide.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "module.child.test_instance.existing") {
+		t.Fatalf("collision not reported:\n%s", ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// replacing the existing resource requires -force
+	ui = new(cli.MockUi)
+	c.Meta.Ui = ui
+	args = []string{"-module", "child", "-force", "collide.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual = testStateRead(t, "local-state.tfstate")
+	child := actual.ModuleByPath([]string{"root", "child"})
+	if id := child.Resources["test_instance.existing"].Primary.ID; id != "source-new" {
+		t.Fatalf("existing resource not replaced: %s", id)
+	}
+}
+
+func TestStatePush_moduleNotFound(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-module"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-module", "missing", "replace.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+}
+
+func TestStatePush_stateDestSerialNewer(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-serial-newer"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-state-dest", "local-state.tfstate", "replace.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_quiet(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-exclude"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-quiet", "-exclude", "test_instance.foo", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if ui.OutputWriter != nil && ui.OutputWriter.Len() > 0 {
+		t.Fatalf("expected no output, got:\n%s", ui.OutputWriter.String())
+	}
+}
+
+func TestStatePush_progress(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+		progressInterval: 10 * time.Millisecond,
+	}
+
+	stop := c.showProgress(nil)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	out := ui.OutputWriter.String()
+	if !strings.Contains(out, "Still pushing state...") {
+		t.Fatalf("expected progress, got:\n%s", out)
+	}
+	if strings.Contains(out, "%") {
+		t.Fatalf("unexpected percentage, got:\n%s", out)
+	}
+
+	// nothing is output once stopped
+	time.Sleep(30 * time.Millisecond)
+	if ui.OutputWriter.String() != out {
+		t.Fatalf("progress output after stop:\n%s", ui.OutputWriter.String())
+	}
+}
+
+func TestStatePush_progressPercent(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+		progressInterval: 10 * time.Millisecond,
+	}
+
+	stop := c.showProgress(&testStateProgresser{written: 512, total: 2048})
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	if out := ui.OutputWriter.String(); !strings.Contains(out, "Still pushing state... (25%, ") {
+		t.Fatalf("expected a percentage, got:\n%s", out)
+	}
+}
+
+func TestStatePush_progressHTTP(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &remote.HTTPClient{URL: u}
+
+	mgr := &state.BackupState{
+		Real: &remote.State{Client: client},
+		Path: "backup.tfstate",
+	}
+	if p := stateProgresser(mgr); p != client {
+		t.Fatalf("expected the http client, got %#v", p)
+	}
+
+	// the inmem client doesn't report progress
+	if p := stateProgresser(&remote.State{Client: &inmem.RemoteClient{}}); p != nil {
+		t.Fatalf("expected no progresser, got %#v", p)
+	}
+}
+
+// testStateProgresser is a state.Progresser reporting fixed progress.
+type testStateProgresser struct {
+	written, total int64
+}
+
+func (p *testStateProgresser) Progress() (int64, int64) {
+	return p.written, p.total
+}
+
+func TestStatePush_setLineage(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-good"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	lineage := "9ab1e3f4-52a6-4c4a-a2f1-2b0c2a1e4f5d"
+	expected := testStateRead(t, "replace.tfstate")
+	original := expected.Lineage
+	expected.Lineage = lineage
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-set-lineage", lineage, "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if actual.Lineage != lineage || !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// the change is always warned about
+	warning := ui.ErrorWriter.String()
+	if !strings.Contains(warning, "WARNING") || !strings.Contains(warning, original) {
+		t.Fatalf("expected warning, got:\n%s", warning)
+	}
+}
+
+func TestStatePush_setLineageChecked(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-bad-lineage"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	// the new lineage still has to match the destination
+	args := []string{"-set-lineage", "9ab1e3f4-52a6-4c4a-a2f1-2b0c2a1e4f5d", "replace.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_setLineageInvalid(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	for _, lineage := range []string{
+		"hello",
+		"9AB1E3F4-52A6-4C4A-A2F1-2B0C2A1E4F5D",
+		"{9ab1e3f4-52a6-4c4a-a2f1-2b0c2a1e4f5d}",
+	} {
+		args := []string{"-set-lineage", lineage, "replace.tfstate"}
+		if code := c.Run(args); code != 1 {
+			t.Fatalf("%s: bad: %d", lineage, code)
+		}
+		if !strings.Contains(ui.ErrorWriter.String(), "Invalid lineage") {
+			t.Fatalf("%s: bad error:\n%s", lineage, ui.ErrorWriter.String())
+		}
+	}
+}
+
+func TestStatePush_upgrade(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-upgrade"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-upgrade", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if actual.Version != terraform.StateVersion || actual.TFVersion != terraform.Version {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	output := ui.OutputWriter.String()
+	expected := "from format version 2, Terraform version 0.7.0 to format version 3"
+	if !strings.Contains(output, expected) {
+		t.Fatalf("expected %q in output:\n%s", expected, output)
+	}
+}
+
+func TestStatePush_upgradeCurrent(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-good"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "replace.tfstate")
+	expected.TFVersion = terraform.Version
+	path := testStateFile(t, expected)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-upgrade", path}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if actual.Serial != expected.Serial || !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "already current") {
+		t.Fatalf("bad output:\n%s", ui.OutputWriter.String())
+	}
+}
+
+func TestStatePush_upgradeFuture(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-good"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	future := testStateRead(t, "replace.tfstate")
+	future.TFVersion = "99.0.0"
+	path := testStateFile(t, future)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-upgrade", path}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "99.0.0") {
+		t.Fatalf("bad error:\n%s", ui.ErrorWriter.String())
+	}
+
+	if _, err := os.Stat("local-state.tfstate"); err == nil {
+		t.Fatal("state should not be written")
+	}
+}
+
+func TestStatePush_outputsOnly(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-outputs"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	original := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	// the lineage and serial of the source aren't checked
+	args := []string{"-outputs-only", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if actual.Lineage != original.Lineage || actual.Serial <= original.Serial {
+		t.Fatalf("bad lineage or serial: %q, %d", actual.Lineage, actual.Serial)
+	}
+
+	root := actual.RootModule()
+	if v := root.Outputs["foo"].Value; v != "new" {
+		t.Fatalf("bad foo output: %#v", v)
+	}
+	if v := root.Outputs["kept"].Value; v != "kept" {
+		t.Fatalf("bad kept output: %#v", v)
+	}
+	if !root.Resources["test_instance.foo"].Equal(original.RootModule().Resources["test_instance.foo"]) {
+		t.Fatalf("resources changed: %#v", root.Resources)
+	}
+}
+
+func TestStatePush_outputsOnlyResourcesDiffer(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-outputs"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-outputs-only", "changed.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "test_instance.foo") {
+		t.Fatalf("bad error:\n%s", ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_verifyIdempotent(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-good"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "replace.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-verify-idempotent", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "identically") {
+		t.Fatalf("bad output:\n%s", ui.OutputWriter.String())
+	}
+}
+
+// testMutatingState is a state.State that adds an output each time the
+// state is persisted, like a backend that mutates the state it stores.
+type testMutatingState struct {
+	state.InmemState
+	n int
+}
+
+func (s *testMutatingState) PersistState() error {
+	st := s.State()
+	st.RootModule().Outputs[fmt.Sprintf("persist%d", s.n)] = &terraform.OutputState{
+		Type:  "string",
+		Value: "mutated",
+	}
+	s.n++
+	return s.InmemState.WriteState(st)
+}
+
+func TestStateVerifyIdempotent(t *testing.T) {
+	mgr := &testMutatingState{}
+	if err := mgr.WriteState(terraform.NewState()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.PersistState(); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := stateVerifyIdempotent(mgr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+") || !strings.Contains(diff, "persist1") {
+		t.Fatalf("bad diff:\n%s", diff)
+	}
+}
+
+func TestStatePush_snapshot(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-good"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "replace.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-snapshot=before-migration", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate.before-migration.snapshot")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, `Created snapshot "before-migration": local-state.tfstate.before-migration.snapshot`) {
+		t.Fatalf("bad output:\n%s", output)
+	}
+
+	// the snapshot can't be replaced, but the push still happens
+	ui = new(cli.MockUi)
+	c = &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{"-force", "-snapshot=before-migration", "local-state.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "already exists") {
+		t.Fatalf("bad error:\n%s", ui.ErrorWriter.String())
+	}
+}
+
+func TestStatePush_snapshotInvalid(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-good"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-snapshot=../escape", "replace.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	// nothing is pushed
+	if _, err := os.Stat("local-state.tfstate"); !os.IsNotExist(err) {
+		t.Fatalf("expected no state to be pushed, got %v", err)
+	}
+}
+
+func TestStateSnapshot(t *testing.T) {
+	s := terraform.NewState()
+	var buf bytes.Buffer
+	if err := terraform.WriteState(s, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &inmem.RemoteClient{}
+	if err := client.Put(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	mgr := &state.LockDisabled{Inner: &remote.State{Client: client}}
+	id, err := stateSnapshot(mgr, "snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "snap" || !bytes.Equal(client.Snapshots["snap"], buf.Bytes()) {
+		t.Fatalf("bad snapshot %q: %#v", id, client.Snapshots)
+	}
+
+	unsupported := []state.State{
+		&state.InmemState{},
+		&remote.State{Client: &remote.FileClient{}},
+	}
+	for _, mgr := range unsupported {
+		if _, err := stateSnapshot(mgr, "snap"); err != errStateSnapshotUnsupported {
+			t.Fatalf("%T: expected errStateSnapshotUnsupported, got %v", mgr, err)
+		}
+	}
+}
+
+func TestStatePush_auditLog(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-bad-lineage"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	defer os.Setenv("TF_AUDIT_OPERATOR", os.Getenv("TF_AUDIT_OPERATOR"))
+	os.Setenv("TF_AUDIT_OPERATOR", "alice@example.com")
+
+	src := testStateRead(t, "replace.tfstate")
+	dst := testStateRead(t, "local-state.tfstate")
+
+	p := testProvider()
+	run := func(args ...string) int {
+		ui := new(cli.MockUi)
+		c := &StatePushCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(p),
+				Ui:          ui,
+			},
+		}
+		return c.Run(append([]string{"-audit-log=audit.log"}, args...))
+	}
+
+	// the blocked attempt is recorded, then the forced push
+	if code := run("replace.tfstate"); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if code := run("-force", "replace.tfstate"); code != 0 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	data, err := ioutil.ReadFile("audit.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got:\n%s", data)
+	}
+
+	var records []statePushAudit
+	for _, l := range lines {
+		var r statePushAudit
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			t.Fatalf("bad record %q: %s", l, err)
+		}
+		records = append(records, r)
+	}
+
+	for i, r := range records {
+		if r.Operator != "alice@example.com" || r.Source != "replace.tfstate" || r.Time.IsZero() {
+			t.Fatalf("bad record %d: %#v", i, r)
+		}
+		if r.SourceLineage != src.Lineage || r.SourceSerial == nil || *r.SourceSerial != src.Serial {
+			t.Fatalf("bad source of record %d: %s", i, lines[i])
+		}
+		if r.DestinationLineage != dst.Lineage || r.DestinationSerial == nil || *r.DestinationSerial != dst.Serial {
+			t.Fatalf("bad destination of record %d: %s", i, lines[i])
+		}
+	}
+
+	if r := records[0]; r.Force || r.Result != "rejected" || !strings.Contains(r.Error, "lineages do not match") {
+		t.Fatalf("bad rejected record: %s", lines[0])
+	}
+	if r := records[1]; !r.Force || r.Result != "pushed" || r.Error != "" {
+		t.Fatalf("bad pushed record: %s", lines[1])
+	}
+}
+
+func TestStatePush_jsonStream(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-bad-lineage"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	run := func(args ...string) (int, []statePushEvent) {
+		var stream bytes.Buffer
+		ui := new(cli.MockUi)
+		c := &StatePushCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(p),
+				Ui:          ui,
+			},
+			streamWriter: &stream,
+		}
+		code := c.Run(append([]string{"-json-stream"}, args...))
+
+		var events []statePushEvent
+		for _, l := range strings.Split(strings.TrimSpace(stream.String()), "\n") {
+			var e statePushEvent
+			if err := json.Unmarshal([]byte(l), &e); err != nil {
+				t.Fatalf("bad event %q: %s", l, err)
+			}
+			if e.Time.IsZero() {
+				t.Fatalf("event without a time: %s", l)
+			}
+			events = append(events, e)
+		}
+		return code, events
+	}
+	stages := func(events []statePushEvent) []string {
+		var result []string
+		for _, e := range events {
+			result = append(result, e.Stage)
+		}
+		return result
+	}
+
+	code, events := run("replace.tfstate")
+	if code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	expected := []string{"source_read", "destination_read", "failed"}
+	if actual := stages(events); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected stages %q, got %q", expected, actual)
+	}
+	if e := events[0]; e.Lineage != "hello" || e.Serial == nil {
+		t.Fatalf("bad source_read event: %#v", e)
+	}
+	if !strings.Contains(events[2].Error, "lineages do not match") {
+		t.Fatalf("bad failed event: %#v", events[2])
+	}
+
+	code, events = run("-force", "-verify-idempotent", "replace.tfstate")
+	if code != 0 {
+		t.Fatalf("bad: %d", code)
+	}
+	expected = []string{
+		"source_read", "destination_read", "checked", "backup_written",
+		"state_written", "persisted", "verified", "done",
+	}
+	if actual := stages(events); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected stages %q, got %q", expected, actual)
+	}
+	if events[3].Path == "" {
+		t.Fatalf("bad backup_written event: %#v", events[3])
+	}
+}
+
+func TestStateLineDiff(t *testing.T) {
+	a := "{\n  one\n  two\n  three\n}\n"
+	b := "{\n  one\n  three\n  four\n}\n"
+
+	expected := "-  two\n   three\n+  four\n"
+	if actual := stateLineDiff([]byte(a), []byte(b)); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestStatePush_validateRefs(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-validate-refs"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-validate-refs", "replace.tfstate"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	errOutput := ui.ErrorWriter.String()
+	for _, ref := range []string{
+		"test_instance.foo depends on test_instance.missing",
+		"module.child.test_instance.baz depends on test_instance.bar.1",
+		"module.child.test_instance.baz depends on data.test_data.qux",
+	} {
+		if !strings.Contains(errOutput, ref) {
+			t.Fatalf("expected %q in output:\n\n%s", ref, errOutput)
+		}
+	}
+	for _, ref := range []string{
+		"depends on test_instance.bar\n",
+		"depends on module.child",
+	} {
+		if strings.Contains(errOutput, ref) {
+			t.Fatalf("unexpected %q in output:\n\n%s", ref, errOutput)
+		}
+	}
+
+	if _, err := os.Stat("local-state.tfstate"); !os.IsNotExist(err) {
+		t.Fatalf("state should not be pushed: %v", err)
+	}
+}
+
+func TestStatePush_validateRefsForce(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-validate-refs"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "replace.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-validate-refs", "-force", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "test_instance.foo depends on test_instance.missing") {
+		t.Fatalf("expected a warning:\n\n%s", ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_validateRefsGood(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-good"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "replace.tfstate")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-validate-refs", "replace.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "All dependencies in the state resolve") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_patch(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-patch"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-patch", "patch.json"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	expected := "Patched 2 resource(s) in the destination state:\n\n" +
+		"  + module.child.module.grandchild.test_instance.new\n" +
+		"  ~ test_instance.foo\n"
+	if !strings.Contains(output, expected) {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// the destination is read at serial 4, since the fixture isn't in the
+	// canonical format, and the patch increments it
+	actual := testStateRead(t, "local-state.tfstate")
+	if actual.Lineage != "destination" || actual.Serial != 5 {
+		t.Fatalf("bad lineage or serial: %q, %d", actual.Lineage, actual.Serial)
+	}
+	if id := actual.RootModule().Resources["test_instance.foo"].Primary.ID; id != "patched-foo" {
+		t.Fatalf("resource not patched: %s", id)
+	}
+	nested := actual.ModuleByPath([]string{"root", "child", "grandchild"})
+	if nested == nil || nested.Resources["test_instance.new"] == nil {
+		t.Fatalf("resource not added:\n%s", actual)
+	}
+
+	// the rest of the destination is untouched
+	child := actual.ModuleByPath([]string{"root", "child"})
+	if id := child.Resources["test_instance.existing"].Primary.ID; id != "dest-existing" {
+		t.Fatalf("unpatched resource modified: %s", id)
+	}
+}
+
+func TestStatePush_patchConflict(t *testing.T) {
+	// Create a temporary working directory that is empty
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-patch"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	run := func(args ...string) (int, *cli.MockUi) {
+		ui := new(cli.MockUi)
+		c := &StatePushCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(testProvider()),
+				Ui:          ui,
+			},
+		}
+		return c.Run(args), ui
+	}
+
+	// the patch was made from an older serial
+	code, ui := run("-patch", "stale.json")
+	if code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "module.child.test_instance.existing") {
+		t.Fatalf("conflict not reported:\n%s", ui.ErrorWriter.String())
+	}
+
+	// the patch is for another state
+	code, ui = run("-patch", "lineage.json")
+	if code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), `"other"`) {
+		t.Fatalf("lineage not reported:\n%s", ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// replacing the resource anyway requires -force
+	code, ui = run("-patch", "-force", "stale.json")
+	if code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual = testStateRead(t, "local-state.tfstate")
+	child := actual.ModuleByPath([]string{"root", "child"})
+	if id := child.Resources["test_instance.existing"].Primary.ID; id != "patched-existing" {
+		t.Fatalf("existing resource not replaced: %s", id)
+	}
+}
+
+func TestReadStatePatch(t *testing.T) {
+	cases := map[string]string{
+		"empty":         `{"resources": {}}`,
+		"unknown field": `{"modules": [], "resources": {"test_instance.foo": {}}}`,
+		"bad address":   `{"resources": {"module.child.foo": {}}}`,
+		"null resource": `{"resources": {"test_instance.foo": null}}`,
+	}
+	for name, tc := range cases {
+		if _, err := readStatePatch(strings.NewReader(tc)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+
+	p, err := readStatePatch(strings.NewReader(
+		`{"resources": {"module.a.data.test_data.foo.1": {"type": "test_data"}}}`))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path, key, err := statePatchAddr("module.a.data.test_data.foo.1")
+	if err != nil || !reflect.DeepEqual(path, []string{"root", "a"}) || key != "data.test_data.foo.1" {
+		t.Fatalf("bad: %#v %q %s", path, key, err)
+	}
+	if p.Serial != nil {
+		t.Fatalf("bad serial: %d", *p.Serial)
+	}
+}
+
+func TestStatePush_encrypted(t *testing.T) {
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-replace-match"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	ciphertext := []byte("\x8f\x02\x00\xa1\xfe\x13\x37")
+	if err := ioutil.WriteFile("replace.tfstate.enc", ciphertext, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	if code := c.Run([]string{"replace.tfstate.enc"}); code != 1 {
+		t.Fatalf("expected the push to fail, got %d", code)
+	}
+	if msg := ui.ErrorWriter.String(); !strings.Contains(msg, "looks encrypted") {
+		t.Fatalf("bad error: %s", msg)
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStateLooksEncrypted(t *testing.T) {
+	cases := map[string]bool{
+		"":                         false,
+		"  {\n  \"version\": 3\n}": false,
+		"tfstate\x00\x01":          false,
+		"not a state":              false,
+		"\x8f\x02\x00\xa1\xfe":     true,
+	}
+	for in, expected := range cases {
+		if got := stateLooksEncrypted([]byte(in)); got != expected {
+			t.Errorf("%q: expected %t, got %t", in, expected, got)
+		}
+	}
+}
+
+func TestStatePush_minVersion(t *testing.T) {
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-good"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	run := func(args ...string) (int, *cli.MockUi) {
+		ui := new(cli.MockUi)
+		c := &StatePushCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(testProvider()),
+				Ui:          ui,
+			},
+		}
+		return c.Run(args), ui
+	}
+
+	old := testStateRead(t, "replace.tfstate")
+	old.TFVersion = "0.8.8"
+	path := testStateFile(t, old)
+
+	code, ui := run("-min-version=0.9.0", "-force", path)
+	if code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	msg := ui.ErrorWriter.String()
+	if !strings.Contains(msg, "written by Terraform 0.8.8") ||
+		!strings.Contains(msg, "requires\nTerraform 0.9.0 or later") {
+		t.Fatalf("bad error:\n%s", msg)
+	}
+	if _, err := os.Stat("local-state.tfstate"); err == nil {
+		t.Fatal("state should not be written")
+	}
+
+	code, ui = run("-min-version=0.8.8", path)
+	if code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(old) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestStatePush_minVersionInvalid(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	if code := c.Run([]string{"-min-version=latest", "replace.tfstate"}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), `Invalid -min-version "latest"`) {
+		t.Fatalf("bad error:\n%s", ui.ErrorWriter.String())
+	}
+}
+
+func TestStateMatchResources_wildcard(t *testing.T) {
+	resource := func(id string) *terraform.ResourceState {
+		return &terraform.ResourceState{
+			Type:    "aws_instance",
+			Primary: &terraform.InstanceState{ID: id},
+		}
+	}
+	s := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_instance.web.0": resource("web0"),
+					"aws_instance.web.1": resource("web1"),
+					"aws_instance.db.0":  resource("db0"),
+					"aws_instance.db.1":  resource("db1"),
+					"aws_instance.web0":  resource("web-zero"),
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Addr     string
+		Expected []string
+	}{
+		{"aws_instance.*[0]", []string{"aws_instance.db[0]", "aws_instance.web[0]"}},
+		{"aws_instance.web[*]", []string{"aws_instance.web[0]", "aws_instance.web[1]"}},
+		{"aws_instance.web*", []string{"aws_instance.web0", "aws_instance.web[0]", "aws_instance.web[1]"}},
+		{"aws_instance.?eb0", nil},
+	}
+
+	for _, tc := range cases {
+		results, err := stateMatchResources(s, []string{tc.Addr})
+		if err != nil {
+			t.Fatalf("%s: %s", tc.Addr, err)
+		}
+
+		var actual []string
+		for _, r := range results {
+			actual = append(actual, r.Address)
+		}
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%s: expected %v, got %v", tc.Addr, tc.Expected, actual)
+		}
+	}
+}
+
+func TestStateCheckMinVersion(t *testing.T) {
+	min := version.Must(version.NewVersion("0.9.0"))
+	cases := map[string]string{
+		"":          "an unknown version",
+		"0.8.8":     "Terraform 0.8.8",
+		"0.9.0-dev": "Terraform 0.9.0-dev",
+		"0.9.0":     "",
+		"0.10.2":    "",
+	}
+	for v, expected := range cases {
+		err := stateCheckMinVersion(&terraform.State{TFVersion: v}, min)
+		switch {
+		case expected == "" && err != nil:
+			t.Errorf("%q: unexpected error: %s", v, err)
+		case expected != "" && (err == nil || !strings.Contains(err.Error(), expected)):
+			t.Errorf("%q: expected an error mentioning %q, got %v", v, expected, err)
+		}
+	}
+}
+
+func TestStatePush_consumers(t *testing.T) {
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-consumers"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	run := func(args ...string) (int, *cli.MockUi) {
+		ui := new(cli.MockUi)
+		c := &StatePushCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(testProvider()),
+				Ui:          ui,
+			},
+		}
+		return c.Run(args), ui
+	}
+
+	expected := testStateRead(t, "local-state.tfstate")
+
+	code, ui := run("-consumer=consumer:network", "breaking.tfstate")
+	if code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	msg := ui.ErrorWriter.String()
+	for _, s := range []string{
+		"would break the consumers",
+		`consumer: data.terraform_remote_state.network.name (output "name" is missing)`,
+		`consumer: data.terraform_remote_state.network.subnet_ids (output "subnet_ids" changes from a list to a string)`,
+	} {
+		if !strings.Contains(msg, s) {
+			t.Fatalf("expected %q in error:\n%s", s, msg)
+		}
+	}
+	for _, s := range []string{"vpc_id", "token", "backend"} {
+		if strings.Contains(msg, s) {
+			t.Fatalf("unexpected %q in error:\n%s", s, msg)
+		}
+	}
+	if actual := testStateRead(t, "local-state.tfstate"); !actual.Equal(expected) {
+		t.Fatalf("state should not be written: %#v", actual)
+	}
+
+	// without a name, the remote state configured with the destination's
+	// backend is checked
+	code, ui = run("-consumer=consumer", "breaking.tfstate")
+	if code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	msg = ui.ErrorWriter.String()
+	if !strings.Contains(msg, `data.terraform_remote_state.network.name (output "name" is missing)`) ||
+		strings.Contains(msg, "token") {
+		t.Fatalf("bad error:\n%s", msg)
+	}
+
+	// a name is required if none of them are
+	code, ui = run("-consumer=consumer", "-state-dest=elsewhere.tfstate", "compatible.tfstate")
+	if code != 1 || !strings.Contains(ui.ErrorWriter.String(), "Use -consumer=consumer:NAME") {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if _, err := os.Stat("elsewhere.tfstate"); !os.IsNotExist(err) {
+		t.Fatalf("state should not be written: %v", err)
+	}
+
+	code, ui = run("-consumer=consumer:network", "compatible.tfstate")
+	if code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "every output referenced by its 1 consumer(s)") {
+		t.Fatalf("bad output:\n%s", ui.OutputWriter.String())
+	}
+	expected = testStateRead(t, "compatible.tfstate")
+	if actual := testStateRead(t, "local-state.tfstate"); !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	code, ui = run("-consumer=consumer:missing", "compatible.tfstate")
+	if code != 1 || !strings.Contains(ui.ErrorWriter.String(), "no data source data.terraform_remote_state.missing") {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestStatePush_consumersForce(t *testing.T) {
+	td := tempDir(t)
+	copy.CopyDir(testFixturePath("state-push-consumers"), td)
+	defer os.RemoveAll(td)
+	defer testChdir(t, td)()
+
+	expected := testStateRead(t, "breaking.tfstate")
+
+	ui := new(cli.MockUi)
+	c := &StatePushCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	args := []string{"-consumer=consumer:network", "-force", "breaking.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), `output "name" is missing`) {
+		t.Fatalf("expected a warning:\n%s", ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, "local-state.tfstate")
+	if !actual.Equal(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestParseStateConsumer(t *testing.T) {
+	cases := map[string]stateConsumer{
+		"../network":         {Dir: "../network"},
+		"../network:vpc":     {Dir: "../network", Name: "vpc"},
+		`C:\infra\network`:   {Dir: `C:\infra\network`},
+		`C:\infra\app:vpc_1`: {Dir: `C:\infra\app`, Name: "vpc_1"},
+	}
+	for v, expected := range cases {
+		actual, err := parseStateConsumer(v)
+		if err != nil {
+			t.Fatalf("%s: %s", v, err)
+		}
+		if *actual != expected {
+			t.Fatalf("%s: expected %#v, got %#v", v, expected, *actual)
+		}
+	}
+
+	if _, err := parseStateConsumer(""); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestStateLocationMatches(t *testing.T) {
+	dest := &stateLocation{
+		Backend: "s3",
+		Config:  map[string]interface{}{"bucket": "infra", "key": "network.tfstate"},
+		Env:     "default",
+	}
+	cases := map[string]struct {
+		Location *stateLocation
+		Expected bool
+	}{
+		"same": {
+			&stateLocation{Backend: "s3", Config: map[string]interface{}{
+				"bucket": "infra", "key": "network.tfstate", "profile": "reader",
+			}, Env: "default"},
+			true,
+		},
+		"other key": {
+			&stateLocation{Backend: "s3", Config: map[string]interface{}{
+				"bucket": "infra", "key": "app.tfstate",
+			}, Env: "default"},
+			false,
+		},
+		"other environment": {
+			&stateLocation{Backend: "s3", Config: dest.Config, Env: "staging"},
+			false,
+		},
+		"other backend": {
+			&stateLocation{Backend: "gcs", Config: dest.Config, Env: "default"},
+			false,
+		},
+		"nothing in common": {
+			&stateLocation{Backend: "s3", Config: map[string]interface{}{
+				"profile": "reader",
+			}, Env: "default"},
+			false,
+		},
+	}
+	for name, tc := range cases {
+		if actual := tc.Location.matches(dest); actual != tc.Expected {
+			t.Fatalf("%s: expected %t, got %t", name, tc.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+data "terraform_remote_state" "network" {
+    backend = "local"
+    config {
+        path = "../local-state.tfstate"
+    }
+}
+
+data "terraform_remote_state" "other" {
+    backend = "local"
+    config {
+        path = "other.tfstate"
+    }
+}
+
+resource "test_instance" "foo" {
+    ami    = "${data.terraform_remote_state.network.vpc_id}"
+    subnet = "${data.terraform_remote_state.network.subnet_ids.0}"
+}
+
+output "name" {
+    value = "${data.terraform_remote_state.network.name}"
+}
+
+output "backend" {
+    value = "${data.terraform_remote_state.network.backend}"
+}
+
+output "token" {
+    value = "${data.terraform_remote_state.other.token}"
+}
@@ -21,12 +21,14 @@ const (
 )
 
 func init() {
-	Ui = &cli.PrefixedUi{
-		AskPrefix:    OutputPrefix,
-		OutputPrefix: OutputPrefix,
-		InfoPrefix:   OutputPrefix,
-		ErrorPrefix:  ErrorPrefix,
-		Ui:           &cli.BasicUi{Writer: os.Stdout},
+	Ui = &lastErrorUi{
+		Ui: &cli.PrefixedUi{
+			AskPrefix:    OutputPrefix,
+			OutputPrefix: OutputPrefix,
+			InfoPrefix:   OutputPrefix,
+			ErrorPrefix:  ErrorPrefix,
+			Ui:           &cli.BasicUi{Writer: os.Stdout},
+		},
 	}
 
 	meta := command.Meta{
@@ -204,12 +206,72 @@ func init() {
 			}, nil
 		},
 
+		"debug animate": func() (cli.Command, error) {
+			return &command.DebugAnimateCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"debug compare": func() (cli.Command, error) {
+			return &command.DebugCompareCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"debug compact": func() (cli.Command, error) {
+			return &command.DebugCompactCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"debug flamegraph": func() (cli.Command, error) {
+			return &command.DebugFlamegraphCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"debug graphs": func() (cli.Command, error) {
+			return &command.DebugGraphsCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"debug grep": func() (cli.Command, error) {
+			return &command.DebugGrepCommand{
+				Meta: meta,
+			}, nil
+		},
+
 		"debug json2dot": func() (cli.Command, error) {
 			return &command.DebugJSON2DotCommand{
 				Meta: meta,
 			}, nil
 		},
 
+		"debug manifest": func() (cli.Command, error) {
+			return &command.DebugManifestCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"debug recover": func() (cli.Command, error) {
+			return &command.DebugRecoverCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"debug state-diff": func() (cli.Command, error) {
+			return &command.DebugStateDiffCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"debug trace": func() (cli.Command, error) {
+			return &command.DebugTraceCommand{
+				Meta: meta,
+			}, nil
+		},
+
 		"force-unlock": func() (cli.Command, error) {
 			return &command.UnlockCommand{
 				Meta: meta,
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/hashicorp/go-plugin"
@@ -327,6 +328,53 @@ func (c *Config) provisionerFactory(path string) terraform.ResourceProvisionerFa
 	}
 }
 
+// DebugPlugins returns the resolved providers and provisioners in the form
+// recorded by the debug archive.
+func (c *Config) DebugPlugins() []terraform.DebugPlugin {
+	var result []terraform.DebugPlugin
+	for kind, m := range map[string]map[string]string{
+		"provider":    c.Providers,
+		"provisioner": c.Provisioners,
+	} {
+		for name, path := range m {
+			p := terraform.DebugPlugin{
+				Name: name,
+				Kind: kind,
+				Path: pluginCmd(path).Path,
+			}
+
+			// Internal plugins are built into this binary, so they share
+			// its version. The plugin handshake doesn't carry a version, so
+			// external plugins only have one if it's in their file name.
+			if strings.Contains(path, command.TFSPACE) {
+				p.Internal = true
+				p.Version = terraform.VersionString()
+			} else {
+				p.Version = pluginFileVersion(p.Path)
+			}
+
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+// pluginVersionPattern matches the file name of a plugin that includes its
+// version, i.e. "terraform-provider-aws_v0.1.0".
+var pluginVersionPattern = regexp.MustCompile(
+	`^terraform-(?:provider|provisioner)-[^_]+_v(\d+\.\d+\.\d+[^.]*)(?:\.exe)?$`)
+
+// pluginFileVersion returns the version in the file name of the plugin at
+// path, or an empty string if there's none.
+func pluginFileVersion(path string) string {
+	m := pluginVersionPattern.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
 func pluginCmd(path string) *exec.Cmd {
 	cmdPath := ""
 
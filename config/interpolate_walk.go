@@ -36,6 +36,28 @@ type interpolationWalker struct {
 	unknownKeys []string
 }
 
+// InterpolationError is the error returned when an interpolation in a
+// configuration can't be evaluated.
+type InterpolationError struct {
+	// Key is the key of the value containing the interpolation, such as
+	// "tags.Name", and Attr its top-level key, "tags".
+	Key  string
+	Attr string
+
+	// Expr is the value containing the interpolation, as written.
+	Expr string
+
+	// Pos is the source position of the value of Attr, if known. Filename
+	// and Line are zero if the position isn't known.
+	Pos ast.Pos
+
+	Err error
+}
+
+func (e *InterpolationError) Error() string {
+	return fmt.Sprintf("%s in:\n\n%s", e.Err, e.Expr)
+}
+
 // interpolationWalkerFunc is the callback called by interpolationWalk.
 // It is called with any interpolation found. It should return a value
 // to replace the interpolation with, along with any errors.
@@ -146,9 +168,15 @@ func (w *interpolationWalker) Primitive(v reflect.Value) error {
 
 	replaceVal, err := w.F(astRoot)
 	if err != nil {
-		return fmt.Errorf(
-			"%s in:\n\n%s",
-			err, v.String())
+		ie := &InterpolationError{
+			Key:  strings.Join(w.key, "."),
+			Expr: v.String(),
+			Err:  err,
+		}
+		if len(w.key) > 0 {
+			ie.Attr = w.key[0]
+		}
+		return ie
 	}
 
 	if w.Replace {
@@ -7,6 +7,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/hcl/hcl/ast"
+	hilast "github.com/hashicorp/hil/ast"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -131,6 +132,31 @@ func (t *hclConfigurable) Config() (*Config, error) {
 		config.unknownKeys = append(config.unknownKeys, k)
 	}
 
+	// Record the file the configuration was loaded from, so errors
+	// interpolating it can point to their source.
+	var rcs []*RawConfig
+	for _, m := range config.Modules {
+		rcs = append(rcs, m.RawConfig)
+	}
+	for _, p := range config.ProviderConfigs {
+		rcs = append(rcs, p.RawConfig)
+	}
+	for _, r := range config.Resources {
+		rcs = append(rcs, r.RawConfig)
+		for _, p := range r.Provisioners {
+			rcs = append(rcs, p.RawConfig)
+		}
+	}
+	for _, o := range config.Outputs {
+		rcs = append(rcs, o.RawConfig)
+	}
+	for _, rc := range rcs {
+		for k, pos := range rc.Positions {
+			pos.Filename = t.File
+			rc.Positions[k] = pos
+		}
+	}
+
 	return config, nil
 }
 
@@ -366,6 +392,8 @@ func loadModulesHcl(list *ast.ObjectList) ([]*Module, error) {
 				err)
 		}
 
+		rawConfig.Positions = hclPositions(item.Val)
+
 		// If we have a count, then figure it out
 		var source string
 		if o := listVal.Filter("source"); len(o.Items) > 0 {
@@ -425,6 +453,8 @@ func loadOutputsHcl(list *ast.ObjectList) ([]*Output, error) {
 				err)
 		}
 
+		rawConfig.Positions = hclPositions(item.Val)
+
 		// If we have depends fields, then add those in
 		var dependsOn []string
 		if o := listVal.Filter("depends_on"); len(o.Items) > 0 {
@@ -563,6 +593,8 @@ func loadProvidersHcl(list *ast.ObjectList) ([]*ProviderConfig, error) {
 				err)
 		}
 
+		rawConfig.Positions = hclPositions(item.Val)
+
 		// If we have an alias field, then add those in
 		var alias string
 		if a := listVal.Filter("alias"); len(a.Items) > 0 {
@@ -642,6 +674,8 @@ func loadDataResourcesHcl(list *ast.ObjectList) ([]*Resource, error) {
 				err)
 		}
 
+		rawConfig.Positions = hclPositions(item.Val)
+
 		// If we have a count, then figure it out
 		var count string = "1"
 		if o := listVal.Filter("count"); len(o.Items) > 0 {
@@ -776,6 +810,8 @@ func loadManagedResourcesHcl(list *ast.ObjectList) ([]*Resource, error) {
 				err)
 		}
 
+		rawConfig.Positions = hclPositions(item.Val)
+
 		// If we have a count, then figure it out
 		var count string = "1"
 		if o := listVal.Filter("count"); len(o.Items) > 0 {
@@ -963,6 +999,8 @@ func loadProvisionersHcl(list *ast.ObjectList, connInfo map[string]interface{})
 			return nil, err
 		}
 
+		rawConfig.Positions = hclPositions(item.Val)
+
 		// Check if we have a provisioner-level connection
 		// block that overrides the resource-level
 		var subConnInfo map[string]interface{}
@@ -1089,3 +1127,35 @@ func unwrapHCLObjectKeysFromJSON(item *ast.ObjectItem, depth int) {
 		}
 	}
 }
+
+// hclPositions returns the position of the value of each top-level key of
+// the object n, so errors interpolating it can point to their source.
+func hclPositions(n ast.Node) map[string]hilast.Pos {
+	ot, ok := n.(*ast.ObjectType)
+	if !ok {
+		return nil
+	}
+
+	result := make(map[string]hilast.Pos)
+	for _, item := range ot.List.Items {
+		if len(item.Keys) == 0 {
+			continue
+		}
+		k, ok := item.Keys[0].Token.Value().(string)
+		if !ok {
+			continue
+		}
+		if _, ok := result[k]; ok {
+			continue
+		}
+
+		pos := item.Val.Pos()
+		result[k] = hilast.Pos{
+			Filename: pos.Filename,
+			Line:     pos.Line,
+			Column:   pos.Column,
+		}
+	}
+
+	return result
+}
@@ -25,6 +25,7 @@ type Tree struct {
 	config   *config.Config
 	children map[string]*Tree
 	path     []string
+	source   string
 	lock     sync.RWMutex
 }
 
@@ -196,8 +197,9 @@ func (t *Tree) Load(s getter.Storage, mode GetMode) error {
 				"module %s: %s", m.Name, err)
 		}
 
-		// Set the path of this child
+		// Set the path of this child, and the source it was resolved to
 		children[m.Name].path = path
+		children[m.Name].source = source
 	}
 
 	// Go through all the children and load them.
@@ -213,6 +215,14 @@ func (t *Tree) Load(s getter.Storage, mode GetMode) error {
 	return nil
 }
 
+// Source returns the source address the module was loaded from, after
+// detection, i.e. "git::https://github.com/hashicorp/example.git?ref=v1.0.0"
+// for the source "github.com/hashicorp/example?ref=v1.0.0". This is empty for
+// the root tree, and for trees that weren't loaded with Load.
+func (t *Tree) Source() string {
+	return t.source
+}
+
 // Path is the full path to this tree.
 func (t *Tree) Path() []string {
 	return t.path
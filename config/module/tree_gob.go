@@ -23,6 +23,7 @@ func (t *Tree) GobDecode(bs []byte) error {
 	t.config = data.Config
 	t.children = data.Children
 	t.path = data.Path
+	t.source = data.Source
 
 	return nil
 }
@@ -33,6 +34,7 @@ func (t *Tree) GobEncode() ([]byte, error) {
 		Children: t.children,
 		Name:     t.name,
 		Path:     t.path,
+		Source:   t.source,
 	}
 
 	var buf bytes.Buffer
@@ -54,4 +56,5 @@ type treeGob struct {
 	Children map[string]*Tree
 	Name     string
 	Path     []string
+	Source   string
 }
@@ -97,6 +97,15 @@ func TestTreeLoad(t *testing.T) {
 	if actual != expected {
 		t.Fatalf("bad: \n\n%s", actual)
 	}
+
+	// the children record the source they were resolved to
+	if tree.Source() != "" {
+		t.Fatalf("bad root source: %s", tree.Source())
+	}
+	source := tree.Children()["foo"].Source()
+	if !strings.HasPrefix(source, "file://") || !strings.HasSuffix(source, "/basic/foo") {
+		t.Fatalf("bad child source: %s", source)
+	}
 }
 
 func TestTreeLoad_duplicate(t *testing.T) {
@@ -32,6 +32,11 @@ type RawConfig struct {
 	Interpolations []ast.Node
 	Variables      map[string]InterpolatedVariable
 
+	// Positions is the source position of the value of each top-level key
+	// of Raw, if known. It is used to locate errors interpolating the
+	// configuration, and isn't preserved when the configuration is encoded.
+	Positions map[string]ast.Pos
+
 	lock        sync.Mutex
 	config      map[string]interface{}
 	unknownKeys []string
@@ -80,6 +85,7 @@ func (r *RawConfig) Copy() *RawConfig {
 	}
 
 	result.Key = r.Key
+	result.Positions = copyPositions(r.Positions, nil)
 	return result
 }
 
@@ -232,6 +238,9 @@ func (r *RawConfig) interpolate(fn interpolationWalkerFunc) error {
 	w := &interpolationWalker{F: fn, Replace: true}
 	err = reflectwalk.Walk(r.config, w)
 	if err != nil {
+		if ie, ok := err.(*InterpolationError); ok {
+			ie.Pos = r.Positions[ie.Attr]
+		}
 		return err
 	}
 
@@ -265,6 +274,29 @@ func (r *RawConfig) merge(r2 *RawConfig) *RawConfig {
 		panic(err)
 	}
 
+	if r2 != nil {
+		result.Positions = copyPositions(r.Positions, r2.Positions)
+	} else {
+		result.Positions = copyPositions(r.Positions, nil)
+	}
+
+	return result
+}
+
+// copyPositions returns a copy of the positions in a, overridden by those in
+// b, or nil if there are none.
+func copyPositions(a, b map[string]ast.Pos) map[string]ast.Pos {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	result := make(map[string]ast.Pos, len(a)+len(b))
+	for k, v := range a {
+		result[k] = v
+	}
+	for k, v := range b {
+		result[k] = v
+	}
 	return result
 }
 
@@ -129,3 +129,19 @@ func TestConfig_Merge_disableCheckpointSignature(t *testing.T) {
 		t.Fatalf("bad: %#v", actual)
 	}
 }
+
+func TestPluginFileVersion(t *testing.T) {
+	cases := map[string]string{
+		"/plugins/terraform-provider-aws_v0.1.0": "0.1.0",
+		"terraform-provider-null_v1.2.3-beta1":   "1.2.3-beta1",
+		"terraform-provisioner-salt_v2.0.0.exe":  "2.0.0",
+		"/plugins/terraform-provider-aws":        "",
+		"/plugins/terraform-provider-aws_v1":     "",
+		"/plugins/my-plugin_v1.0.0":              "",
+	}
+	for path, expected := range cases {
+		if actual := pluginFileVersion(path); actual != expected {
+			t.Fatalf("%s: expected %q, got %q", path, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// DebugSchemas implementation of terraform.DebugSchemaProvider interface.
+func (p *Provider) DebugSchemas() (*terraform.DebugProviderSchema, error) {
+	result := &terraform.DebugProviderSchema{
+		Provider:      debugSchemaMap(p.Schema),
+		ResourceTypes: make(map[string]map[string]*terraform.DebugAttributeSchema),
+		DataSources:   make(map[string]map[string]*terraform.DebugAttributeSchema),
+	}
+
+	for k, r := range p.ResourcesMap {
+		if r != nil {
+			result.ResourceTypes[k] = debugSchemaMap(r.Schema)
+		}
+	}
+	for k, r := range p.DataSourcesMap {
+		if r != nil {
+			result.DataSources[k] = debugSchemaMap(r.Schema)
+		}
+	}
+
+	return result, nil
+}
+
+// debugSchemaMap returns the debug description of the attributes in m.
+func debugSchemaMap(m map[string]*Schema) map[string]*terraform.DebugAttributeSchema {
+	result := make(map[string]*terraform.DebugAttributeSchema, len(m))
+	for k, s := range m {
+		if s != nil {
+			result[k] = debugSchema(s)
+		}
+	}
+	return result
+}
+
+// debugSchema returns the debug description of a single attribute.
+func debugSchema(s *Schema) *terraform.DebugAttributeSchema {
+	result := &terraform.DebugAttributeSchema{
+		Type:      strings.ToLower(strings.TrimPrefix(s.Type.String(), "Type")),
+		Optional:  s.Optional,
+		Required:  s.Required,
+		Computed:  s.Computed,
+		ForceNew:  s.ForceNew,
+		Sensitive: s.Sensitive,
+	}
+
+	switch elem := s.Elem.(type) {
+	case *Schema:
+		result.Elem = debugSchema(elem)
+	case *Resource:
+		result.Block = debugSchemaMap(elem.Schema)
+	}
+
+	return result
+}
@@ -165,6 +165,78 @@ func TestProviderDataSources(t *testing.T) {
 	}
 }
 
+func TestProviderDebugSchemas(t *testing.T) {
+	p := &Provider{
+		Schema: map[string]*Schema{
+			"region": &Schema{Type: TypeString, Required: true},
+		},
+		ResourcesMap: map[string]*Resource{
+			"foo": &Resource{
+				Schema: map[string]*Schema{
+					"tags": &Schema{
+						Type:     TypeSet,
+						Optional: true,
+						ForceNew: true,
+						Elem:     &Schema{Type: TypeString},
+					},
+					"disk": &Schema{
+						Type:     TypeList,
+						Computed: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"size": &Schema{Type: TypeInt, Required: true},
+							},
+						},
+					},
+				},
+			},
+		},
+		DataSourcesMap: map[string]*Resource{
+			"bar": &Resource{
+				Schema: map[string]*Schema{
+					"password": &Schema{Type: TypeString, Computed: true, Sensitive: true},
+				},
+			},
+		},
+	}
+
+	actual, err := p.DebugSchemas()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &terraform.DebugProviderSchema{
+		Provider: map[string]*terraform.DebugAttributeSchema{
+			"region": {Type: "string", Required: true},
+		},
+		ResourceTypes: map[string]map[string]*terraform.DebugAttributeSchema{
+			"foo": {
+				"tags": {
+					Type:     "set",
+					Optional: true,
+					ForceNew: true,
+					Elem:     &terraform.DebugAttributeSchema{Type: "string"},
+				},
+				"disk": {
+					Type:     "list",
+					Computed: true,
+					Block: map[string]*terraform.DebugAttributeSchema{
+						"size": {Type: "int", Required: true},
+					},
+				},
+			},
+		},
+		DataSources: map[string]map[string]*terraform.DebugAttributeSchema{
+			"bar": {
+				"password": {Type: "string", Computed: true, Sensitive: true},
+			},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestProviderValidate(t *testing.T) {
 	cases := []struct {
 		P      *Provider
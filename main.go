@@ -95,9 +95,14 @@ func realMain() int {
 	return wrappedMain()
 }
 
-func wrappedMain() int {
-	// We always need to close the DebugInfo before we exit.
-	defer terraform.CloseDebugInfo()
+func wrappedMain() (code int) {
+	// We always need to close the DebugInfo before we exit, recording the
+	// outcome of the command first, so it's written even if the command
+	// failed.
+	defer func() {
+		terraform.SetDebugOutcome(code, lastError())
+		terraform.CloseDebugInfo()
+	}()
 
 	log.SetOutput(os.Stderr)
 	log.Printf(
@@ -131,6 +136,11 @@ func wrappedMain() int {
 		config = *config.Merge(usrcfg)
 	}
 
+	// Record the resolved plugins for the debug archive
+	if err := terraform.SetDebugPlugins(config.DebugPlugins()); err != nil {
+		log.Printf("[WARN] Error recording plugins in debug archive: %s", err)
+	}
+
 	// Run checkpoint
 	go runCheckpoint(&config)
 
@@ -178,6 +188,9 @@ func wrappedMain() int {
 
 	// Rebuild the CLI with any modified args.
 	log.Printf("[INFO] CLI command args: %#v", args)
+	if err := terraform.SetDebugArgs(args); err != nil {
+		log.Printf("[WARN] Error recording args in debug archive: %s", err)
+	}
 	cliRunner = &cli.CLI{
 		Args:       args,
 		Commands:   Commands,
@@ -321,3 +334,32 @@ func mergeEnvArgs(envName string, cmd string, args []string) ([]string, error) {
 	copy(newArgs[len(extra)+idx:], args[idx:])
 	return newArgs, nil
 }
+
+// lastErrorUi is a cli.Ui that records the last error written to it, as the
+// top-level error of the command in the outcome of the debug archive.
+type lastErrorUi struct {
+	cli.Ui
+
+	lock sync.Mutex
+	err  string
+}
+
+func (u *lastErrorUi) Error(msg string) {
+	u.lock.Lock()
+	u.err = msg
+	u.lock.Unlock()
+
+	u.Ui.Error(msg)
+}
+
+// lastError returns the last error written to Ui, if any.
+func lastError() string {
+	u, ok := Ui.(*lastErrorUi)
+	if !ok {
+		return ""
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.err
+}
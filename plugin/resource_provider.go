@@ -1,6 +1,7 @@
 package plugin
 
 import (
+	"errors"
 	"net/rpc"
 
 	"github.com/hashicorp/go-plugin"
@@ -297,6 +298,21 @@ func (p *ResourceProvider) DataSources() []terraform.DataSource {
 	return result
 }
 
+// DebugSchemas implementation of terraform.DebugSchemaProvider interface.
+// Plugins built before schemas could be described return an error.
+func (p *ResourceProvider) DebugSchemas() (*terraform.DebugProviderSchema, error) {
+	var resp ResourceProviderDebugSchemasResponse
+	err := p.Client.Call("Plugin.DebugSchemas", new(interface{}), &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		err = resp.Error
+	}
+
+	return resp.Schema, err
+}
+
 func (p *ResourceProvider) Close() error {
 	return p.Client.Close()
 }
@@ -312,6 +328,11 @@ type ResourceProviderStopResponse struct {
 	Error *plugin.BasicError
 }
 
+type ResourceProviderDebugSchemasResponse struct {
+	Schema *terraform.DebugProviderSchema
+	Error  *plugin.BasicError
+}
+
 type ResourceProviderConfigureResponse struct {
 	Error *plugin.BasicError
 }
@@ -576,3 +597,22 @@ func (s *ResourceProviderServer) DataSources(
 	*result = s.Provider.DataSources()
 	return nil
 }
+
+func (s *ResourceProviderServer) DebugSchemas(
+	nothing interface{},
+	result *ResourceProviderDebugSchemasResponse) error {
+	sp, ok := s.Provider.(terraform.DebugSchemaProvider)
+	if !ok {
+		*result = ResourceProviderDebugSchemasResponse{
+			Error: plugin.NewBasicError(errors.New("the provider can't describe its schema")),
+		}
+		return nil
+	}
+
+	schema, err := sp.DebugSchemas()
+	*result = ResourceProviderDebugSchemasResponse{
+		Schema: schema,
+		Error:  plugin.NewBasicError(err),
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 func TestResourceProvider_impl(t *testing.T) {
 	var _ plugin.Plugin = new(ResourceProviderPlugin)
 	var _ terraform.ResourceProvider = new(ResourceProvider)
+	var _ terraform.DebugSchemaProvider = new(ResourceProvider)
 }
 
 func TestResourceProvider_stop(t *testing.T) {
@@ -515,6 +516,75 @@ func TestResourceProvider_datasources(t *testing.T) {
 	}
 }
 
+func TestResourceProvider_debugSchemas(t *testing.T) {
+	expected := &terraform.DebugProviderSchema{
+		ResourceTypes: map[string]map[string]*terraform.DebugAttributeSchema{
+			"test_instance": {
+				"ami": {Type: "string", Required: true, ForceNew: true},
+				"tags": {
+					Type:     "map",
+					Optional: true,
+					Elem:     &terraform.DebugAttributeSchema{Type: "string"},
+				},
+			},
+		},
+	}
+	p := &testDebugSchemaProvider{Schema: expected}
+
+	// Create a mock provider
+	client, _ := plugin.TestPluginRPCConn(t, pluginMap(&ServeOpts{
+		ProviderFunc: testProviderFixed(p),
+	}))
+	defer client.Close()
+
+	// Request the provider
+	raw, err := client.Dispense(ProviderPluginName)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	provider := raw.(terraform.DebugSchemaProvider)
+
+	result, err := provider.DebugSchemas()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestResourceProvider_debugSchemasUnsupported(t *testing.T) {
+	p := new(terraform.MockResourceProvider)
+
+	// Create a mock provider
+	client, _ := plugin.TestPluginRPCConn(t, pluginMap(&ServeOpts{
+		ProviderFunc: testProviderFixed(p),
+	}))
+	defer client.Close()
+
+	// Request the provider
+	raw, err := client.Dispense(ProviderPluginName)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	provider := raw.(terraform.DebugSchemaProvider)
+
+	if _, err := provider.DebugSchemas(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+// testDebugSchemaProvider is a mock provider that can describe its schema.
+type testDebugSchemaProvider struct {
+	terraform.MockResourceProvider
+
+	Schema *terraform.DebugProviderSchema
+}
+
+func (p *testDebugSchemaProvider) DebugSchemas() (*terraform.DebugProviderSchema, error) {
+	return p.Schema, nil
+}
+
 func TestResourceProvider_validate(t *testing.T) {
 	p := new(terraform.MockResourceProvider)
 
@@ -139,6 +139,40 @@ func (s *LocalState) RefreshState() error {
 	return nil
 }
 
+// Snapshot implements Snapshotter by writing a read-only copy of the state
+// next to the state file, named PATH.NAME.snapshot. The path of the copy is
+// returned as the snapshot identifier.
+func (s *LocalState) Snapshot(name string) (string, error) {
+	if s.state == nil {
+		return "", fmt.Errorf("no state to snapshot")
+	}
+
+	path := s.PathOut
+	if path == "" {
+		path = s.Path
+	}
+	snapshotPath := fmt.Sprintf("%s.%s.snapshot", path, name)
+
+	f, err := os.OpenFile(snapshotPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0444)
+	if err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("snapshot %q already exists", name)
+		}
+		return "", err
+	}
+
+	err = terraform.WriteState(s.state, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(snapshotPath)
+		return "", err
+	}
+
+	return snapshotPath, nil
+}
+
 // Lock implements a local filesystem state.Locker.
 func (s *LocalState) Lock(info *LockInfo) (string, error) {
 	if s.stateFileOut == nil {
@@ -123,11 +123,44 @@ func TestLocalState_nonExist(t *testing.T) {
 	}
 }
 
+func TestLocalState_snapshot(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	id, err := ls.Snapshot("before-migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(id)
+	if id != ls.Path+".before-migration.snapshot" {
+		t.Fatalf("bad snapshot id: %s", id)
+	}
+
+	f, err := os.Open(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	snapshot, err := terraform.ReadState(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snapshot.Equal(ls.State()) {
+		t.Fatalf("bad snapshot: %s", snapshot)
+	}
+
+	// snapshots are never replaced
+	if _, err := ls.Snapshot("before-migration"); err == nil {
+		t.Fatal("expected error creating a snapshot with an existing name")
+	}
+}
+
 func TestLocalState_impl(t *testing.T) {
 	var _ StateReader = new(LocalState)
 	var _ StateWriter = new(LocalState)
 	var _ StatePersister = new(LocalState)
 	var _ StateRefresher = new(LocalState)
+	var _ Snapshotter = new(LocalState)
 }
 
 func testLocalState(t *testing.T) *LocalState {
@@ -7,9 +7,11 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync/atomic"
 )
 
 func httpFactory(conf map[string]string) (Client, error) {
@@ -63,6 +65,10 @@ type HTTPClient struct {
 	Client   *http.Client
 	Username string
 	Password string
+
+	// written and total are the progress of the current Put, accessed
+	// atomically
+	written, total int64
 }
 
 func (c *HTTPClient) Get() (*Payload, error) {
@@ -152,11 +158,22 @@ func (c *HTTPClient) Put(data []byte) error {
 		}
 	*/
 
-	req, err := http.NewRequest("POST", base.String(), bytes.NewReader(data))
+	// Count the bytes of the body as they're sent, to report the progress
+	atomic.StoreInt64(&c.total, int64(len(data)))
+	defer atomic.StoreInt64(&c.total, 0)
+	newBody := func() io.ReadCloser {
+		atomic.StoreInt64(&c.written, 0)
+		return ioutil.NopCloser(&httpProgressReader{r: bytes.NewReader(data), n: &c.written})
+	}
+
+	req, err := http.NewRequest("POST", base.String(), newBody())
 	if err != nil {
 		return fmt.Errorf("Failed to make HTTP request: %s", err)
 	}
 
+	// the body is sent again from the start if the request is redirected
+	req.GetBody = func() (io.ReadCloser, error) { return newBody(), nil }
+
 	// Prepare the request
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Content-MD5", b64)
@@ -181,6 +198,24 @@ func (c *HTTPClient) Put(data []byte) error {
 	}
 }
 
+// Progress implements state.Progresser, reporting the bytes of the state
+// sent by the current Put.
+func (c *HTTPClient) Progress() (int64, int64) {
+	return atomic.LoadInt64(&c.written), atomic.LoadInt64(&c.total)
+}
+
+// httpProgressReader counts the bytes read from r in n.
+type httpProgressReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (r *httpProgressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
+
 func (c *HTTPClient) Delete() error {
 	req, err := http.NewRequest("DELETE", c.URL.String(), nil)
 	if err != nil {
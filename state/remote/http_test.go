@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -30,6 +31,36 @@ func TestHTTPClient(t *testing.T) {
 	testClient(t, client)
 }
 
+func TestHTTPClient_progress(t *testing.T) {
+	var _ ClientProgresser = new(HTTPClient)
+
+	client := new(HTTPClient)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the body is read by the time the response is written
+		ioutil.ReadAll(r.Body)
+		if written, total := client.Progress(); written != 5 || total != 5 {
+			t.Errorf("bad progress during put: %d/%d", written, total)
+		}
+	}))
+	defer ts.Close()
+
+	url, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.URL = url
+	client.Client = cleanhttp.DefaultClient()
+
+	if err := client.Put([]byte("hello")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// no write is in progress once the put returns
+	if _, total := client.Progress(); total != 0 {
+		t.Fatalf("bad total after put: %d", total)
+	}
+}
+
 type testHTTPHandler struct {
 	Data []byte
 }
@@ -22,6 +22,20 @@ type ClientLocker interface {
 	state.Locker
 }
 
+// ClientSnapshotter is an optional interface that allows a remote state
+// backend to keep named, immutable snapshots of the stored state.
+type ClientSnapshotter interface {
+	Client
+	state.Snapshotter
+}
+
+// ClientProgresser is an optional interface that allows a remote state
+// backend to report the progress of a Put.
+type ClientProgresser interface {
+	Client
+	state.Progresser
+}
+
 // Payload is the return value from the remote state storage.
 type Payload struct {
 	MD5  []byte
@@ -74,6 +74,22 @@ type Locker interface {
 	Unlock(id string) error
 }
 
+// Snapshotter is an optional interface implemented by states whose storage
+// can keep a named, immutable copy of the current state. Snapshot returns an
+// identifier for the snapshot, which can be used to find it in the storage
+// later. Creating a snapshot with a name that's already in use is an error.
+type Snapshotter interface {
+	Snapshot(name string) (string, error)
+}
+
+// Progresser is an optional interface implemented by states whose storage
+// reports how much of the state it has written while it's persisted.
+// Progress returns the number of bytes written so far and the total size of
+// the state being written. The total is zero when no write is in progress.
+type Progresser interface {
+	Progress() (written, total int64)
+}
+
 // test hook to verify that LockWithContext has attempted a lock
 var postLockHook func()
 
@@ -82,9 +98,26 @@ var postLockHook func()
 func LockWithContext(ctx context.Context, s State, info *LockInfo) (string, error) {
 	delay := time.Second
 	maxDelay := 16 * time.Second
+
+	// record any time spent waiting in the debug archive
+	wait := terraform.DebugLockWait{
+		Start: time.Now(),
+	}
+	if info != nil {
+		wait.Operation = info.Operation
+	}
+	defer func() {
+		if wait.Attempts > 1 {
+			wait.Wait = time.Since(wait.Start)
+			terraform.RecordDebugLockWait(wait)
+		}
+	}()
+
 	for {
+		wait.Attempts++
 		id, err := s.Lock(info)
 		if err == nil {
+			wait.Acquired = true
 			return id, nil
 		}
 
@@ -103,10 +136,14 @@ func LockWithContext(ctx context.Context, s State, info *LockInfo) (string, erro
 			postLockHook()
 		}
 
+		wait.HolderID = le.Info.ID
+		wait.HolderOperation = le.Info.Operation
+
 		// there's an existing lock, wait and try again
 		select {
 		case <-ctx.Done():
 			// return the last lock error with the info
+			wait.Error = err.Error()
 			return "", err
 		case <-time.After(delay):
 			if delay < maxDelay {
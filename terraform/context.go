@@ -120,7 +120,9 @@ func NewContext(opts *ContextOpts) (*Context, error) {
 		if err := checkRequiredVersion(opts.Module); err != nil {
 			return nil, err
 		}
+		dbug.writeModules(opts.Module)
 	}
+	dbug.writeProviderSchemas(opts.Module, opts.Providers)
 
 	// Copy all the hooks and add our stop hook. We don't append directly
 	// to the Config so that we're not modifying that in-place.
@@ -241,11 +243,12 @@ func (c *Context) Graph(typ GraphType, opts *ContextGraphOpts) (*Graph, error) {
 	case GraphTypePlan:
 		// Create the plan graph builder
 		p := &PlanGraphBuilder{
-			Module:    c.module,
-			State:     c.state,
-			Providers: c.components.ResourceProviders(),
-			Targets:   c.targets,
-			Validate:  opts.Validate,
+			Module:        c.module,
+			State:         c.state,
+			Providers:     c.components.ResourceProviders(),
+			Targets:       c.targets,
+			Validate:      opts.Validate,
+			RecordTargets: typ == GraphTypePlan,
 		}
 
 		// Some special cases for other graph types shared with plan currently
@@ -469,6 +472,11 @@ func (c *Context) Apply() (*State, error) {
 
 	// Copy our own state
 	c.state = c.state.DeepCopy()
+	dbug.recordPriorOutputs(c.state)
+	dbug.recordSerial(c.state)
+
+	// Record the planned changes to compare with what is applied
+	dbug.recordPlannedChanges(c.diff)
 
 	// Build the graph.
 	graph, err := c.Graph(GraphTypeApply, nil)
@@ -490,6 +498,12 @@ func (c *Context) Apply() (*State, error) {
 
 	// Clean out any unused things
 	c.state.prune()
+	dbug.recordOutputs(c.state)
+	dbug.recordSerial(c.state)
+	dbug.recordStateSnapshot(c.state)
+
+	// If debugging, reference the archive from the error
+	err = dbug.wrapError(err)
 
 	return c.state, err
 }
@@ -555,6 +569,9 @@ func (c *Context) Plan() (*Plan, error) {
 	}
 	p.Diff = c.diff
 
+	// Record how sensitive values flow through the configuration
+	dbug.writeSensitivity(c.module, c.diff)
+
 	// If this is true, it means we're running unit tests. In this case,
 	// we perform a deep copy just to ensure that all context tests also
 	// test that a diff is copy-able. This will panic if it fails. This
@@ -596,6 +613,8 @@ func (c *Context) Refresh() (*State, error) {
 
 	// Copy our own state
 	c.state = c.state.DeepCopy()
+	dbug.recordPriorOutputs(c.state)
+	dbug.recordSerial(c.state)
 
 	// Build the graph.
 	graph, err := c.Graph(GraphTypeRefresh, nil)
@@ -610,6 +629,9 @@ func (c *Context) Refresh() (*State, error) {
 
 	// Clean out any unused things
 	c.state.prune()
+	dbug.recordOutputs(c.state)
+	dbug.recordSerial(c.state)
+	dbug.recordStateSnapshot(c.state)
 
 	return c.state, nil
 }
@@ -8,9 +8,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/hashicorp/go-multierror"
 )
 
 // DebugInfo is the global handler for writing the debug archive. All methods
@@ -22,18 +27,41 @@ var dbug *debugInfo
 // provided directory. This must be called before any other terraform package
 // operations or not at all. Once his is called, CloseDebugInfo should be
 // called before program exit.
+//
+// The archive is only written if TF_DEBUG is set, and is configured by the
+// other TF_DEBUG_* environment variables. See DebugConfigFromEnv. Setting
+// TF_DEBUG_ESTIMATE instead only estimates the size of the archive, and
+// setting TF_DEBUG_ON_FAILURE instead only records the resources that fail.
+//
+// The effective settings are recorded in debug-config.json at the start of
+// the archive.
 func SetDebugInfo(path string) error {
-	if os.Getenv("TF_DEBUG") == "" {
+	if os.Getenv("TF_DEBUG") == "" && os.Getenv("TF_DEBUG_ESTIMATE") == "" &&
+		os.Getenv("TF_DEBUG_ON_FAILURE") == "" {
 		return nil
 	}
 
-	di, err := newDebugInfoFile(path)
+	cfg, err := DebugConfigFromEnv()
 	if err != nil {
 		return err
 	}
+	cfg.Dir = path
 
-	dbug = di
-	return nil
+	return SetDebugInfoConfig(cfg)
+}
+
+// SetDebugInfoWriter initializes the debug handler to write the archive to w,
+// rather than to a file. Unlike SetDebugInfo, this doesn't check TF_DEBUG,
+// but the other TF_DEBUG_* options apply. If w is a DebugStreamWriter, its
+// statistics are recorded in the archive.
+func SetDebugInfoWriter(w io.Writer) error {
+	cfg, err := DebugConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	cfg.Writer = w
+
+	return SetDebugInfoConfig(cfg)
 }
 
 // CloseDebugInfo is the exported interface to Close the debug info handler.
@@ -43,55 +71,195 @@ func CloseDebugInfo() error {
 	return dbug.Close()
 }
 
+// debugFileOptions are the options of an archive written to files by
+// newDebugInfoFile.
+type debugFileOptions struct {
+	// stream, if set, is written a copy of the archive.
+	stream *DebugStreamWriter
+
+	// atomic writes each part to a temporary file, renamed once the part is
+	// complete. See DebugConfig.Atomic.
+	atomic bool
+
+	// owner owns the entries of every part, and sorted writes them in
+	// sorted order on Close. See DebugConfig.Sorted.
+	owner  debugOwner
+	sorted bool
+
+	// file, if set, is the name the archive files are named for, rather
+	// than name, which is then only the root directory of the entries.
+	file string
+}
+
 // newDebugInfoFile initializes the global debug handler with a backing file in
-// the provided directory.
-func newDebugInfoFile(dir string) (*debugInfo, error) {
+// the provided directory, for the archive with the given name, written as
+// configured by opts.
+func newDebugInfoFile(dir, name string, opts debugFileOptions) (*debugInfo, error) {
 	err := os.MkdirAll(dir, 0755)
 	if err != nil {
 		return nil, err
 	}
 
-	// FIXME: not guaranteed unique, but good enough for now
-	name := fmt.Sprintf("debug-%s", time.Now().Format("2006-01-02-15-04-05.999999999"))
-	archivePath := filepath.Join(dir, name+".tar.gz")
+	file := name
+	if opts.file != "" {
+		file = opts.file
+	}
+	archivePath := filepath.Join(dir, debugPartFile(file, 0))
+
+	f, err := createDebugFile(archivePath, opts.atomic)
+	if err != nil {
+		return nil, err
+	}
 
-	f, err := os.OpenFile(archivePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	w := f
+	if opts.stream != nil {
+		w = &debugTeeWriter{Writer: f, stream: opts.stream}
+	}
+
+	d, err := newDebugInfoOwned(name, w, opts.owner, opts.sorted)
 	if err != nil {
 		return nil, err
 	}
-	return newDebugInfo(name, f)
+	d.path = archivePath
+	d.file = file
+	d.stream = opts.stream
+	d.atomic = opts.atomic
+
+	// subsequent parts are written alongside the first, and share the same
+	// archive root directory name so they extract to the same location.
+	d.nextPart = func(part int) (io.Writer, error) {
+		partPath := filepath.Join(dir, debugPartFile(file, part))
+		f, err := createDebugFile(partPath, opts.atomic)
+		if err != nil || opts.stream == nil {
+			return f, err
+		}
+		return &debugTeeWriter{Writer: f, stream: opts.stream}, nil
+	}
+
+	return d, nil
+}
+
+// debugArchiveName returns the name for a new archive, which is also the
+// archive root directory name. The correlation ID of the run is appended to
+// the name, if there is one.
+func debugArchiveName(correlationID string) string {
+	// FIXME: not guaranteed unique, but good enough for now
+	return debugArchiveNameAt(time.Now(), correlationID)
+}
+
+// debugArchiveNameAt returns the name of an archive created at t.
+func debugArchiveNameAt(t time.Time, correlationID string) string {
+	name := fmt.Sprintf("debug-%s", t.Format("2006-01-02-15-04-05.999999999"))
+	if correlationID != "" {
+		name += "-" + correlationID
+	}
+	return name
 }
 
 // newDebugInfo initializes the global debug handler.
 func newDebugInfo(name string, w io.Writer) (*debugInfo, error) {
+	return newDebugInfoOwned(name, w, debugOwner{}, false)
+}
+
+// newDebugInfoOwned initializes the global debug handler, with every entry of
+// the archive owned by owner, including those written before it's
+// configured. If sorted is set, the entries are held until Close, and
+// written in sorted order with fixed modification times.
+func newDebugInfoOwned(name string, w io.Writer, owner debugOwner, sorted bool) (*debugInfo, error) {
 	gz := gzip.NewWriter(w)
 
 	d := &debugInfo{
-		name: name,
-		w:    w,
-		gz:   gz,
-		tar:  tar.NewWriter(gz),
+		name:   name,
+		file:   name,
+		w:      w,
+		gz:     gz,
+		tar:    tar.NewWriter(gz),
+		owner:  owner,
+		sorted: sorted,
+	}
+
+	if err := d.writeHeader(); err != nil {
+		return nil, err
 	}
 
-	// create the subdirs we need
+	return d, nil
+}
+
+// writeHeader writes the format version and creates the subdirs we need at
+// the start of each archive part. The format version is always the first
+// file, so readers can determine the layout of the rest of the archive.
+func (d *debugInfo) writeHeader() error {
+	version := []byte(strconv.Itoa(debugArchiveFormatVersion) + "\n")
+	versionHdr := &tar.Header{
+		Name:    d.name + "/" + debugArchiveVersionFile,
+		Mode:    0644,
+		Size:    int64(len(version)),
+		ModTime: d.entryTime(),
+	}
 	topHdr := &tar.Header{
-		Name:     name,
+		Name:     d.name,
 		Typeflag: tar.TypeDir,
 		Mode:     0755,
 	}
 	graphsHdr := &tar.Header{
-		Name:     name + "/graphs",
+		Name:     d.name + "/graphs",
 		Typeflag: tar.TypeDir,
 		Mode:     0755,
 	}
+	dataHdr := &tar.Header{
+		Name:     d.name + "/datasources",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+	}
+	for _, hdr := range []*tar.Header{versionHdr, topHdr, graphsHdr, dataHdr} {
+		d.owner.apply(hdr)
+	}
+	if err := d.tar.WriteHeader(versionHdr); err != nil {
+		return err
+	}
+	if _, err := d.tar.Write(version); err != nil {
+		return err
+	}
+
 	err := d.tar.WriteHeader(topHdr)
-	// if the first errors, the second will too
+	// if the first errors, the others will too
 	err = d.tar.WriteHeader(graphsHdr)
+	err = d.tar.WriteHeader(dataHdr)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return d, nil
+	if d.label == "" && d.correlationID == "" {
+		return nil
+	}
+	return d.writeMeta()
+}
+
+// writeMeta writes the archive metadata entry with the current label and
+// correlation ID, which applies to the entries written after it. See
+// debugArchiveMetaFile.
+func (d *debugInfo) writeMeta() error {
+	js, err := json.Marshal(debugArchiveMeta{
+		Label:         d.label,
+		CorrelationID: d.correlationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	hdr := &tar.Header{
+		Name:    d.name + "/" + debugArchiveMetaFile,
+		Mode:    0644,
+		Size:    int64(len(js)),
+		ModTime: d.entryTime(),
+	}
+	d.owner.apply(hdr)
+	if err := d.tar.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err = d.tar.Write(js)
+	return err
 }
 
 // debugInfo provides various methods for writing debug information to a
@@ -113,6 +281,13 @@ type debugInfo struct {
 	// archive root directory name
 	name string
 
+	// file is the name the archive files are named for, which is the same
+	// as name unless the archive is sorted
+	file string
+
+	// path to the archive file, if it's written to a file
+	path string
+
 	// current operation phase
 	phase string
 
@@ -126,6 +301,256 @@ type debugInfo struct {
 	w   io.Writer
 	gz  *gzip.Writer
 	tar *tar.Writer
+
+	// fullFlushEvery is the number of entries between full flushes. A full
+	// flush ends the current gzip member and starts a new one, so everything
+	// written up to that point can be decoded without any of the data that
+	// follows, even if the process is killed mid-write. Each gzip member adds
+	// about 20 bytes of header and trailer, and discards the compression
+	// dictionary, so flushing after every entry can make an archive of many
+	// small files 30-50% larger. Zero disables full flushes.
+	fullFlushEvery int
+
+	// partEntries is the number of entries written to each archive part
+	// before it is closed and the next part is started. Closed parts are
+	// complete, valid archives regardless of what happens to the process
+	// afterwards. Zero disables parts. Parts require nextPart to be set.
+	partEntries int
+
+	// nextPart opens the writer for the given archive part number.
+	nextPart func(part int) (io.Writer, error)
+
+	// part is the current archive part number, and partCount is the number
+	// of entries written to the current part.
+	part      int
+	partCount int
+
+	// partEntryCounts is the number of entries written to each completed
+	// part, and partResources the instances with hook events in each part,
+	// for the index written to the last part.
+	partEntryCounts []int
+	partResources   []map[string]bool
+
+	// destroyOrder records the start and end of each destroy, and
+	// destroying tracks the destroys that are in progress.
+	destroyOrder []string
+	destroying   map[string]bool
+
+	// noops records whether every diff of each instance was empty
+	noops map[string]bool
+
+	// orphans records the ID of each resource removed from the
+	// configuration, by address
+	orphans map[string]string
+
+	// evalErrors records the errors evaluating interpolations
+	evalErrors []*debugEvalError
+
+	// refreshing records the ID of each instance being refreshed, and
+	// deletedUpstream the prior ID of each instance that was no longer
+	// found by its refresh, by address
+	refreshing      map[string]string
+	deletedUpstream map[string]string
+
+	// applyDiffs records the known attributes of each instance being
+	// applied, and partialStates the instances whose resulting state is
+	// tainted or missing some of them, despite no error, by address
+	applyDiffs    map[string]map[string]string
+	partialStates map[string]*debugPartialState
+
+	// computedDiffs records the attributes of each instance being applied
+	// that the diff marked computed, and whether they're sensitive, and
+	// computedValues the values they resolved to, by address
+	computedDiffs  map[string]map[string]bool
+	computedValues map[string]map[string]string
+
+	// priorOutputs records the root outputs before the run, and outputs
+	// their latest values, by name
+	priorOutputs map[string]*debugOutputValue
+	outputs      map[string]*debugOutputValue
+
+	// captureState enables the snapshot of the state, and stateSnapshot is
+	// the last snapshot recorded, serialized
+	captureState  bool
+	stateSnapshot []byte
+
+	// formatUpgrades counts the states whose format was upgraded when read
+	formatUpgrades int
+
+	// serial records the changes of the state's serial during the run
+	serial *debugSerialHistory
+
+	// deferrals records the nodes whose work was deferred to a later walk,
+	// in the order they were deferred
+	deferrals []*debugDeferral
+
+	// keyChurn records the prior and planned instance keys of each
+	// resource, by address
+	keyChurn map[string]*debugKeyChurn
+
+	// instanceIDs records the last ID of each instance applied or refreshed
+	instanceIDs map[string]*string
+
+	// diffExists records whether each instance existed when last diffed,
+	// and replacements the instances whose last diff replaced them
+	diffExists   map[string]bool
+	replacements map[string]*debugReplacement
+
+	// serializations records the resources whose apply was gated by a
+	// concurrency limit, in the order they were
+	serializations []*debugSerialization
+
+	// readiness tracks when each resource vertex was ready and started
+	readiness *debugReadiness
+
+	// graphCounts records the size of each graph written with WriteGraph
+	graphCounts []debugGraphCount
+
+	// graphOmit is the set of node categories omitted from filtered graphs
+	graphOmit map[string]bool
+
+	// graphSplit is how graphs are split into their components, if at all
+	graphSplit string
+
+	// omitPluginPaths leaves the plugin paths out of plugins.json
+	omitPluginPaths bool
+
+	// level is the default level of detail and phaseLevels the level for
+	// specific phases. summaryOnly is set while the level of the current
+	// phase is DebugLevelSummary.
+	level       string
+	phaseLevels map[string]string
+	summaryOnly bool
+
+	// memoryLimit is the heap size above which only summaries are written,
+	// if not zero, checked every memoryCheckInterval. memoryChecked is when
+	// it was last checked, memoryThrottled whether it was exceeded then, and
+	// memoryTransitions records each change.
+	memoryLimit         int
+	memoryCheckInterval time.Duration
+	memoryChecked       time.Time
+	memoryThrottled     bool
+	memoryTransitions   []debugMemoryTransition
+
+	// estimate tallies the archive size when it's only being estimated
+	estimate *debugEstimate
+
+	// outcome is the result of the command, recorded by SetDebugOutcome
+	outcome *debugOutcome
+
+	// argsWritten is set once args.txt has been written
+	argsWritten bool
+
+	// modulesWritten is set once modules.json has been written
+	modulesWritten bool
+
+	// maxFiles is the number of files after which no more per-resource
+	// files are written, if not zero, and maxFilesReached is set once a
+	// file has been dropped. files is the number of files written so far,
+	// which counts each file added to a bundle as it's added, rather than
+	// the bundle once it's written.
+	maxFiles        int
+	maxFilesReached bool
+	files           int
+
+	// bundleSize is the size of the bundles that per-resource files smaller
+	// than it are combined into, if not zero, and bundle the files
+	// accumulated since the last bundle was written
+	bundleSize int
+	bundle     *debugBundle
+
+	// owner is the ownership recorded for every entry written
+	owner debugOwner
+
+	// label is attached to every entry written, if set
+	label string
+
+	// correlationID identifies the run, and is attached to every entry
+	// written, if set
+	correlationID string
+
+	// atomic is set if the parts are renamed into place once complete
+	atomic bool
+
+	// sorted is set if the entries are held in sortedEntries until Close,
+	// then written sorted by name, after which sortedFlushed is set
+	sorted        bool
+	sortedEntries []*debugSortedEntry
+	sortedFlushed bool
+
+	// sortedPhases is the order the phases of a sorted archive began in
+	sortedPhases map[string]int
+
+	// rpc enables capturing the arguments and results of provider calls
+	rpc bool
+
+	// providerConfigs records the fingerprint of the last configuration of
+	// each provider, by address
+	providerConfigs map[string]*debugProviderFingerprint
+
+	// schemas enables writing the provider schemas, and providerSchemas
+	// are the schemas of the providers used, by name, which are read once
+	// per archive whether or not they're written, so the attributes they
+	// mark sensitive can be redacted. They're nil for providers that can't
+	// describe their schema.
+	schemas         bool
+	providerSchemas map[string]*DebugProviderSchema
+
+	// onFailure is set if only the events of failed resources are written,
+	// which are buffered in failures until then
+	onFailure bool
+	failures  *debugFailures
+
+	// recordWorkers enables the recording of worker assignments, and
+	// workers tracks the slots in use and the vertices they evaluated.
+	recordWorkers bool
+	workers       *debugWorkers
+
+	// audit forwards each hook event to an audit collector, if configured
+	audit *debugAudit
+
+	// lockWaits records each wait to acquire a state lock
+	lockWaits []DebugLockWait
+
+	// reconciliation compares the planned changes with the applies made
+	reconciliation *debugReconciliation
+
+	// applyTimeouts tracks the operation and configured timeout of each
+	// apply in progress, and timeouts records the applies that timed out.
+	applyTimeouts map[string]debugApplyTimeout
+	timeouts      []DebugTimeout
+
+	// schemaVersions records the schema version of each instance before
+	// and after it was refreshed or applied
+	schemaVersions map[string]*debugSchemaVersion
+
+	// statePersists summarizes each update of the state
+	statePersists []debugStatePersist
+
+	// applyGraph is the structure of the last apply graph written, for
+	// computing the critical path
+	applyGraph *debugPathGraph
+
+	// waves is how entries are organized by the wave of the apply graph
+	// their resource is in, if at all. resourceWaves is the wave of each
+	// resource node of applyGraph, applyWaves the wave of each instance
+	// being applied, by address, entryWave the wave of the entry being
+	// written, and waveEntries the entries written in a wave so far.
+	waves         string
+	resourceWaves map[debugWaveKey]int
+	applyWaves    map[string]int
+	entryWave     int
+	waveEntries   []debugWaveEntry
+
+	// timings records the duration of each hook operation and phase, and
+	// timingStarts and phaseStart record the operations in progress.
+	timings      []DebugTiming
+	timingStarts map[string]debugTimingStart
+	phaseStart   time.Time
+
+	// stream is the DebugStreamWriter receiving a copy of the archive, if
+	// the archive is being streamed to a collector.
+	stream *DebugStreamWriter
 }
 
 // Set the name of the current operational phase in the debug handler. Each file
@@ -138,10 +563,19 @@ func (d *debugInfo) SetPhase(phase string) {
 	d.Lock()
 	defer d.Unlock()
 
+	d.recordPhaseEnd()
 	d.phase = phase
+	d.summaryOnly = d.levelFor(phase) == DebugLevelSummary
+
+	// the context sets the phase to "INVALID" between runs, which isn't
+	// worth timing
+	if phase != "INVALID" {
+		d.phaseStart = time.Now()
+	}
 }
 
-// Close the debugInfo, finalizing the data in storage. This closes the
+// Close the debugInfo, finalizing the data in storage. Any summary files
+// accumulated during the run are written first, then this closes the
 // tar.Writer, the gzip.Wrtier, and if the output writer is an io.Closer, it is
 // also closed.
 func (d *debugInfo) Close() error {
@@ -157,6 +591,85 @@ func (d *debugInfo) Close() error {
 	}
 	d.closed = true
 
+	err := d.flushBundle()
+	d.renumberSorted()
+	if serr := d.writeSummaries(); err == nil {
+		err = serr
+	}
+	if serr := d.flushSorted(); err == nil {
+		err = serr
+	}
+	if oerr := d.writeOutcome(); err == nil {
+		err = oerr
+	}
+	if ierr := d.writePartIndex(); err == nil {
+		err = ierr
+	}
+	if cerr := d.closeWriters(); err == nil {
+		err = cerr
+	}
+	if eerr := d.writeEstimate(); err == nil {
+		err = eerr
+	}
+
+	// the stream outlives the individual archive parts
+	if d.stream != nil {
+		d.stream.Close()
+	}
+	return err
+}
+
+// writeSummaries writes the files that are accumulated over the course of
+// the run. This is called with the lock held, just before the archive is
+// closed. The summaries of wall clock times leave the times out of sorted
+// archives, since they differ between identical runs.
+func (d *debugInfo) writeSummaries() error {
+	var err error
+	for _, f := range []func() error{
+		d.writeComputedValues,
+		d.writeDeferrals,
+		d.writeDeletedUpstream,
+		d.writeDestroyOrder,
+		d.writeEvalErrors,
+		d.writeFailures,
+		d.writeGraphCounts,
+		d.writeInstanceIDs,
+		d.writeKeyChurn,
+		d.writeMemoryPressure,
+		d.writeLockWaits,
+		d.writeNoops,
+		d.writeOrphans,
+		d.writeOutputsChanged,
+		d.writePartialStates,
+		d.writeProviderConfigs,
+		d.writeReadiness,
+		d.writeReconciliation,
+		d.writeReplacements,
+		d.writeSchemaVersions,
+		d.writeSerialHistory,
+		d.writeStateSnapshot,
+		d.writeSerialization,
+		d.writeStatePersists,
+		d.writeTimeouts,
+		d.writeWaves,
+		d.writeWaveEntries,
+		d.writeTimings,
+		d.writeCriticalPath,
+		d.writeQueueing,
+		d.writeWorkerAssignments,
+		d.writeStreamStats,
+		d.writeAuditStats,
+	} {
+		if ferr := f(); ferr != nil {
+			err = multierror.Append(err, ferr)
+		}
+	}
+
+	return err
+}
+
+// closeWriters finalizes the current archive part.
+func (d *debugInfo) closeWriters() error {
 	d.tar.Close()
 	d.gz.Close()
 
@@ -166,6 +679,30 @@ func (d *debugInfo) Close() error {
 	return nil
 }
 
+// rotate closes the current archive part and starts the next. The last entry
+// of each closed part points to the next.
+func (d *debugInfo) rotate() error {
+	if err := d.writeNextPart(); err != nil {
+		return err
+	}
+	if err := d.closeWriters(); err != nil {
+		return err
+	}
+
+	w, err := d.nextPart(d.part + 1)
+	if err != nil {
+		return err
+	}
+
+	d.part++
+	d.partCount = 0
+	d.w = w
+	d.gz.Reset(w)
+	d.tar = tar.NewWriter(d.gz)
+
+	return d.writeHeader()
+}
+
 // debug buffer is an io.WriteCloser that will write itself to the debug
 // archive when closed.
 type debugBuffer struct {
@@ -189,9 +726,12 @@ func (nopWriteCloser) Write([]byte) (int, error) { return 0, nil }
 func (nopWriteCloser) Close() error              { return nil }
 
 // NewFileWriter returns an io.WriteClose that will be buffered and written to
-// the debug archive when closed.
+// the debug archive when closed. This is used for the logs of graph builds
+// and walks, which are left out of sorted archives, since they identify
+// vertices by their address in memory, and record the order concurrent
+// vertices were visited in.
 func (d *debugInfo) NewFileWriter(name string) io.WriteCloser {
-	if d == nil {
+	if d == nil || d.sorted {
 		return nopWriteCloser{}
 	}
 
@@ -213,7 +753,14 @@ type flusher interface {
 // on the output writer if they are available.
 func (d *debugInfo) flush() {
 	d.tar.Flush()
-	d.gz.Flush()
+
+	if d.fullFlushEvery > 0 && d.partCount%d.fullFlushEvery == 0 {
+		// end this gzip member, and start a new one on the same writer
+		d.gz.Close()
+		d.gz.Reset(d.w)
+	} else {
+		d.gz.Flush()
+	}
 
 	if f, ok := d.w.(flusher); ok {
 		f.Flush()
@@ -232,19 +779,43 @@ func (d *debugInfo) WriteFile(name string, data []byte) error {
 
 	d.Lock()
 	defer d.Unlock()
+
+	// nothing more can be written once the archive is finalized
+	if d.closed {
+		return nil
+	}
 	return d.writeFile(name, data)
 }
 
+// writeFile writes data to the archive, prefixing the file name with the step
+// and phase. The name may include a subdirectory, i.e. "graphs/plan.dot".
 func (d *debugInfo) writeFile(name string, data []byte) error {
+	// the files in a bundle were counted as they were added to it
+	if name != debugBundleFile {
+		d.files++
+	}
+
+	if d.sorted && !d.sortedFlushed {
+		d.sortEntry(name, data)
+		return nil
+	}
+
 	defer d.flush()
-	path := fmt.Sprintf("%s/%d-%s-%s", d.name, d.step, d.phase, name)
-	d.step++
+	if err := d.writeEntry(name, data); err != nil {
+		return err
+	}
+	d.partCount++
 
-	hdr := &tar.Header{
-		Name: path,
-		Mode: 0644,
-		Size: int64(len(data)),
+	if d.partEntries > 0 && d.nextPart != nil && d.partCount >= d.partEntries {
+		return d.rotate()
 	}
+	return nil
+}
+
+// writeEntry writes a single entry to the archive, with the step and phase
+// prefix, without flushing or counting it towards the current part.
+func (d *debugInfo) writeEntry(name string, data []byte) error {
+	hdr := d.entryHeader(name, data)
 	err := d.tar.WriteHeader(hdr)
 	if err != nil {
 		return err
@@ -254,11 +825,36 @@ func (d *debugInfo) writeFile(name string, data []byte) error {
 	return err
 }
 
+// entryHeader returns the tar header of the next entry, named with the step
+// and phase prefix, and advances the step.
+func (d *debugInfo) entryHeader(name string, data []byte) *tar.Header {
+	hdr := d.entryHeaderAt(name, d.phase, d.step, len(data))
+	d.recordEntryWave(name)
+	d.step++
+	d.recordEstimate(data)
+	return hdr
+}
+
+// entryHeaderAt returns the tar header of an entry of size bytes, named with
+// the given step and phase prefix.
+func (d *debugInfo) entryHeaderAt(name, phase string, step, size int) *tar.Header {
+	dir, file := path.Split(name)
+	hdr := &tar.Header{
+		Name:    fmt.Sprintf("%s/%s%d-%s-%s", d.name, dir, step, phase, file),
+		Mode:    0644,
+		Size:    int64(size),
+		ModTime: d.entryTime(),
+	}
+	d.owner.apply(hdr)
+	return hdr
+}
+
 // DebugHook implements all methods of the terraform.Hook interface, and writes
 // the arguments to a file in the archive. When a suitable format for the
 // argument isn't available, the argument is encoded using json.Marshal. If the
 // debug handler is nil, all DebugHook methods are noop, so no time is spent in
-// marshaling the data structures.
+// marshaling the data structures. If an audit collector is configured, each
+// event is also forwarded to it before the hook returns. See DebugConfig.
 type DebugHook struct{}
 
 func (*DebugHook) PreApply(ii *InstanceInfo, is *InstanceState, id *InstanceDiff) (HookAction, error) {
@@ -286,9 +882,19 @@ func (*DebugHook) PreApply(ii *InstanceInfo, is *InstanceState, id *InstanceDiff
 	}
 	buf.Write(js)
 
-	dbug.WriteFile("hook-PreApply", buf.Bytes())
+	dbug.recordWaveStart(ii, id)
+	dbug.writeHookFile(ii, "hook-PreApply", buf.Bytes())
+	dbug.recordTimingStart("apply", ii)
+	dbug.recordTimeoutStart(ii, is, id)
+	dbug.recordSchemaBefore(ii, is)
+	dbug.recordApplyDiff(ii, id)
+	dbug.recordComputedDiff(ii, id)
 
-	return HookActionContinue, nil
+	if id != nil && id.GetDestroy() {
+		dbug.recordDestroyStart(ii.HumanId())
+	}
+
+	return dbug.auditHook(ii, "PreApply", buf.Bytes())
 }
 
 func (*DebugHook) PostApply(ii *InstanceInfo, is *InstanceState, err error) (HookAction, error) {
@@ -310,9 +916,19 @@ func (*DebugHook) PostApply(ii *InstanceInfo, is *InstanceState, err error) (Hoo
 		buf.WriteString(err.Error())
 	}
 
-	dbug.WriteFile("hook-PostApply", buf.Bytes())
-
-	return HookActionContinue, nil
+	dbug.recordTimingEnd("apply", ii)
+	dbug.writeHookFile(ii, "hook-PostApply", buf.Bytes())
+	dbug.recordWaveEnd(ii)
+	dbug.recordDestroyEnd(ii.HumanId(), err)
+	dbug.recordApply(err)
+	dbug.recordTimeoutEnd(ii, err)
+	dbug.recordApplyResult(ii, err)
+	dbug.recordInstanceID(ii, is)
+	dbug.recordSchemaAfter(ii, is)
+	dbug.recordPartialState(ii, is, err)
+	dbug.recordComputedValues(ii, is)
+
+	return dbug.auditHook(ii, "PostApply", buf.Bytes())
 }
 
 func (*DebugHook) PreDiff(ii *InstanceInfo, is *InstanceState) (HookAction, error) {
@@ -329,9 +945,11 @@ func (*DebugHook) PreDiff(ii *InstanceInfo, is *InstanceState) (HookAction, erro
 		buf.WriteString(is.String())
 		buf.WriteString("\n")
 	}
-	dbug.WriteFile("hook-PreDiff", buf.Bytes())
+	dbug.writeHookFile(ii, "hook-PreDiff", buf.Bytes())
+	dbug.recordTimingStart("diff", ii)
+	dbug.recordDiffState(ii, is)
 
-	return HookActionContinue, nil
+	return dbug.auditHook(ii, "PreDiff", buf.Bytes())
 }
 
 func (*DebugHook) PostDiff(ii *InstanceInfo, id *InstanceDiff) (HookAction, error) {
@@ -354,9 +972,12 @@ func (*DebugHook) PostDiff(ii *InstanceInfo, id *InstanceDiff) (HookAction, erro
 	}
 	buf.Write(js)
 
-	dbug.WriteFile("hook-PostDiff", buf.Bytes())
+	dbug.recordTimingEnd("diff", ii)
+	dbug.recordDiff(ii, id)
+	dbug.recordReplacement(ii, id)
+	dbug.writeHookFile(ii, "hook-PostDiff", buf.Bytes())
 
-	return HookActionContinue, nil
+	return dbug.auditHook(ii, "PostDiff", buf.Bytes())
 }
 
 func (*DebugHook) PreProvisionResource(ii *InstanceInfo, is *InstanceState) (HookAction, error) {
@@ -373,9 +994,10 @@ func (*DebugHook) PreProvisionResource(ii *InstanceInfo, is *InstanceState) (Hoo
 		buf.WriteString(is.String())
 		buf.WriteString("\n")
 	}
-	dbug.WriteFile("hook-PreProvisionResource", buf.Bytes())
+	dbug.writeHookFile(ii, "hook-PreProvisionResource", buf.Bytes())
+	dbug.recordTimingStart("provision", ii)
 
-	return HookActionContinue, nil
+	return dbug.auditHook(ii, "PreProvisionResource", buf.Bytes())
 }
 
 func (*DebugHook) PostProvisionResource(ii *InstanceInfo, is *InstanceState) (HookAction, error) {
@@ -393,8 +1015,9 @@ func (*DebugHook) PostProvisionResource(ii *InstanceInfo, is *InstanceState) (Ho
 		buf.WriteString(is.String())
 		buf.WriteString("\n")
 	}
-	dbug.WriteFile("hook-PostProvisionResource", buf.Bytes())
-	return HookActionContinue, nil
+	dbug.recordTimingEnd("provision", ii)
+	dbug.writeHookFile(ii, "hook-PostProvisionResource", buf.Bytes())
+	return dbug.auditHook(ii, "PostProvisionResource", buf.Bytes())
 }
 
 func (*DebugHook) PreProvision(ii *InstanceInfo, s string) (HookAction, error) {
@@ -409,8 +1032,8 @@ func (*DebugHook) PreProvision(ii *InstanceInfo, s string) (HookAction, error) {
 	}
 	buf.WriteString(s + "\n")
 
-	dbug.WriteFile("hook-PreProvision", buf.Bytes())
-	return HookActionContinue, nil
+	dbug.writeHookFile(ii, "hook-PreProvision", buf.Bytes())
+	return dbug.auditHook(ii, "PreProvision", buf.Bytes())
 }
 
 func (*DebugHook) PostProvision(ii *InstanceInfo, s string, err error) (HookAction, error) {
@@ -424,8 +1047,8 @@ func (*DebugHook) PostProvision(ii *InstanceInfo, s string, err error) (HookActi
 	}
 	buf.WriteString(s + "\n")
 
-	dbug.WriteFile("hook-PostProvision", buf.Bytes())
-	return HookActionContinue, nil
+	dbug.writeHookFile(ii, "hook-PostProvision", buf.Bytes())
+	return dbug.auditHook(ii, "PostProvision", buf.Bytes())
 }
 
 func (*DebugHook) ProvisionOutput(ii *InstanceInfo, s1 string, s2 string) {
@@ -441,7 +1064,11 @@ func (*DebugHook) ProvisionOutput(ii *InstanceInfo, s1 string, s2 string) {
 	buf.WriteString(s1 + "\n")
 	buf.WriteString(s2 + "\n")
 
-	dbug.WriteFile("hook-ProvisionOutput", buf.Bytes())
+	dbug.writeHookFile(ii, "hook-ProvisionOutput", buf.Bytes())
+
+	// output can't halt the provisioner, so delivery failures are only
+	// recorded
+	dbug.auditHook(ii, "ProvisionOutput", buf.Bytes())
 }
 
 func (*DebugHook) PreRefresh(ii *InstanceInfo, is *InstanceState) (HookAction, error) {
@@ -458,8 +1085,11 @@ func (*DebugHook) PreRefresh(ii *InstanceInfo, is *InstanceState) (HookAction, e
 		buf.WriteString(is.String())
 		buf.WriteString("\n")
 	}
-	dbug.WriteFile("hook-PreRefresh", buf.Bytes())
-	return HookActionContinue, nil
+	dbug.writeHookFile(ii, "hook-PreRefresh", buf.Bytes())
+	dbug.recordTimingStart("refresh", ii)
+	dbug.recordSchemaBefore(ii, is)
+	dbug.recordRefreshBefore(ii, is)
+	return dbug.auditHook(ii, "PreRefresh", buf.Bytes())
 }
 
 func (*DebugHook) PostRefresh(ii *InstanceInfo, is *InstanceState) (HookAction, error) {
@@ -477,8 +1107,12 @@ func (*DebugHook) PostRefresh(ii *InstanceInfo, is *InstanceState) (HookAction,
 		buf.WriteString(is.String())
 		buf.WriteString("\n")
 	}
-	dbug.WriteFile("hook-PostRefresh", buf.Bytes())
-	return HookActionContinue, nil
+	dbug.recordTimingEnd("refresh", ii)
+	dbug.writeHookFile(ii, "hook-PostRefresh", buf.Bytes())
+	dbug.recordInstanceID(ii, is)
+	dbug.recordSchemaAfter(ii, is)
+	dbug.recordRefreshAfter(ii, is)
+	return dbug.auditHook(ii, "PostRefresh", buf.Bytes())
 }
 
 func (*DebugHook) PreImportState(ii *InstanceInfo, s string) (HookAction, error) {
@@ -493,8 +1127,8 @@ func (*DebugHook) PreImportState(ii *InstanceInfo, s string) (HookAction, error)
 	}
 	buf.WriteString(s + "\n")
 
-	dbug.WriteFile("hook-PreImportState", buf.Bytes())
-	return HookActionContinue, nil
+	dbug.writeHookFile(ii, "hook-PreImportState", buf.Bytes())
+	return dbug.auditHook(ii, "PreImportState", buf.Bytes())
 }
 
 func (*DebugHook) PostImportState(ii *InstanceInfo, iss []*InstanceState) (HookAction, error) {
@@ -513,11 +1147,52 @@ func (*DebugHook) PostImportState(ii *InstanceInfo, iss []*InstanceState) (HookA
 			buf.WriteString(is.String() + "\n")
 		}
 	}
-	dbug.WriteFile("hook-PostImportState", buf.Bytes())
-	return HookActionContinue, nil
+	dbug.writeHookFile(ii, "hook-PostImportState", buf.Bytes())
+	return dbug.auditHook(ii, "PostImportState", buf.Bytes())
+}
+
+// writeHookFile writes the data for a hook event on the given instance, and
+// records the instance in the index of the current archive part.
+func (d *debugInfo) writeHookFile(ii *InstanceInfo, name string, data []byte) error {
+	if d == nil {
+		return nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.recordPartResource(ii)
+	if d.underMemoryPressure() {
+		return nil
+	}
+	if d.onFailure && ii != nil {
+		d.bufferHookFile(ii, name, data)
+		return nil
+	}
+	if d.summaryOnly {
+		return nil
+	}
+	return d.writeInstanceFile(ii, debugHookFile(ii, name), data)
+}
+
+// debugHookFile returns the archive file name for a hook event on the given
+// instance. Events for data sources are kept in the datasources directory,
+// so they can be examined separately from managed resources.
+func debugHookFile(ii *InstanceInfo, name string) string {
+	if ii != nil && strings.HasPrefix(ii.Id, "data.") {
+		return "datasources/" + name
+	}
+	return name
 }
 
-// skip logging this for now, since it could be huge
-func (*DebugHook) PostStateUpdate(*State) (HookAction, error) {
+// PostStateUpdate only records a summary of the state, its serial, and the
+// values of the root outputs, since the full state could be huge.
+func (*DebugHook) PostStateUpdate(s *State) (HookAction, error) {
+	dbug.recordStatePersist(s)
+	dbug.recordSerial(s)
+	dbug.recordOutputs(s)
 	return HookActionContinue, nil
 }
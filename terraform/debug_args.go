@@ -0,0 +1,172 @@
+package terraform
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// debugSecretName matches the names of variables, backend settings and
+// attributes whose values are likely to be credentials. It's shared by
+// everything in the debug archive that redacts values by name.
+var debugSecretName = regexp.MustCompile(
+	`(?i)(pass|secret|token|key|credential|private|auth|cert|session)`)
+
+// debugSecretAttr returns the part of the flattened attribute name k that's
+// named like a secret, i.e. "assume_role.0.token" for
+// "assume_role.0.token.value", or empty if it isn't a secret.
+func debugSecretAttr(k string) string {
+	parts := strings.Split(k, ".")
+	for i, p := range parts {
+		if debugSecretName.MatchString(p) {
+			return strings.Join(parts[:i+1], ".")
+		}
+	}
+	return ""
+}
+
+// debugShellSafe matches arguments that don't need quoting in args.txt.
+var debugShellSafe = regexp.MustCompile(`^[A-Za-z0-9_./=:@%+,-]+$`)
+
+var (
+	debugArgsLock sync.Mutex
+	debugArgs     []string
+)
+
+// SetDebugArgs records the command line arguments the CLI was invoked with,
+// not including the program name, so that they're written to args.txt of
+// the debug archive. Like SetDebugPlugins, this may be called before or
+// after SetDebugInfo. The values of -var and -backend-config settings that
+// look like credentials are redacted before they're recorded.
+func SetDebugArgs(args []string) error {
+	sanitized := debugSanitizeArgs(args)
+
+	debugArgsLock.Lock()
+	debugArgs = sanitized
+	debugArgsLock.Unlock()
+
+	return dbug.writeArgs()
+}
+
+// writeArgs writes the recorded arguments to args.txt as a single shell
+// quoted command line. The arguments are only written once per archive,
+// since they can't change during a run.
+func (d *debugInfo) writeArgs() error {
+	if d == nil {
+		return nil
+	}
+
+	debugArgsLock.Lock()
+	args := debugArgs
+	debugArgsLock.Unlock()
+
+	if args == nil {
+		return nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.argsWritten {
+		return nil
+	}
+	d.argsWritten = true
+
+	words := []string{"terraform"}
+	for _, arg := range args {
+		words = append(words, debugShellQuote(arg))
+	}
+
+	return d.writeFile("args.txt", []byte(strings.Join(words, " ")+"\n"))
+}
+
+// debugSanitizeArgs returns a copy of args with the values of any
+// credential-like -var and -backend-config settings replaced with
+// "<redacted>". Both the "-var=k=v" and "-var k=v" forms are handled.
+func debugSanitizeArgs(args []string) []string {
+	result := make([]string, len(args))
+	copy(result, args)
+
+	for i := 0; i < len(result); i++ {
+		flag := strings.TrimLeft(result[i], "-")
+		if flag == result[i] {
+			continue
+		}
+
+		value, inline := "", false
+		if idx := strings.Index(flag, "="); idx >= 0 {
+			flag, value, inline = flag[:idx], flag[idx+1:], true
+		}
+		if flag != "var" && flag != "backend-config" {
+			continue
+		}
+		if !inline {
+			if i+1 >= len(result) {
+				break
+			}
+			i++
+			value = result[i]
+		}
+
+		redacted, ok := debugRedactSetting(value)
+		if !ok {
+			continue
+		}
+		if inline {
+			result[i] = strings.TrimSuffix(result[i], value) + redacted
+		} else {
+			result[i] = redacted
+		}
+	}
+
+	return result
+}
+
+// debugRedactSetting redacts the value of a key=value setting if it looks
+// like a credential, returning false if it doesn't. Settings without a key,
+// such as the path to a backend config file, are left as is.
+func debugRedactSetting(setting string) (string, bool) {
+	idx := strings.Index(setting, "=")
+	if idx < 0 {
+		return setting, false
+	}
+
+	k, v := setting[:idx], setting[idx+1:]
+	if !debugSecretName.MatchString(k) && !debugSecretValue(v) {
+		return setting, false
+	}
+
+	return k + "=<redacted>", true
+}
+
+// debugSecretValue reports whether a value looks like a generated
+// credential: a long string without whitespace that mixes letters and
+// digits.
+func debugSecretValue(v string) bool {
+	if len(v) < 20 {
+		return false
+	}
+
+	letters, digits := false, false
+	for _, r := range v {
+		switch {
+		case unicode.IsSpace(r):
+			return false
+		case unicode.IsLetter(r):
+			letters = true
+		case unicode.IsDigit(r):
+			digits = true
+		}
+	}
+
+	return letters && digits
+}
+
+// debugShellQuote quotes arg for a POSIX shell, if required.
+func debugShellQuote(arg string) string {
+	if debugShellSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
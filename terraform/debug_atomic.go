@@ -0,0 +1,51 @@
+package terraform
+
+import (
+	"io"
+	"os"
+)
+
+// debugTempSuffix is appended to the path of an archive part while it's
+// being written atomically.
+const debugTempSuffix = ".tmp"
+
+// debugAtomicFile is an archive part written to a temporary sibling of its
+// path, which is renamed to the path once the part is closed, so the path
+// only ever holds a complete part.
+type debugAtomicFile struct {
+	*os.File
+
+	path string
+}
+
+func (f *debugAtomicFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.File.Name(), f.path)
+}
+
+// createDebugFile creates the file of an archive part at path. If atomic is
+// set, the part is written to a temporary sibling of path instead, and only
+// renamed to path once it's closed.
+func createDebugFile(path string, atomic bool) (io.Writer, error) {
+	if !atomic {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	// the rename would replace an existing archive, which is never
+	// overwritten when writing in place either
+	if _, err := os.Lstat(path); err == nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrExist}
+	}
+
+	f, err := os.OpenFile(path+debugTempSuffix, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &debugAtomicFile{File: f, path: path}, nil
+}
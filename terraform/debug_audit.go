@@ -0,0 +1,177 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// debugAuditDefaultTimeout is the default time allowed for the audit
+// collector to acknowledge each event.
+const debugAuditDefaultTimeout = 10 * time.Second
+
+// DebugAuditEvent is the body of the request sent to the audit collector for
+// each DebugHook event.
+type DebugAuditEvent struct {
+	// Event is the name of the hook method, i.e. "PreApply".
+	Event string
+
+	// Resource is the human readable address of the instance.
+	Resource string
+
+	// Phase is the operational phase the event occurred in.
+	Phase string
+
+	// CorrelationID identifies the run. See DebugConfig.CorrelationID.
+	CorrelationID string `json:",omitempty"`
+
+	Time time.Time
+
+	// Data is the same content written to the hook file in the archive.
+	Data string
+}
+
+// debugAuditStats is the content of audit.json.
+type debugAuditStats struct {
+	Address  string
+	Critical bool
+	Timeout  time.Duration
+
+	// Sent is the number of events acknowledged by the collector, and
+	// Failed the number that weren't.
+	Sent   int
+	Failed int
+
+	// LastError is the most recent delivery error, if any.
+	LastError string `json:",omitempty"`
+}
+
+// debugAudit forwards hook events to an audit collector. Unlike the stream,
+// each event is sent synchronously, and the hook doesn't return until the
+// collector has acknowledged it with a 2xx response.
+type debugAudit struct {
+	url    string
+	client *http.Client
+
+	sync.Mutex
+	stats debugAuditStats
+}
+
+func newDebugAudit(addr string, timeout time.Duration, critical bool) *debugAudit {
+	if timeout == 0 {
+		timeout = debugAuditDefaultTimeout
+	}
+
+	client := cleanhttp.DefaultClient()
+	client.Timeout = timeout
+
+	return &debugAudit{
+		url:    addr,
+		client: client,
+		stats: debugAuditStats{
+			Address:  debugRedactAddr(addr),
+			Critical: critical,
+			Timeout:  timeout,
+		},
+	}
+}
+
+// validateDebugAuditAddr checks that addr is an http or https URL.
+func validateDebugAuditAddr(addr string) error {
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid debug audit address %q: must be an http or https URL", addr)
+	}
+	return nil
+}
+
+// send posts a single event, returning an error unless it was acknowledged.
+func (a *debugAudit) send(event *DebugAuditEvent) error {
+	js, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.client.Post(a.url, "application/json", bytes.NewReader(js))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			err = fmt.Errorf("audit collector returned %s", resp.Status)
+		}
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	if err != nil {
+		a.stats.Failed++
+		a.stats.LastError = err.Error()
+		return err
+	}
+	a.stats.Sent++
+	return nil
+}
+
+// auditHook forwards a hook event to the audit collector, if one is
+// configured, returning the result for the hook. If the event can't be
+// delivered and auditing is critical, the hook halts the operation with the
+// delivery error. Otherwise the failure is only recorded in audit.json.
+func (d *debugInfo) auditHook(ii *InstanceInfo, event string, data []byte) (HookAction, error) {
+	if d == nil {
+		return HookActionContinue, nil
+	}
+
+	// the request is made without holding the lock, so that other hooks
+	// aren't blocked by a slow collector
+	d.Lock()
+	a := d.audit
+	phase := d.phase
+	correlationID := d.correlationID
+	d.Unlock()
+	if a == nil {
+		return HookActionContinue, nil
+	}
+
+	ev := &DebugAuditEvent{
+		Event:         event,
+		Phase:         phase,
+		CorrelationID: correlationID,
+		Time:          time.Now(),
+		Data:          string(data),
+	}
+	if ii != nil {
+		ev.Resource = ii.HumanId()
+	}
+
+	if err := a.send(ev); err != nil && a.stats.Critical {
+		return HookActionHalt, fmt.Errorf(
+			"failed to deliver %s event for %s to the audit collector: %s",
+			event, ev.Resource, err)
+	}
+
+	return HookActionContinue, nil
+}
+
+// writeAuditStats writes audit.json, recording how many events were
+// delivered to the audit collector. The lock must be held.
+func (d *debugInfo) writeAuditStats() error {
+	if d.audit == nil {
+		return nil
+	}
+
+	d.audit.Lock()
+	stats := d.audit.stats
+	d.audit.Unlock()
+
+	js, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("audit.json", js)
+}
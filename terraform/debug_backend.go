@@ -0,0 +1,59 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// DebugStateLocation describes where the state for a run is stored, as
+// recorded in the state-location.json file of the debug archive.
+type DebugStateLocation struct {
+	// Workspace is the name of the environment the state belongs to.
+	Workspace string
+
+	// Location identifies where the backend stores the state, i.e.
+	// "s3:bucket/env:/dev/terraform.tfstate". It must not contain any
+	// credentials.
+	Location string
+}
+
+var (
+	debugStateLocationLock sync.Mutex
+	debugStateLocation     *DebugStateLocation
+)
+
+// SetDebugStateLocation records where the state is stored so that it's
+// written to the debug archive. Like SetDebugPlugins, this may be called
+// before or after SetDebugInfo. Any userinfo or query parameters in a URL
+// location are removed as a precaution.
+func SetDebugStateLocation(loc DebugStateLocation) error {
+	loc.Location = debugRedactAddr(loc.Location)
+
+	debugStateLocationLock.Lock()
+	debugStateLocation = &loc
+	debugStateLocationLock.Unlock()
+
+	return dbug.writeStateLocation()
+}
+
+// writeStateLocation writes the recorded location to state-location.json.
+func (d *debugInfo) writeStateLocation() error {
+	if d == nil {
+		return nil
+	}
+
+	debugStateLocationLock.Lock()
+	loc := debugStateLocation
+	debugStateLocationLock.Unlock()
+
+	if loc == nil {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(loc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.WriteFile("state-location.json", js)
+}
@@ -0,0 +1,130 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// debugBundleFile is the name of the entries bundling small per-resource
+// files together. Bundles are unpacked by DebugArchiveReader, so they never
+// appear as files themselves.
+const debugBundleFile = "bundles/files.bundle"
+
+// debugBundleEntry is the index entry of a file in a bundle. Offset is
+// relative to the end of the index.
+type debugBundleEntry struct {
+	Name    string
+	Step    int
+	Phase   string
+	ModTime time.Time
+	Offset  int
+	Size    int
+}
+
+// debugBundle accumulates small files until they're written to the archive
+// as a single bundle entry. Each tar entry has a 512 byte header, padding to
+// a multiple of 512 bytes, and with full flushes a gzip member of its own, so
+// bundling the thousands of small hook events of a wide graph makes the
+// archive considerably smaller.
+//
+// A bundle is written as a single line of JSON, the index, followed by the
+// content of its files, concatenated in the order they were written.
+type debugBundle struct {
+	index []debugBundleEntry
+	data  bytes.Buffer
+}
+
+// bundleFile adds a file to the current bundle, with the step and phase it
+// would otherwise have been written at, writing the bundle once it's at least
+// bundleSize bytes. The lock must be held.
+func (d *debugInfo) bundleFile(name string, data []byte) error {
+	if d.bundle == nil {
+		d.bundle = &debugBundle{}
+	}
+	d.recordEntryWave(name)
+
+	d.bundle.index = append(d.bundle.index, debugBundleEntry{
+		Name:    name,
+		Step:    d.step,
+		Phase:   d.phase,
+		ModTime: d.entryTime(),
+		Offset:  d.bundle.data.Len(),
+		Size:    len(data),
+	})
+	d.bundle.data.Write(data)
+	d.step++
+	d.files++
+
+	if d.bundle.data.Len() < d.bundleSize {
+		return nil
+	}
+	return d.flushBundle()
+}
+
+// flushBundle writes the files accumulated in the current bundle, if any, as
+// a single entry. The lock must be held.
+func (d *debugInfo) flushBundle() error {
+	if d.bundle == nil || len(d.bundle.index) == 0 {
+		return nil
+	}
+	b := d.bundle
+	d.bundle = nil
+
+	index, err := json.Marshal(b.index)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(index) + 1 + b.data.Len())
+	buf.Write(index)
+	buf.WriteByte('\n')
+	buf.Write(b.data.Bytes())
+
+	return d.writeFile(debugBundleFile, buf.Bytes())
+}
+
+// isDebugBundle returns whether the file read from an archive is a bundle.
+func isDebugBundle(version int, f *DebugArchiveFile) bool {
+	return version >= 3 && f.Name == debugBundleFile
+}
+
+// unbundleDebugFiles returns the files in a bundle read from an archive.
+// They take the label and correlation ID of the bundle itself, which applied
+// to the bundle when it was written.
+func unbundleDebugFiles(bundle *DebugArchiveFile) ([]*DebugArchiveFile, error) {
+	i := bytes.IndexByte(bundle.Data, '\n')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid debug bundle at step %d: no index", bundle.Step)
+	}
+
+	var index []debugBundleEntry
+	if err := json.Unmarshal(bundle.Data[:i], &index); err != nil {
+		return nil, fmt.Errorf("invalid debug bundle index at step %d: %s", bundle.Step, err)
+	}
+
+	data := bundle.Data[i+1:]
+	result := make([]*DebugArchiveFile, len(index))
+	for i, e := range index {
+		if e.Offset < 0 || e.Size < 0 || e.Offset+e.Size > len(data) ||
+			strings.HasPrefix(e.Name, "/") {
+			return nil, fmt.Errorf(
+				"invalid debug bundle entry %q at step %d", e.Name, bundle.Step)
+		}
+
+		result[i] = &DebugArchiveFile{
+			Name:          e.Name,
+			Step:          e.Step,
+			Phase:         e.Phase,
+			ModTime:       e.ModTime,
+			Label:         bundle.Label,
+			CorrelationID: bundle.CorrelationID,
+			Data:          data[e.Offset : e.Offset+e.Size],
+		}
+	}
+
+	return result, nil
+}
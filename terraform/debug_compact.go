@@ -0,0 +1,108 @@
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DebugCompaction describes the result of CompactDebugArchive.
+type DebugCompaction struct {
+	// Graphs is the number of graphs read, and Removed the number that were
+	// left out as near duplicates, totalling RemovedBytes.
+	Graphs       int
+	Removed      int
+	RemovedBytes int64
+}
+
+// CompactDebugArchive copies the debug archive read from r to w, leaving out
+// graphs that are nearly identical to the last kept graph of the same name.
+// Two graphs are near duplicates when the similarity of their lines, from 0
+// for graphs with nothing in common to 1 for identical graphs, is at least
+// threshold. The first graph of each name is always kept. Everything else is
+// copied unchanged, with the step and phase it was originally written at, so
+// the steps of the removed graphs are left as gaps.
+func CompactDebugArchive(r io.Reader, w io.Writer, threshold float64) (*DebugCompaction, error) {
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("invalid similarity threshold %v, must be between 0 and 1", threshold)
+	}
+
+	result := &DebugCompaction{}
+	kept := make(map[string][]byte)
+	var d *debugInfo
+	err := walkDebugArchive(r, func(name string, version int, f *DebugArchiveFile) error {
+		if d == nil {
+			var err error
+			d, err = newDebugInfo(name, w)
+			if err != nil {
+				return err
+			}
+		}
+
+		// the new archive records its own format version
+		if f.Name == debugArchiveVersionFile {
+			return nil
+		}
+
+		if strings.HasPrefix(f.Name, "graphs/") {
+			result.Graphs++
+			if prev, ok := kept[f.Name]; ok && debugGraphSimilarity(prev, f.Data) >= threshold {
+				result.Removed++
+				result.RemovedBytes += int64(len(f.Data))
+				return nil
+			}
+			kept[f.Name] = f.Data
+		}
+
+		if f.Step >= 0 {
+			d.step = f.Step
+			d.phase = f.Phase
+		}
+		return d.WriteFile(f.Name, f.Data)
+	})
+	if err != nil {
+		if d != nil {
+			d.Close()
+		}
+		return nil, err
+	}
+	if d == nil {
+		return nil, fmt.Errorf("archive is empty")
+	}
+
+	if err := d.Close(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// debugGraphSimilarity returns the similarity of two graphs as the number of
+// distinct lines they have in common, divided by the number of distinct
+// lines in either. Each node and edge of a dot graph is on its own line, so
+// this is the proportion of nodes and edges that are unchanged.
+func debugGraphSimilarity(a, b []byte) float64 {
+	lines := func(data []byte) map[string]bool {
+		m := make(map[string]bool)
+		for _, l := range strings.Split(string(data), "\n") {
+			if l = strings.TrimSpace(l); l != "" {
+				m[l] = true
+			}
+		}
+		return m
+	}
+
+	as, bs := lines(a), lines(b)
+	if len(as) == 0 && len(bs) == 0 {
+		return 1
+	}
+
+	common := 0
+	for l := range as {
+		if bs[l] {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(as)+len(bs)-common)
+}
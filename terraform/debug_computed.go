@@ -0,0 +1,122 @@
+package terraform
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// recordComputedDiff records the attributes the diff an instance is about to
+// be applied with marks computed, whose values aren't known until the apply,
+// so their values can be recorded by recordComputedValues. Attributes the
+// diff marks sensitive are noted, so their values can be redacted.
+func (d *debugInfo) recordComputedDiff(ii *InstanceInfo, id *InstanceDiff) {
+	if d == nil || ii == nil || id == nil || id.GetDestroy() {
+		return
+	}
+
+	computed := make(map[string]bool)
+	for k, ad := range id.CopyAttributes() {
+		if ad != nil && ad.NewComputed {
+			computed[k] = ad.Sensitive
+		}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.computedDiffs == nil {
+		d.computedDiffs = make(map[string]map[string]bool)
+	}
+	d.computedDiffs[ii.HumanId()] = computed
+}
+
+// recordComputedValues records the values the computed attributes of an
+// instance resolved to once it was applied, matched by debugComputedMatch.
+// The values of attributes the diff or the provider's schema marks
+// sensitive, and of attributes named like secrets, are redacted. Attributes
+// that are still missing are left out, as are instances without computed
+// attributes.
+func (d *debugInfo) recordComputedValues(ii *InstanceInfo, is *InstanceState) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	id := ii.HumanId()
+	computed, ok := d.computedDiffs[id]
+	if !ok {
+		return
+	}
+	delete(d.computedDiffs, id)
+
+	if len(computed) == 0 || is == nil || is.ID == "" {
+		return
+	}
+
+	schema := d.resourceSchema(ii.Type)
+	values := make(map[string]string)
+	for k, sensitive := range computed {
+		for attr, v := range is.Attributes {
+			if !debugComputedMatch(k, attr) {
+				continue
+			}
+			if sensitive || debugSchemaSensitive(schema, attr) || debugSecretAttr(attr) != "" {
+				v = debugRPCRedacted
+			}
+			values[attr] = v
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	if d.computedValues == nil {
+		d.computedValues = make(map[string]map[string]string)
+	}
+	d.computedValues[id] = values
+}
+
+// debugComputedMatch returns whether attr is, or is nested within, the
+// computed attribute k. The key of a set element that isn't known until
+// applied, such as "~1234", matches any key, and the count of a collection,
+// such as "tags.%", matches everything in the collection.
+func debugComputedMatch(k, attr string) bool {
+	kp := strings.Split(k, ".")
+	ap := strings.Split(attr, ".")
+
+	switch kp[len(kp)-1] {
+	case "#", "%":
+		kp = kp[:len(kp)-1]
+		if len(ap) <= len(kp) {
+			return false
+		}
+	default:
+		if len(ap) != len(kp) {
+			return false
+		}
+	}
+
+	for i, p := range kp {
+		if !strings.HasPrefix(p, "~") && p != ap[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeComputedValues writes computed-values.json, the values the computed
+// attributes of each applied instance resolved to, by address. The lock must
+// be held.
+func (d *debugInfo) writeComputedValues() error {
+	if len(d.computedValues) == 0 {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(d.computedValues, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("computed-values.json", js)
+}
@@ -0,0 +1,690 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DebugConfig configures the debug archive. Programs embedding Terraform can
+// pass this to SetDebugInfoConfig directly, while the Terraform CLI builds it
+// from the environment with DebugConfigFromEnv.
+type DebugConfig struct {
+	// Dir is the directory the archive file is created in, and Writer is
+	// the writer the archive is written to instead. Exactly one must be
+	// set. Archive parts are only written when Dir is set. A failed apply
+	// finalizes the current part and starts the next, so the archive up to
+	// the error can be collected as soon as the error is returned.
+	Dir    string
+	Writer io.Writer
+
+	// Atomic writes each archive part in Dir to a ".tmp" sibling of its
+	// file, renamed to the file only once the part is complete, so the
+	// name of an archive always means it's complete. Otherwise parts are
+	// written in place, which leaves whatever was written before a crash
+	// at the expected name, where it can be recovered with
+	// RecoverDebugArchive. Atomic requires Dir. TF_DEBUG_ATOMIC enables it.
+	Atomic bool
+
+	// Sorted holds every entry until the archive is closed, then writes them
+	// sorted by name, phase and content, with fixed modification times, so
+	// the archives of identical runs are identical byte for byte, even if
+	// their hooks fire in a different order. The steps of the entries are
+	// renumbered in that order, so they no longer reflect the order the
+	// entries were written in. Summaries of wall clock times, such as
+	// timings.json, leave the times out and list their entries in a fixed
+	// order, and the logs of graph builds and walks are left out. The root
+	// directory of the entries is named for the Unix epoch, and no
+	// CorrelationID is generated, but the archive file is still named for
+	// the current time. Small files aren't bundled. Nothing is written until
+	// the archive is closed, so everything is lost if the process crashes
+	// first. Whether the parallelism limit gated any applies, recorded in
+	// serialization.json, still depends on how the walk was scheduled. This
+	// is meant for test fixtures, not production use. TF_DEBUG_SORTED
+	// enables it.
+	Sorted bool
+
+	// FullFlushEvery is the number of entries between full gzip flushes,
+	// and PartEntries is the number of entries written to each archive part
+	// before the next is started. Both maximize the data that can be
+	// recovered if the process is killed. Zero disables either. They're set
+	// by TF_DEBUG_FULL_FLUSH and TF_DEBUG_PART_ENTRIES.
+	FullFlushEvery int
+	PartEntries    int
+
+	// MaxFiles is the number of files in the archive after which no more
+	// per-resource files, such as hook events, diagnostics and connection
+	// settings, are written, to keep the archive manageable for very large
+	// runs. Each file in a bundle counts towards it. Summaries, such as
+	// timings.json, are still written, and still include the events of the
+	// files that were dropped. A marker,
+	// max-files-reached.txt, is written when the first file is dropped.
+	// Zero disables the limit. It's set by TF_DEBUG_MAX_FILES.
+	MaxFiles int
+
+	// BundleSize combines per-resource files smaller than it, such as hook
+	// events, into bundles of at least this many bytes, each written as a
+	// single entry, rather than writing an entry for every file. This makes
+	// the archives of wide graphs much smaller, since every entry has a tar
+	// header and padding of its own. The files of a bundle are only written
+	// once it's full or the archive is closed, so they're lost if the
+	// process crashes first, and they're recorded in the part the bundle is
+	// written to. DebugArchiveReader unpacks bundles transparently. Zero
+	// writes every file as its own entry. It's set by TF_DEBUG_BUNDLE_SIZE.
+	BundleSize int
+
+	// MemoryLimit is the size of the heap in bytes above which only summary
+	// files are written, as at DebugLevelSummary, so that buffering and
+	// writing the per-resource files and graphs doesn't make a shortage of
+	// memory worse. Hook events held in memory by OnFailure are dropped as
+	// well. The heap is checked every MemoryCheckInterval, or every second
+	// if that is zero, and full writes resume once it's below the limit
+	// again. The marker memory-pressure.txt is written the first time the
+	// limit is exceeded, and each switch is recorded in
+	// memory-pressure.json. Zero disables the limit. They're set by
+	// TF_DEBUG_MEMORY_LIMIT and TF_DEBUG_MEMORY_CHECK_INTERVAL, the latter
+	// as a duration such as "500ms".
+	MemoryLimit         int
+	MemoryCheckInterval time.Duration
+
+	// GraphOmit are the categories of nodes to omit from an additional
+	// filtered dot file written for each graph: root, provider,
+	// provisioner, variable, output or meta. TF_DEBUG_GRAPH_OMIT sets them
+	// as a comma separated list.
+	GraphOmit []string
+
+	// GraphSplit is "split" to write each weakly connected component of a
+	// graph to its own dot file rather than a single combined file, or
+	// "keep" to write both. Graphs with a single component are always
+	// written to a single file. It's set by TF_DEBUG_GRAPH_SPLIT.
+	GraphSplit string
+
+	// Waves is "tag" to list the entries written for each resource while
+	// it's applied, such as its hook events, in wave-entries.json with the
+	// wave of the apply graph it's in, or "split" to also write them to the
+	// waves/N directory of their wave. A resource's wave is the number of
+	// resources on the longest chain of dependencies from it, including
+	// itself, so the resources of a wave could be applied concurrently. The
+	// resources of each wave are listed in waves.json. Entries are written
+	// in a single flat layout, without waves, if it's empty. It's set by
+	// TF_DEBUG_WAVES.
+	Waves string
+
+	// OmitPluginPaths leaves the paths to plugin binaries out of the
+	// archive, since they can reveal the local filesystem layout.
+	// TF_DEBUG_OMIT_PLUGIN_PATHS enables it.
+	OmitPluginPaths bool
+
+	// WorkerAssignments records which of the walk's parallelism slots
+	// evaluated each vertex, and when, in worker-assignments.json. This is
+	// only useful for debugging the scheduling of graph walks, so it's off
+	// by default. TF_DEBUG_WORKERS enables it.
+	WorkerAssignments bool
+
+	// Stream is the address of a collector service to stream a copy of the
+	// archive to as it's written. See NewDebugStreamWriter. It's set by
+	// TF_DEBUG_STREAM.
+	Stream string
+
+	// Audit is the http or https URL of an audit collector to POST each
+	// DebugHook event to as a DebugAuditEvent. Each request must be
+	// acknowledged with a 2xx response before the hook returns, and may take
+	// up to AuditTimeout, or 10 seconds if that is zero. If AuditCritical is
+	// set, an event that can't be delivered fails the operation. Otherwise
+	// delivery failures are only counted in audit.json. They're set by
+	// TF_DEBUG_AUDIT, TF_DEBUG_AUDIT_TIMEOUT, as a duration such as "5s", and
+	// TF_DEBUG_AUDIT_CRITICAL.
+	Audit         string
+	AuditTimeout  time.Duration
+	AuditCritical bool
+
+	// Level is the level of detail recorded, DebugLevelFull if empty, and
+	// PhaseLevels overrides it for individual operational phases, such as
+	// "refresh" or "apply". TF_DEBUG_LEVEL sets both from a comma separated
+	// list of levels, each optionally prefixed with a phase, i.e.
+	// "summary,apply:full".
+	Level       string
+	PhaseLevels map[string]string
+
+	// Estimate builds the archive as configured, but discards it rather than
+	// writing it, and reports the estimated size of the archive on stderr
+	// when it's closed, per phase and in total. Dir and Writer are ignored,
+	// and can't be combined with Stream. TF_DEBUG_ESTIMATE enables it.
+	Estimate bool
+
+	// OnFailure only records the hook events of the resources that fail to
+	// apply, and of the few resources that were applied just before each
+	// failure, with a summary in failures.json. The events of every resource
+	// are held in memory until it's applied, then discarded if it succeeded.
+	// Graphs aren't written, but the other summaries are.
+	// TF_DEBUG_ON_FAILURE enables it.
+	OnFailure bool
+
+	// Schemas writes the schema of each provider the configuration uses to
+	// schemas/NAME.json when the context is created, for providers that
+	// can describe their schema. Schemas can be large, so they're only
+	// written when enabled, but they're read either way, to redact the
+	// attributes they mark sensitive. TF_DEBUG_SCHEMAS enables it.
+	Schemas bool
+
+	// CorrelationID identifies the run, so the archive can be joined with
+	// traces from other systems. It's appended to the archive name, recorded
+	// in the archive-meta.json entry like Label and in debug-config.json,
+	// and sent with each audit event. It may only contain letters, digits
+	// and "_.-", and is at most 64 bytes. A random UUID is generated if it's
+	// empty, unless the archive is Sorted. It's set by
+	// TF_DEBUG_CORRELATION_ID.
+	CorrelationID string
+
+	// RPC captures the arguments and result of each provider Apply, as sent
+	// over the plugin RPC connection, in rpc/ADDR-apply.json. The values of
+	// the attributes the provider marks sensitive, in its schema or in the
+	// diff, and of attributes named like secrets, such as "password" or
+	// "access_key", are redacted, but the rest of the resource's state is
+	// included, so this must only be enabled when the archive can be
+	// handled accordingly. TF_DEBUG_RPC enables it.
+	RPC bool
+
+	// State writes a snapshot of the state at the end of the run to
+	// state.tfstate, so it can be compared with the state as it is later,
+	// such as by "terraform debug state-diff". The snapshot is taken when
+	// each refresh or apply finishes, and the last one is written. The
+	// state contains every attribute of every resource, including secrets,
+	// so this must only be enabled when the archive can be handled
+	// accordingly. TF_DEBUG_STATE enables it.
+	State bool
+
+	// UID, GID, UName and GName are the ownership recorded in the tar header
+	// of every entry, so extracting the archive as root in a controlled
+	// environment gives the files the intended owner. The IDs must not be
+	// negative, and the names, if any, must be valid user and group names of
+	// at most 32 bytes. The ownership is left zeroed by default. They're
+	// set by TF_DEBUG_UID, TF_DEBUG_GID, TF_DEBUG_UNAME and TF_DEBUG_GNAME.
+	UID   int
+	GID   int
+	UName string
+	GName string
+
+	// Label is a free-form label, such as a tenant or run ID, attached to
+	// every entry written to the archive once it's configured, so archives
+	// merged from many runs remain attributable. It's recorded in an
+	// archive-meta.json entry that applies to the entries after it, so
+	// the content of the entries is unchanged, and in debug-config.json.
+	// It may only contain letters, digits and "_.:/@=+-", and is at most
+	// 128 bytes. Entries aren't labeled if it's empty. It's set by
+	// TF_DEBUG_LABEL.
+	Label string
+}
+
+// DebugConfigFromEnv returns the DebugConfig described by the TF_DEBUG_*
+// environment variables, with the Dir and Writer left unset. The variable of
+// each option is documented on its DebugConfig field. Boolean options are
+// enabled by setting their variable to any value.
+func DebugConfigFromEnv() (DebugConfig, error) {
+	cfg := DebugConfig{
+		GraphSplit:        os.Getenv("TF_DEBUG_GRAPH_SPLIT"),
+		Waves:             os.Getenv("TF_DEBUG_WAVES"),
+		OmitPluginPaths:   os.Getenv("TF_DEBUG_OMIT_PLUGIN_PATHS") != "",
+		WorkerAssignments: os.Getenv("TF_DEBUG_WORKERS") != "",
+		Stream:            os.Getenv("TF_DEBUG_STREAM"),
+		Audit:             os.Getenv("TF_DEBUG_AUDIT"),
+		AuditCritical:     os.Getenv("TF_DEBUG_AUDIT_CRITICAL") != "",
+		Estimate:          os.Getenv("TF_DEBUG_ESTIMATE") != "",
+		OnFailure:         os.Getenv("TF_DEBUG_ON_FAILURE") != "",
+		Schemas:           os.Getenv("TF_DEBUG_SCHEMAS") != "",
+		Label:             os.Getenv("TF_DEBUG_LABEL"),
+		Atomic:            os.Getenv("TF_DEBUG_ATOMIC") != "",
+		Sorted:            os.Getenv("TF_DEBUG_SORTED") != "",
+		CorrelationID:     os.Getenv("TF_DEBUG_CORRELATION_ID"),
+		RPC:               os.Getenv("TF_DEBUG_RPC") != "",
+		State:             os.Getenv("TF_DEBUG_STATE") != "",
+		UName:             os.Getenv("TF_DEBUG_UNAME"),
+		GName:             os.Getenv("TF_DEBUG_GNAME"),
+	}
+
+	for _, v := range []struct {
+		env string
+		dst *int
+	}{
+		{"TF_DEBUG_FULL_FLUSH", &cfg.FullFlushEvery},
+		{"TF_DEBUG_PART_ENTRIES", &cfg.PartEntries},
+		{"TF_DEBUG_MAX_FILES", &cfg.MaxFiles},
+		{"TF_DEBUG_BUNDLE_SIZE", &cfg.BundleSize},
+		{"TF_DEBUG_MEMORY_LIMIT", &cfg.MemoryLimit},
+		{"TF_DEBUG_UID", &cfg.UID},
+		{"TF_DEBUG_GID", &cfg.GID},
+	} {
+		s := os.Getenv(v.env)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s value %q", v.env, s)
+		}
+		*v.dst = n
+	}
+
+	if s := os.Getenv("TF_DEBUG_AUDIT_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid TF_DEBUG_AUDIT_TIMEOUT value %q", s)
+		}
+		cfg.AuditTimeout = timeout
+	}
+
+	if s := os.Getenv("TF_DEBUG_MEMORY_CHECK_INTERVAL"); s != "" {
+		interval, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid TF_DEBUG_MEMORY_CHECK_INTERVAL value %q", s)
+		}
+		cfg.MemoryCheckInterval = interval
+	}
+
+	if spec := os.Getenv("TF_DEBUG_LEVEL"); spec != "" {
+		var err error
+		cfg.Level, cfg.PhaseLevels, err = parseDebugLevels(spec)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	if spec := os.Getenv("TF_DEBUG_GRAPH_OMIT"); spec != "" {
+		for _, c := range strings.Split(spec, ",") {
+			cfg.GraphOmit = append(cfg.GraphOmit, strings.TrimSpace(c))
+		}
+	}
+
+	return cfg, cfg.validateOptions()
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c *DebugConfig) Validate() error {
+	switch {
+	case c.Estimate && c.Stream != "":
+		return fmt.Errorf("debug config can't estimate the archive size while streaming it")
+	case c.Estimate:
+		// the destination is ignored
+	case c.Dir == "" && c.Writer == nil:
+		return fmt.Errorf("debug config requires a Dir or a Writer")
+	case c.Dir != "" && c.Writer != nil:
+		return fmt.Errorf("debug config can't have both a Dir and a Writer")
+	case c.Atomic && c.Dir == "":
+		return fmt.Errorf("debug config can only write atomically to a Dir")
+	}
+
+	if err := c.validateOptions(); err != nil {
+		return err
+	}
+
+	if c.Stream != "" {
+		if _, err := parseDebugStreamAddr(c.Stream); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateOptions validates everything but the archive destination.
+func (c *DebugConfig) validateOptions() error {
+	if c.FullFlushEvery < 0 {
+		return fmt.Errorf("invalid debug full flush interval %d", c.FullFlushEvery)
+	}
+	if c.PartEntries < 0 {
+		return fmt.Errorf("invalid debug part entries %d", c.PartEntries)
+	}
+	if c.MaxFiles < 0 {
+		return fmt.Errorf("invalid debug max files %d", c.MaxFiles)
+	}
+	if c.BundleSize < 0 {
+		return fmt.Errorf("invalid debug bundle size %d", c.BundleSize)
+	}
+	if c.MemoryLimit < 0 {
+		return fmt.Errorf("invalid debug memory limit %d", c.MemoryLimit)
+	}
+	if c.MemoryCheckInterval < 0 {
+		return fmt.Errorf("invalid debug memory check interval %s", c.MemoryCheckInterval)
+	}
+
+	if c.AuditTimeout < 0 {
+		return fmt.Errorf("invalid debug audit timeout %s", c.AuditTimeout)
+	}
+	if c.Audit != "" {
+		if err := validateDebugAuditAddr(c.Audit); err != nil {
+			return err
+		}
+	}
+
+	if err := validateDebugOwner(c.UID, c.GID, c.UName, c.GName); err != nil {
+		return err
+	}
+	if err := validateDebugLabel(c.Label); err != nil {
+		return err
+	}
+	if err := validateDebugCorrelationID(c.CorrelationID); err != nil {
+		return err
+	}
+
+	if err := validateDebugLevel(c.Level); err != nil {
+		return err
+	}
+	for _, level := range c.PhaseLevels {
+		if err := validateDebugLevel(level); err != nil {
+			return err
+		}
+	}
+
+	valid := make(map[string]bool)
+	for _, c := range debugGraphNodeCategories {
+		valid[c] = true
+	}
+	for _, category := range c.GraphOmit {
+		if !valid[category] {
+			return fmt.Errorf(
+				"invalid debug graph omit category %q, must be one of: %s",
+				category, strings.Join(debugGraphNodeCategories, ", "))
+		}
+	}
+
+	switch c.GraphSplit {
+	case "", debugGraphSplit, debugGraphKeep:
+	default:
+		return fmt.Errorf(
+			"invalid debug graph split value %q, must be %q or %q",
+			c.GraphSplit, debugGraphSplit, debugGraphKeep)
+	}
+
+	switch c.Waves {
+	case "", debugWavesTag, debugWavesSplit:
+	default:
+		return fmt.Errorf(
+			"invalid debug waves value %q, must be %q or %q",
+			c.Waves, debugWavesTag, debugWavesSplit)
+	}
+
+	return nil
+}
+
+// SetDebugInfoConfig initializes the debug handler as configured by cfg,
+// without reading the environment. Like SetDebugInfo, this must be called
+// before any other terraform package operations, and CloseDebugInfo should
+// be called before program exit.
+func SetDebugInfoConfig(cfg DebugConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	// a generated correlation ID would differ between identical runs
+	if cfg.CorrelationID == "" && !cfg.Sorted {
+		cfg.CorrelationID = newDebugCorrelationID()
+	}
+	name := debugArchiveName(cfg.CorrelationID)
+	file := ""
+	if cfg.Sorted {
+		name, file = debugSortedArchiveName(cfg.CorrelationID), name
+	}
+	owner := debugOwner{
+		uid:   cfg.UID,
+		gid:   cfg.GID,
+		uname: cfg.UName,
+		gname: cfg.GName,
+	}
+
+	var stream *DebugStreamWriter
+	if cfg.Stream != "" {
+		var err error
+		stream, err = NewDebugStreamWriter(cfg.Stream)
+		if err != nil {
+			return err
+		}
+	}
+
+	var di *debugInfo
+	var err error
+	if cfg.Estimate {
+		counter := &debugCountingWriter{}
+		di, err = newDebugInfoOwned(name, counter, owner, cfg.Sorted)
+		if err == nil {
+			di.estimate = &debugEstimate{out: os.Stderr, counter: counter}
+		}
+	} else if cfg.Writer != nil {
+		w := cfg.Writer
+		if stream != nil {
+			w = &debugTeeWriter{Writer: w, stream: stream}
+		}
+		di, err = newDebugInfoOwned(name, w, owner, cfg.Sorted)
+		if err == nil {
+			di.stream = stream
+			if s, ok := cfg.Writer.(*DebugStreamWriter); ok && stream == nil {
+				di.stream = s
+			}
+		}
+	} else {
+		di, err = newDebugInfoFile(cfg.Dir, name, debugFileOptions{
+			stream: stream,
+			atomic: cfg.Atomic,
+			owner:  owner,
+			sorted: cfg.Sorted,
+			file:   file,
+		})
+	}
+	if err != nil {
+		if stream != nil {
+			stream.Close()
+		}
+		return err
+	}
+
+	if err := di.configure(cfg); err != nil {
+		di.Close()
+		return err
+	}
+
+	// record the effective settings first, so they describe the rest of
+	// the archive
+	if err := di.writeConfig(); err != nil {
+		di.Close()
+		return err
+	}
+
+	// record anything that was resolved before the archive was created
+	for _, f := range []func() error{
+		di.writeArgs,
+		di.writePlugins,
+		di.writeStateLocation,
+	} {
+		if err := f(); err != nil {
+			di.Close()
+			return err
+		}
+	}
+
+	// only install the archive once it's completely set up, so a failure
+	// doesn't leave a partial one recording the run
+	dbug = di
+	return nil
+}
+
+// configure applies the options in cfg to d.
+func (d *debugInfo) configure(cfg DebugConfig) error {
+	if err := cfg.validateOptions(); err != nil {
+		return err
+	}
+
+	d.fullFlushEvery = cfg.FullFlushEvery
+	d.partEntries = cfg.PartEntries
+	d.maxFiles = cfg.MaxFiles
+	d.bundleSize = cfg.BundleSize
+	d.memoryLimit = cfg.MemoryLimit
+	d.memoryCheckInterval = cfg.MemoryCheckInterval
+	d.graphSplit = cfg.GraphSplit
+	d.waves = cfg.Waves
+	d.omitPluginPaths = cfg.OmitPluginPaths
+	d.recordWorkers = cfg.WorkerAssignments
+	d.level = cfg.Level
+	d.phaseLevels = cfg.PhaseLevels
+	d.summaryOnly = d.levelFor(d.phase) == DebugLevelSummary
+	d.onFailure = cfg.OnFailure
+	d.schemas = cfg.Schemas
+	d.rpc = cfg.RPC
+	d.captureState = cfg.State
+	metaChanged := d.label != cfg.Label || d.correlationID != cfg.CorrelationID
+	d.label = cfg.Label
+	d.correlationID = cfg.CorrelationID
+
+	d.audit = nil
+	if cfg.Audit != "" {
+		d.audit = newDebugAudit(cfg.Audit, cfg.AuditTimeout, cfg.AuditCritical)
+	}
+
+	d.graphOmit = nil
+	if len(cfg.GraphOmit) > 0 {
+		d.graphOmit = make(map[string]bool)
+		for _, c := range cfg.GraphOmit {
+			d.graphOmit[c] = true
+		}
+	}
+
+	if metaChanged {
+		return d.writeMeta()
+	}
+	return nil
+}
+
+// debugHookEvents are the hook events recorded by DebugHook.
+var debugHookEvents = []string{
+	"PreApply",
+	"PostApply",
+	"PreDiff",
+	"PostDiff",
+	"PreProvisionResource",
+	"PostProvisionResource",
+	"PreProvision",
+	"PostProvision",
+	"ProvisionOutput",
+	"PreRefresh",
+	"PostRefresh",
+	"PreImportState",
+	"PostImportState",
+	"PostStateUpdate",
+}
+
+// debugConfigSummary is the content of debug-config.json, which records the
+// effective debug settings so that anyone reading the archive knows what was
+// and wasn't captured.
+type debugConfigSummary struct {
+	FormatVersion       int
+	Hooks               []string
+	FullFlushEvery      int
+	PartEntries         int
+	MaxFiles            int           `json:",omitempty"`
+	BundleSize          int           `json:",omitempty"`
+	MemoryLimit         int           `json:",omitempty"`
+	MemoryCheckInterval time.Duration `json:",omitempty"`
+	GraphOmit           []string      `json:",omitempty"`
+	GraphSplit          string        `json:",omitempty"`
+	Waves               string        `json:",omitempty"`
+	OmitPluginPaths     bool
+	WorkerAssignments   bool
+	Stream              string            `json:",omitempty"`
+	Audit               string            `json:",omitempty"`
+	AuditCritical       bool              `json:",omitempty"`
+	Level               string            `json:",omitempty"`
+	PhaseLevels         map[string]string `json:",omitempty"`
+	Estimate            bool              `json:",omitempty"`
+	OnFailure           bool              `json:",omitempty"`
+	Schemas             bool              `json:",omitempty"`
+	RPC                 bool              `json:",omitempty"`
+	State               bool              `json:",omitempty"`
+	Label               string            `json:",omitempty"`
+	CorrelationID       string            `json:",omitempty"`
+	Atomic              bool              `json:",omitempty"`
+	Sorted              bool              `json:",omitempty"`
+	UID                 int               `json:",omitempty"`
+	GID                 int               `json:",omitempty"`
+	UName               string            `json:",omitempty"`
+	GName               string            `json:",omitempty"`
+}
+
+// writeConfig writes debug-config.json with the current settings. Anything
+// that may contain credentials, such as the userinfo of the stream address,
+// is left out.
+func (d *debugInfo) writeConfig() error {
+	if d == nil {
+		return nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	summary := debugConfigSummary{
+		FormatVersion:       debugArchiveFormatVersion,
+		Hooks:               debugHookEvents,
+		FullFlushEvery:      d.fullFlushEvery,
+		PartEntries:         d.partEntries,
+		MaxFiles:            d.maxFiles,
+		BundleSize:          d.bundleSize,
+		MemoryLimit:         d.memoryLimit,
+		MemoryCheckInterval: d.memoryCheckInterval,
+		GraphSplit:          d.graphSplit,
+		Waves:               d.waves,
+		OmitPluginPaths:     d.omitPluginPaths,
+		WorkerAssignments:   d.recordWorkers,
+		Level:               d.level,
+		PhaseLevels:         d.phaseLevels,
+		Estimate:            d.estimate != nil,
+		OnFailure:           d.onFailure,
+		Schemas:             d.schemas,
+		RPC:                 d.rpc,
+		State:               d.captureState,
+		Label:               d.label,
+		CorrelationID:       d.correlationID,
+		Atomic:              d.atomic,
+		Sorted:              d.sorted,
+		UID:                 d.owner.uid,
+		GID:                 d.owner.gid,
+		UName:               d.owner.uname,
+		GName:               d.owner.gname,
+	}
+
+	for c := range d.graphOmit {
+		summary.GraphOmit = append(summary.GraphOmit, c)
+	}
+	sort.Strings(summary.GraphOmit)
+
+	if d.audit != nil {
+		summary.Audit = d.audit.stats.Address
+		summary.AuditCritical = d.audit.stats.Critical
+	}
+
+	if d.stream != nil {
+		summary.Stream = d.stream.Stats().Address
+	}
+
+	js, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("debug-config.json", js)
+}
+
+// debugRedactAddr removes any userinfo and query parameters from a URL,
+// since they may contain credentials.
+func debugRedactAddr(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		// a host:port has nothing to redact
+		return addr
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
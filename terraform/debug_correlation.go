@@ -0,0 +1,40 @@
+package terraform
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/satori/go.uuid"
+)
+
+// debugCorrelationIDMaxLen is the maximum length of a correlation ID in
+// bytes.
+const debugCorrelationIDMaxLen = 64
+
+// debugCorrelationIDPattern matches a valid correlation ID, such as a UUID or
+// the hex encoded ID of a trace. The ID is part of the archive file name, so
+// it's limited to characters that are safe in file names.
+var debugCorrelationIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// validateDebugCorrelationID returns an error if id isn't a valid correlation
+// ID. The empty ID is valid, and means one is generated.
+func validateDebugCorrelationID(id string) error {
+	switch {
+	case id == "":
+		return nil
+	case len(id) > debugCorrelationIDMaxLen:
+		return fmt.Errorf(
+			"invalid debug correlation ID %q, must be at most %d bytes",
+			id, debugCorrelationIDMaxLen)
+	case !debugCorrelationIDPattern.MatchString(id):
+		return fmt.Errorf(
+			"invalid debug correlation ID %q, must start with a letter or digit, "+
+				"followed by letters, digits or any of \"_.-\"", id)
+	}
+	return nil
+}
+
+// newDebugCorrelationID returns a new random correlation ID.
+func newDebugCorrelationID() string {
+	return uuid.NewV4().String()
+}
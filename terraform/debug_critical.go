@@ -0,0 +1,220 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// debugApplyGraphName is the name the apply graph is written with, which is
+// the graph the critical path is computed for.
+const debugApplyGraphName = "ApplyGraphBuilder-graph"
+
+// debugCriticalPath is the content of critical-path.json: the longest chain
+// of dependencies through the apply graph. Since the resources in the chain
+// can't be applied in parallel, its length bounds the time the apply takes.
+type debugCriticalPath struct {
+	// Weights is "duration" if each resource is weighted by the time it took
+	// to apply, or "unit" if no timings were recorded, in which case each
+	// resource counts as 1.
+	Weights string
+
+	// Length is the total weight of the path, in nanoseconds for duration
+	// weights, or the number of resources for unit weights.
+	Length int64
+
+	// Path is the resources in the chain, in the order they were applied.
+	Path []debugCriticalPathNode
+}
+
+type debugCriticalPathNode struct {
+	Name     string
+	Address  string
+	Duration time.Duration `json:",omitempty"`
+}
+
+// debugPathGraph is the structure of the apply graph, retained until Close
+// when the timings are complete.
+type debugPathGraph struct {
+	nodes []debugPathNode
+
+	// deps are the indexes of the nodes each node depends on
+	deps [][]int
+}
+
+type debugPathNode struct {
+	name string
+
+	// addr is the instance address of a resource node, in the form used by
+	// the hooks, or empty for any other node. A resource that is replaced
+	// has both a destroy node and a create node with the same address.
+	addr    string
+	destroy bool
+}
+
+// newDebugPathGraph records the structure of g.
+func newDebugPathGraph(g *Graph) *debugPathGraph {
+	vs := g.Vertices()
+	sort.Slice(vs, func(i, j int) bool {
+		return dag.VertexName(vs[i]) < dag.VertexName(vs[j])
+	})
+
+	result := &debugPathGraph{
+		nodes: make([]debugPathNode, len(vs)),
+		deps:  make([][]int, len(vs)),
+	}
+	index := make(map[dag.Vertex]int)
+	for i, v := range vs {
+		index[v] = i
+		n := debugPathNode{name: dag.VertexName(v)}
+		if rn, ok := v.(GraphNodeResource); ok {
+			if addr := rn.ResourceAddr(); addr != nil {
+				n.addr = debugResourceAddr(addr)
+			}
+		}
+		if dn, ok := v.(GraphNodeDestroyer); ok && dn.DestroyAddr() != nil {
+			n.destroy = true
+		}
+		result.nodes[i] = n
+	}
+
+	for _, e := range g.Edges() {
+		src := index[e.Source()]
+		result.deps[src] = append(result.deps[src], index[e.Target()])
+	}
+	for _, deps := range result.deps {
+		sort.Ints(deps)
+	}
+
+	return result
+}
+
+// criticalPath returns the longest path through the graph, weighting each
+// resource node by its apply time in timings. If timings has no apply
+// timings, each resource node has a weight of 1.
+func (g *debugPathGraph) criticalPath(timings []DebugTiming) *debugCriticalPath {
+	applies := make(map[string][]time.Duration)
+	for _, t := range timings {
+		if t.Operation == "apply" && t.Phase == "apply" {
+			applies[t.Address] = append(applies[t.Address], t.Duration)
+		}
+	}
+
+	result := &debugCriticalPath{Weights: "duration"}
+	if len(applies) == 0 {
+		result.Weights = "unit"
+	}
+
+	// The timings are in the order the applies started, so when a resource
+	// is replaced, the destroy node takes the first and the create node the
+	// last.
+	weight := func(n debugPathNode) (int64, time.Duration) {
+		if n.addr == "" {
+			return 0, 0
+		}
+		if result.Weights == "unit" {
+			return 1, 0
+		}
+
+		ds := applies[n.addr]
+		switch {
+		case len(ds) == 0:
+			return 0, 0
+		case n.destroy:
+			return int64(ds[0]), ds[0]
+		default:
+			return int64(ds[len(ds)-1]), ds[len(ds)-1]
+		}
+	}
+
+	// The longest path from each node through its dependencies. The graph
+	// is written before it's validated, so any cycle is broken where it's
+	// found rather than followed.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(g.nodes))
+	length := make([]int64, len(g.nodes))
+	next := make([]int, len(g.nodes))
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		next[i] = -1
+		for _, dep := range g.deps[i] {
+			switch state[dep] {
+			case visiting:
+				continue
+			case unvisited:
+				visit(dep)
+			}
+			if next[i] < 0 || length[dep] > length[next[i]] {
+				next[i] = dep
+			}
+		}
+
+		length[i], _ = weight(g.nodes[i])
+		if next[i] >= 0 {
+			length[i] += length[next[i]]
+		}
+		state[i] = visited
+	}
+
+	start := -1
+	for i := range g.nodes {
+		if state[i] == unvisited {
+			visit(i)
+		}
+		if start < 0 || length[i] > length[start] {
+			start = i
+		}
+	}
+	if start < 0 {
+		return result
+	}
+
+	// Follow the path from the last node to be applied back through its
+	// dependencies, and reverse it into apply order.
+	result.Length = length[start]
+	for i := start; i >= 0; i = next[i] {
+		n := g.nodes[i]
+		if n.addr == "" {
+			continue
+		}
+		_, d := weight(n)
+		result.Path = append(result.Path, debugCriticalPathNode{
+			Name:     n.name,
+			Address:  n.addr,
+			Duration: d,
+		})
+	}
+	for i, j := 0, len(result.Path)-1; i < j; i, j = i+1, j-1 {
+		result.Path[i], result.Path[j] = result.Path[j], result.Path[i]
+	}
+
+	return result
+}
+
+// writeCriticalPath writes critical-path.json, if an apply graph was
+// written. Sorted archives weigh every resource as 1, since the durations
+// differ between runs. The lock must be held.
+func (d *debugInfo) writeCriticalPath() error {
+	if d.applyGraph == nil {
+		return nil
+	}
+
+	timings := d.timings
+	if d.sorted {
+		timings = nil
+	}
+
+	js, err := json.MarshalIndent(d.applyGraph.criticalPath(timings), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("critical-path.json", js)
+}
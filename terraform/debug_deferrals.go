@@ -0,0 +1,103 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// debugDeferral is an entry of deferrals.json: a node whose work was deferred
+// by a walk to a later one, such as a data source whose configuration has
+// values that are only known during apply.
+type debugDeferral struct {
+	Node   string
+	Phase  string
+	Reason string
+
+	// Processed is set if the deferred work was done later in the same run,
+	// in ProcessedPhase.
+	Processed      bool
+	ProcessedPhase string `json:",omitempty"`
+}
+
+// recordDeferral records that the work of the node for the instance id in the
+// module at path was deferred by the current walk, and why.
+func (d *debugInfo) recordDeferral(path []string, id, reason string) {
+	if d == nil {
+		return
+	}
+	addr := debugDeferralNode(path, id)
+
+	d.Lock()
+	defer d.Unlock()
+
+	// a node is only deferred once by each walk, even if it's evaluated
+	// again, such as by the shadow graph
+	for _, def := range d.deferrals {
+		if def.Node == addr && def.Phase == d.phase {
+			return
+		}
+	}
+
+	d.deferrals = append(d.deferrals, &debugDeferral{
+		Node:   addr,
+		Phase:  d.phase,
+		Reason: reason,
+	})
+}
+
+// recordDeferredProcessed records that the work of the node for the instance
+// id in the module at path was done, completing any earlier deferrals of it.
+func (d *debugInfo) recordDeferredProcessed(path []string, id string) {
+	if d == nil {
+		return
+	}
+	addr := debugDeferralNode(path, id)
+
+	d.Lock()
+	defer d.Unlock()
+
+	for _, def := range d.deferrals {
+		if def.Node == addr && !def.Processed {
+			def.Processed = true
+			def.ProcessedPhase = d.phase
+		}
+	}
+}
+
+// debugDeferralNode returns the address of the instance id in the module at
+// path, i.e. "module.child.data.aws_ami.ubuntu".
+func debugDeferralNode(path []string, id string) string {
+	if len(path) > 1 {
+		return modulePrefixStr(path) + "." + id
+	}
+	return id
+}
+
+// debugDeferralComputed returns the reason a node is deferred because the
+// keys of its configuration aren't known yet.
+func debugDeferralComputed(c *ResourceConfig) string {
+	keys := append([]string(nil), c.ComputedKeys...)
+	sort.Strings(keys)
+	return "configuration has values that are not yet known: " + strings.Join(keys, ", ")
+}
+
+// writeDeferrals writes deferrals.json, the deferred nodes sorted by address,
+// if any nodes were deferred. The lock must be held.
+func (d *debugInfo) writeDeferrals() error {
+	if len(d.deferrals) == 0 {
+		return nil
+	}
+
+	deferrals := append([]*debugDeferral(nil), d.deferrals...)
+	sort.SliceStable(deferrals, func(i, j int) bool {
+		return deferrals[i].Node < deferrals[j].Node
+	})
+
+	js, err := json.MarshalIndent(deferrals, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("deferrals.json", js)
+}
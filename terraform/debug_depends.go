@@ -0,0 +1,93 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// graphNodeDependsOn is implemented by nodes that can declare explicit
+// dependencies with depends_on.
+type graphNodeDependsOn interface {
+	dependsOn() []string
+}
+
+// debugDependsOn is an entry in depends-on.json, recording how the explicit
+// dependencies of a resource were resolved into graph edges.
+type debugDependsOn struct {
+	Resource string
+
+	// Explicit maps each depends_on entry to the vertices it resolved to.
+	// An entry that resolved to nothing didn't produce an edge.
+	Explicit map[string][]string
+
+	// Inferred are the other dependencies, from references in the
+	// configuration.
+	Inferred []string `json:",omitempty"`
+}
+
+// newDebugDependsOn resolves the depends_on entries of v, if it has any,
+// given the parents it was connected to.
+func newDebugDependsOn(m *ReferenceMap, v dag.Vertex, parents []dag.Vertex) *debugDependsOn {
+	dn, ok := v.(graphNodeDependsOn)
+	if !ok || len(dn.dependsOn()) == 0 {
+		return nil
+	}
+
+	result := &debugDependsOn{
+		Resource: dag.VertexName(v),
+		Explicit: make(map[string][]string),
+	}
+
+	explicit := make(map[string]bool)
+	for _, ns := range dn.dependsOn() {
+		targets, _ := m.reference(v, ns)
+		names := []string{}
+		for _, t := range targets {
+			name := dag.VertexName(t)
+			names = append(names, name)
+			explicit[name] = true
+		}
+		sort.Strings(names)
+		result.Explicit[ns] = names
+	}
+
+	for _, p := range parents {
+		name := dag.VertexName(p)
+		if !explicit[name] {
+			result.Inferred = append(result.Inferred, name)
+			explicit[name] = true
+		}
+	}
+	sort.Strings(result.Inferred)
+
+	return result
+}
+
+// dependsOn returns the depends_on entries of the resource configuration.
+func (n *NodeAbstractResource) dependsOn() []string {
+	if n.Config == nil {
+		return nil
+	}
+	return n.Config.DependsOn
+}
+
+// writeDependsOn writes depends-on.json for a graph being built, if any of
+// its resources have explicit dependencies.
+func (d *debugInfo) writeDependsOn(entries []*debugDependsOn) error {
+	if d == nil || len(entries) == 0 {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Resource < entries[j].Resource
+	})
+
+	js, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.WriteFile("depends-on.json", js)
+}
@@ -0,0 +1,86 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recordDestroyStart records that the destroy of the named instance has
+// started. The destroy sequence is written to destroy-order.txt when the
+// archive is closed.
+func (d *debugInfo) recordDestroyStart(id string) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.destroying == nil {
+		d.destroying = make(map[string]bool)
+	}
+	d.destroying[id] = true
+
+	d.destroyOrder = append(d.destroyOrder, d.destroyTime()+"start "+id)
+}
+
+// recordDestroyEnd records that the destroy of the named instance has
+// completed, if a destroy of that instance was started.
+func (d *debugInfo) recordDestroyEnd(id string, err error) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.destroying[id] {
+		return
+	}
+	delete(d.destroying, id)
+
+	line := d.destroyTime() + "done  " + id
+	if err != nil {
+		line += fmt.Sprintf(" (error: %s)", err)
+	}
+	d.destroyOrder = append(d.destroyOrder, line)
+}
+
+// destroyTime returns the time prefix of a line of the destroy sequence,
+// which is left out of sorted archives.
+func (d *debugInfo) destroyTime() string {
+	if d.sorted {
+		return ""
+	}
+	return time.Now().UTC().Format(time.RFC3339Nano) + " "
+}
+
+// writeDestroyOrder writes the recorded destroy sequence, if anything was
+// destroyed. The order of concurrent destroys varies between runs, so a
+// sorted archive lists them by instance instead, each start followed by its
+// completion. The lock must be held.
+func (d *debugInfo) writeDestroyOrder() error {
+	if len(d.destroyOrder) == 0 {
+		return nil
+	}
+
+	lines := d.destroyOrder
+	if d.sorted {
+		lines = append([]string(nil), lines...)
+		id := func(line string) string {
+			rest := strings.TrimSpace(strings.SplitN(line, " ", 2)[1])
+			return strings.SplitN(rest, " (error: ", 2)[0]
+		}
+		sort.SliceStable(lines, func(i, j int) bool {
+			return id(lines[i]) < id(lines[j])
+		})
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line + "\n")
+	}
+
+	return d.writeFile("destroy-order.txt", buf.Bytes())
+}
@@ -0,0 +1,58 @@
+package terraform
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// The severities of the diagnostics recorded in the diagnostics directory of
+// the debug archive.
+const (
+	debugDiagnosticWarning = "warning"
+	debugDiagnosticError   = "error"
+)
+
+// debugDiagnostics is the content of a file in the diagnostics directory:
+// the messages of one severity reported for a single graph node by one walk.
+type debugDiagnostics struct {
+	Resource  string
+	Operation string
+	Severity  string
+	Messages  []string
+}
+
+// recordDiagnostics writes the warnings and errors reported by validating a
+// graph node, such as those from a provider's ValidateResource, as soon as
+// they are reported. Each severity is written to its own file, i.e.
+// "diagnostics/aws_instance.foo-warning.json", so warnings are kept in the
+// archive even if they scrolled by unnoticed.
+func (d *debugInfo) recordDiagnostics(op walkOperation, name string, verr *EvalValidateError) {
+	if d == nil || verr == nil {
+		return
+	}
+
+	var errs []string
+	for _, e := range verr.Errors {
+		errs = append(errs, e.Error())
+	}
+
+	for _, diags := range []debugDiagnostics{
+		{Severity: debugDiagnosticWarning, Messages: verr.Warnings},
+		{Severity: debugDiagnosticError, Messages: errs},
+	} {
+		if len(diags.Messages) == 0 {
+			continue
+		}
+		diags.Resource = name
+		diags.Operation = op.String()
+
+		js, err := json.MarshalIndent(diags, "", "  ")
+		if err != nil {
+			continue
+		}
+
+		// keep the file in the diagnostics directory, whatever the name
+		file := strings.Replace(name, "/", "_", -1)
+		d.WriteResourceFile("diagnostics/"+file+"-"+diags.Severity+".json", js)
+	}
+}
@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// DebugArchiveError wraps an error returned from an operation while the
+// debug archive was active, referencing the archive so it can be collected
+// along with the error. If the archive is written to files, the part being
+// written is finalized before the error is returned, so Path can be opened
+// straight away, even when writing atomically. Anything recorded after the
+// error is written to the next part, which is finalized by CloseDebugInfo.
+type DebugArchiveError struct {
+	Err error
+
+	// Path is the path to the first part of the debug archive, which is
+	// complete when the error is returned. This is empty if the archive was
+	// not written to a file.
+	Path string
+
+	archive *debugInfo
+}
+
+func (e *DebugArchiveError) Error() string {
+	paths := e.Paths()
+	switch len(paths) {
+	case 0:
+		return e.Err.Error()
+	case 1:
+		return fmt.Sprintf("%s\n\nDebug archive: %s", e.Err, paths[0])
+	default:
+		return fmt.Sprintf("%s\n\nDebug archive parts:\n  %s",
+			e.Err, strings.Join(paths, "\n  "))
+	}
+}
+
+// WrappedErrors implements errwrap.Wrapper
+func (e *DebugArchiveError) WrappedErrors() []error {
+	return []error{e.Err}
+}
+
+// Paths returns the paths to every part of the debug archive written so far,
+// in order. Every part but the last is complete, and names the part that
+// follows it; the last is complete once CloseDebugInfo is called.
+func (e *DebugArchiveError) Paths() []string {
+	if e.Path == "" {
+		return nil
+	}
+	if e.archive == nil {
+		return []string{e.Path}
+	}
+
+	return e.archive.partPaths()
+}
+
+// partPaths returns the paths to the parts of the archive written so far.
+func (d *debugInfo) partPaths() []string {
+	d.Lock()
+	defer d.Unlock()
+
+	dir := filepath.Dir(d.path)
+	paths := make([]string, 0, d.part+1)
+	for i := 0; i <= d.part; i++ {
+		paths = append(paths, filepath.Join(dir, debugPartFile(d.file, i)))
+	}
+	return paths
+}
+
+// wrapError wraps err in a DebugArchiveError referencing the debug archive.
+// If the archive is written to files, the current part is finalized and the
+// archive continues in the next part, which is left open to be finalized by
+// CloseDebugInfo. If debugging isn't active, or err is nil, err is returned
+// unchanged.
+func (d *debugInfo) wrapError(err error) error {
+	if d == nil || err == nil {
+		return err
+	}
+
+	d.Lock()
+	if !d.closed && d.nextPart != nil {
+		if rerr := d.rotate(); rerr != nil {
+			log.Printf("[WARN] failed to finalize the debug archive part: %s", rerr)
+		}
+	}
+	d.Unlock()
+
+	return &DebugArchiveError{
+		Err:     err,
+		Path:    d.path,
+		archive: d,
+	}
+}
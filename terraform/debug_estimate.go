@@ -0,0 +1,138 @@
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DebugSizeEstimate is the estimated size of the debug archive of a run made
+// with DebugConfig.Estimate set.
+type DebugSizeEstimate struct {
+	// Phases are the estimates for each operational phase, in the order the
+	// phases started.
+	Phases []DebugPhaseEstimate
+
+	// Entries and Bytes are the number of files and their total size before
+	// compression, and Compressed is the size of the compressed archive.
+	Entries    int
+	Bytes      int64
+	Compressed int64
+}
+
+// DebugPhaseEstimate is the estimated archive size of a single phase. The
+// compressed size is the share of the total in proportion to Bytes, since
+// compression isn't done per phase.
+type DebugPhaseEstimate struct {
+	Phase      string
+	Entries    int
+	Bytes      int64
+	Compressed int64
+}
+
+// debugEstimate tallies the data written in estimate mode.
+type debugEstimate struct {
+	// out is where the estimate is reported when the archive is closed
+	out io.Writer
+
+	// counter counts the compressed archive bytes, which are discarded
+	counter *debugCountingWriter
+
+	phases []*DebugPhaseEstimate
+}
+
+// debugCountingWriter discards everything written to it, counting the
+// bytes.
+type debugCountingWriter struct {
+	n int64
+}
+
+func (w *debugCountingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// recordEstimate counts an entry written in the current phase. The lock must
+// be held.
+func (d *debugInfo) recordEstimate(data []byte) {
+	if d.estimate == nil {
+		return
+	}
+
+	var p *DebugPhaseEstimate
+	for _, existing := range d.estimate.phases {
+		if existing.Phase == d.phase {
+			p = existing
+			break
+		}
+	}
+	if p == nil {
+		p = &DebugPhaseEstimate{Phase: d.phase}
+		d.estimate.phases = append(d.estimate.phases, p)
+	}
+
+	p.Entries++
+	p.Bytes += int64(len(data))
+}
+
+// sizeEstimate returns the estimate so far. The compressed size is only
+// final once the archive is closed. The lock must be held.
+func (d *debugInfo) sizeEstimate() *DebugSizeEstimate {
+	if d.estimate == nil {
+		return nil
+	}
+
+	result := &DebugSizeEstimate{
+		Compressed: d.estimate.counter.n,
+	}
+	for _, p := range d.estimate.phases {
+		result.Phases = append(result.Phases, *p)
+		result.Entries += p.Entries
+		result.Bytes += p.Bytes
+	}
+	for i := range result.Phases {
+		if result.Bytes > 0 {
+			result.Phases[i].Compressed =
+				result.Compressed * result.Phases[i].Bytes / result.Bytes
+		}
+	}
+
+	return result
+}
+
+// writeEstimate reports the final estimate. The lock must be held, and the
+// archive closed.
+func (d *debugInfo) writeEstimate() error {
+	e := d.sizeEstimate()
+	if e == nil {
+		return nil
+	}
+
+	_, err := io.WriteString(d.estimate.out, formatDebugSizeEstimate(e))
+	return err
+}
+
+// formatDebugSizeEstimate formats the estimate for display, largest phase
+// first.
+func formatDebugSizeEstimate(e *DebugSizeEstimate) string {
+	phases := make([]DebugPhaseEstimate, len(e.Phases))
+	copy(phases, e.Phases)
+	sort.SliceStable(phases, func(i, j int) bool {
+		return phases[i].Bytes > phases[j].Bytes
+	})
+
+	result := fmt.Sprintf(
+		"Estimated debug archive size: %d bytes compressed, %d bytes in %d file(s) uncompressed\n",
+		e.Compressed, e.Bytes, e.Entries)
+	for _, p := range phases {
+		name := p.Phase
+		if name == "" {
+			name = "(no phase)"
+		}
+		result += fmt.Sprintf(
+			"  %s: %d bytes compressed, %d bytes in %d file(s) uncompressed\n",
+			name, p.Compressed, p.Bytes, p.Entries)
+	}
+
+	return result
+}
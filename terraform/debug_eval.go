@@ -0,0 +1,85 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform/config"
+)
+
+// debugEvalError is an error evaluating an interpolation, with the source
+// position of the value containing it.
+type debugEvalError struct {
+	Module   string
+	Resource string `json:",omitempty"`
+
+	// File, Line and Column locate the value of the attribute containing the
+	// interpolation, and are empty if the position isn't known.
+	File   string `json:",omitempty"`
+	Line   int    `json:",omitempty"`
+	Column int    `json:",omitempty"`
+
+	Key        string
+	Expression string
+	Error      string
+}
+
+// recordEvalError records err if it is an error evaluating an interpolation
+// in the configuration of the module at path, or of r if it's not nil.
+func (d *debugInfo) recordEvalError(path []string, r *Resource, err error) {
+	if d == nil {
+		return
+	}
+	ie, ok := err.(*config.InterpolationError)
+	if !ok {
+		return
+	}
+
+	e := &debugEvalError{
+		Module:     modulePrefixStr(path),
+		File:       ie.Pos.Filename,
+		Line:       ie.Pos.Line,
+		Column:     ie.Pos.Column,
+		Key:        ie.Key,
+		Expression: ie.Expr,
+		Error:      ie.Err.Error(),
+	}
+	if e.Module == "" {
+		e.Module = "root"
+	}
+	if r != nil {
+		e.Resource = fmt.Sprintf("%s.%s", r.Type, r.Name)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.evalErrors = append(d.evalErrors, e)
+}
+
+// writeEvalErrors writes eval-errors.json, the errors evaluating
+// interpolations ordered by their source position, if there were any. The
+// lock must be held.
+func (d *debugInfo) writeEvalErrors() error {
+	if len(d.evalErrors) == 0 {
+		return nil
+	}
+
+	errs := make([]*debugEvalError, len(d.evalErrors))
+	copy(errs, d.evalErrors)
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].File != errs[j].File {
+			return errs[i].File < errs[j].File
+		}
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+
+	js, err := json.MarshalIndent(errs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return d.writeFile("eval-errors.json", js)
+}
@@ -0,0 +1,129 @@
+package terraform
+
+import (
+	"encoding/json"
+)
+
+// debugFailureContext is the number of resources that completed just before
+// a failure whose events are written along with those of the failure.
+const debugFailureContext = 3
+
+// debugFailures buffers the hook events of each resource when only failures
+// are recorded, until the resource is applied.
+type debugFailures struct {
+	// pending are the events of each resource not yet applied, and recent
+	// the events of the last resources applied successfully, oldest first.
+	pending map[string][]debugBufferedEntry
+	recent  []debugCompletedResource
+
+	// failures are written to failures.json
+	failures []debugFailure
+}
+
+// debugBufferedEntry is a hook event waiting to be written, along with the
+// phase it happened in.
+type debugBufferedEntry struct {
+	name  string
+	phase string
+	data  []byte
+}
+
+type debugCompletedResource struct {
+	addr    string
+	entries []debugBufferedEntry
+}
+
+// debugFailure is an entry in failures.json: a resource that failed to
+// apply, and the resources that completed just before it, whose events were
+// also written for context.
+type debugFailure struct {
+	Address string
+	Error   string
+	Context []string
+}
+
+// bufferHookFile holds the data for a hook event on the given instance until
+// it's known whether the instance fails to apply. The lock must be held.
+func (d *debugInfo) bufferHookFile(ii *InstanceInfo, name string, data []byte) {
+	if d.failures == nil {
+		d.failures = &debugFailures{pending: make(map[string][]debugBufferedEntry)}
+	}
+
+	addr := ii.HumanId()
+	d.failures.pending[addr] = append(d.failures.pending[addr], debugBufferedEntry{
+		name:  debugHookFile(ii, name),
+		phase: d.phase,
+		data:  data,
+	})
+}
+
+// recordApplyResult writes the buffered events of an instance that failed to
+// apply, along with those of the instances that completed just before it.
+// The events of an instance that succeeded are kept as context for the next
+// failure, and eventually discarded.
+func (d *debugInfo) recordApplyResult(ii *InstanceInfo, err error) error {
+	if d == nil || ii == nil {
+		return nil
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.onFailure || d.failures == nil || d.closed {
+		return nil
+	}
+	f := d.failures
+
+	addr := ii.HumanId()
+	entries := f.pending[addr]
+	delete(f.pending, addr)
+
+	if err == nil {
+		f.recent = append(f.recent, debugCompletedResource{addr: addr, entries: entries})
+		if len(f.recent) > debugFailureContext {
+			f.recent = f.recent[1:]
+		}
+		return nil
+	}
+
+	failure := debugFailure{Address: addr, Error: err.Error(), Context: []string{}}
+	for _, r := range f.recent {
+		failure.Context = append(failure.Context, r.addr)
+		if err := d.writeBuffered(r.entries); err != nil {
+			return err
+		}
+	}
+	f.recent = nil
+	f.failures = append(f.failures, failure)
+
+	return d.writeBuffered(entries)
+}
+
+// writeBuffered writes buffered events with the phase they happened in. The
+// lock must be held.
+func (d *debugInfo) writeBuffered(entries []debugBufferedEntry) error {
+	phase := d.phase
+	defer func() { d.phase = phase }()
+
+	for _, e := range entries {
+		d.phase = e.phase
+		if err := d.writeResourceFile(e.name, e.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFailures writes failures.json, if any resources failed while only
+// failures were recorded. The lock must be held.
+func (d *debugInfo) writeFailures() error {
+	if d.failures == nil || len(d.failures.failures) == 0 {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(d.failures.failures, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("failures.json", js)
+}
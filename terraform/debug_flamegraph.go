@@ -0,0 +1,60 @@
+package terraform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteCollapsedStacks writes the operation timings recorded in the archive
+// in the collapsed stack format read by flamegraph.pl, one stack per line
+// followed by its total duration in microseconds:
+//
+//	apply;module.child;aws_instance.web;apply 1532000
+//
+// Each stack is the phase, the modules of the instance, the instance itself
+// and the operation. Operations run concurrently, so the widths in the
+// resulting flame graph are the time spent on each instance rather than
+// the wall clock time of the run. It returns false if the archive has no
+// timings.
+func (r *DebugArchiveReader) WriteCollapsedStacks(w io.Writer) (bool, error) {
+	timings, err := r.Timings()
+	if err != nil || timings == nil {
+		return false, err
+	}
+
+	totals := make(map[string]time.Duration)
+	for _, t := range timings {
+		// phase timings overlap the operations within them
+		if t.Operation == "phase" {
+			continue
+		}
+
+		frames := []string{t.Phase}
+		if t.Phase == "" {
+			frames[0] = "unknown"
+		}
+		ii := debugReplayInfo(t.Address)
+		for _, m := range ii.ModulePath[1:] {
+			frames = append(frames, "module."+m)
+		}
+		frames = append(frames, ii.Id, t.Operation)
+
+		totals[strings.Join(frames, ";")] += t.Duration
+	}
+
+	stacks := make([]string, 0, len(totals))
+	for s := range totals {
+		stacks = append(stacks, s)
+	}
+	sort.Strings(stacks)
+
+	bw := bufio.NewWriter(w)
+	for _, s := range stacks {
+		fmt.Fprintf(bw, "%s %d\n", s, totals[s]/time.Microsecond)
+	}
+	return true, bw.Flush()
+}
@@ -0,0 +1,26 @@
+package terraform
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// GraphFrames returns the dot files of the graphs directory of the archive,
+// in the order of the steps they were written at, so they can be viewed as
+// the frames of an animation of the graphs built during the run.
+func (r *DebugArchiveReader) GraphFrames() []*DebugArchiveFile {
+	var result []*DebugArchiveFile
+	for _, f := range r.Files {
+		if strings.HasPrefix(f.Name, "graphs/") && path.Ext(f.Name) == ".dot" {
+			result = append(result, f)
+		}
+	}
+
+	// recovered parts may have been read out of order
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Step < result[j].Step
+	})
+
+	return result
+}
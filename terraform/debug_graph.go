@@ -0,0 +1,434 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// The categories of graph nodes that can be omitted from the filtered dot
+// graphs written by WriteGraph, via DebugConfig.GraphOmit.
+const (
+	DebugGraphNodeRoot        = "root"
+	DebugGraphNodeProvider    = "provider"
+	DebugGraphNodeProvisioner = "provisioner"
+	DebugGraphNodeVariable    = "variable"
+	DebugGraphNodeOutput      = "output"
+	DebugGraphNodeMeta        = "meta"
+)
+
+// The values of DebugConfig.GraphSplit. With either, graphs with more than
+// one weakly connected component are written as one dot file per component.
+// "keep" also writes the combined graph, "split" doesn't.
+const (
+	debugGraphSplit = "split"
+	debugGraphKeep  = "keep"
+)
+
+var debugGraphNodeCategories = []string{
+	DebugGraphNodeRoot,
+	DebugGraphNodeProvider,
+	DebugGraphNodeProvisioner,
+	DebugGraphNodeVariable,
+	DebugGraphNodeOutput,
+	DebugGraphNodeMeta,
+}
+
+// debugGraphCount is a row in nodes.csv, recording the size of a graph at the
+// time it was written.
+type debugGraphCount struct {
+	Phase    string
+	Step     int
+	Name     string
+	Vertices int
+	Edges    int
+}
+
+// DebugGraphJSON is the JSON representation of a graph written by
+// WriteGraph, for tools that would rather not parse dot. It is written
+// alongside the dot file as graphs/NAME.json.
+type DebugGraphJSON struct {
+	Name string
+
+	// Nodes are sorted by ID, and Edges by From and then To.
+	Nodes []DebugGraphJSONNode
+	Edges []DebugGraphJSONEdge
+}
+
+// DebugGraphJSONNode is a single vertex of a DebugGraphJSON.
+type DebugGraphJSONNode struct {
+	// ID is the name of the vertex, which is the name of its node in the dot
+	// graph without the "[root] " prefix.
+	ID string
+
+	// Type is the name of the Go type implementing the vertex, i.e.
+	// "NodeApplyableResource".
+	Type string
+
+	// Attributes are the dot attributes of the node, such as its label and
+	// shape. Vertices that aren't drawn in the dot graph have none.
+	Attributes map[string]string `json:",omitempty"`
+}
+
+// DebugGraphJSONEdge is a single edge of a DebugGraphJSON, from a dependent
+// vertex to its dependency, by ID.
+type DebugGraphJSONEdge struct {
+	From string
+	To   string
+}
+
+// WriteGraph writes the dot representation of g to the graphs directory of
+// the archive, along with its JSON representation, and records the number of
+// vertices and edges in nodes.csv so the size of the graph can be tracked
+// across phases. The name of the transform that added each vertex is written
+// to graphs/NAME-provenance.json, or "unknown" if it wasn't recorded. If any
+// node categories are to be omitted, a filtered graph is written alongside
+// the full graph. If graphs are to be split, each weakly connected component
+// of g is written to its own file, numbered from 0. The structure of the
+// apply graph is kept to compute the critical path when the archive is
+// closed.
+func (d *debugInfo) WriteGraph(name string, g *Graph) error {
+	if d == nil || g == nil {
+		return nil
+	}
+
+	// build the output before taking the lock
+	var dot []byte
+	var components [][]byte
+	if d.graphSplit != "" {
+		if cs := debugGraphComponents(g); len(cs) > 1 {
+			for _, c := range cs {
+				components = append(components, c.Dot(nil))
+			}
+		}
+	}
+	if components == nil || d.graphSplit == debugGraphKeep {
+		dot = g.Dot(nil)
+	}
+
+	js, err := json.MarshalIndent(newDebugGraphJSON(name, g), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	count := debugGraphCount{
+		Name:     name,
+		Vertices: len(g.Vertices()),
+		Edges:    len(g.Edges()),
+	}
+
+	var filtered []byte
+	if len(d.graphOmit) > 0 {
+		filtered = debugGraphOmit(g, d.graphOmit).Dot(nil)
+	}
+
+	provenance, err := json.MarshalIndent(debugProvenance(g), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var applyGraph *debugPathGraph
+	if name == debugApplyGraphName {
+		applyGraph = newDebugPathGraph(g)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.closed {
+		return nil
+	}
+
+	count.Phase = d.phase
+	count.Step = d.step
+	d.graphCounts = append(d.graphCounts, count)
+	if applyGraph != nil {
+		d.applyGraph = applyGraph
+		d.resourceWaves = nil
+	}
+
+	// the graph is still counted at the summary level, or when only
+	// failures are recorded, or under memory pressure, just not written
+	if d.summaryOnly || d.onFailure || d.underMemoryPressure() {
+		return nil
+	}
+
+	if dot != nil {
+		if err := d.writeFile("graphs/"+name+".dot", dot); err != nil {
+			return err
+		}
+	}
+
+	if err := d.writeFile("graphs/"+name+".json", js); err != nil {
+		return err
+	}
+
+	if err := d.writeFile("graphs/"+name+"-provenance.json", provenance); err != nil {
+		return err
+	}
+
+	for i, c := range components {
+		err := d.writeFile(fmt.Sprintf("graphs/%s-component-%d.dot", name, i), c)
+		if err != nil {
+			return err
+		}
+	}
+
+	if filtered != nil {
+		return d.writeFile("graphs/"+name+"-filtered.dot", filtered)
+	}
+	return nil
+}
+
+// WriteSubgraph writes the dot representation of the subgraph of g focused
+// on the resource at addr to the graphs directory of the archive, as
+// graphs/NAME-subgraph-ADDR.dot. The subgraph has every vertex of the
+// resource, such as its apply and destroy nodes, along with their transitive
+// dependencies and dependents. If the resource isn't in g, nothing is written
+// but a note, graphs/NAME-subgraph-ADDR.txt, so it's clear the subgraph was
+// asked for.
+func (d *debugInfo) WriteSubgraph(name string, g *Graph, addr string) error {
+	if d == nil || g == nil {
+		return nil
+	}
+
+	target, err := ParseResourceAddress(addr)
+	if err != nil {
+		return err
+	}
+
+	// build the output before taking the lock
+	file := "graphs/" + name + "-subgraph-" + addr
+	var data []byte
+	if sub, err := debugSubgraph(g, target); err != nil {
+		return err
+	} else if sub == nil {
+		file += ".txt"
+		data = []byte(fmt.Sprintf("%s is not in the %s graph\n", addr, name))
+	} else {
+		file += ".dot"
+		data = sub.Dot(nil)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.closed || d.summaryOnly || d.underMemoryPressure() {
+		return nil
+	}
+	return d.writeFile(file, data)
+}
+
+// debugSubgraph returns the subgraph of g with the vertices of the resource
+// at target, and their ancestors and descendents, or nil if there are no
+// vertices of the resource in g.
+func debugSubgraph(g *Graph, target *ResourceAddress) (*Graph, error) {
+	keep := new(dag.Set)
+	for _, v := range g.Vertices() {
+		rn, ok := v.(GraphNodeResource)
+		if !ok || !target.Equals(rn.ResourceAddr()) {
+			continue
+		}
+
+		keep.Add(v)
+		for _, walk := range []func(dag.Vertex) (*dag.Set, error){g.Ancestors, g.Descendents} {
+			vs, err := walk(v)
+			if err != nil {
+				return nil, err
+			}
+			for _, related := range vs.List() {
+				keep.Add(related)
+			}
+		}
+	}
+	if keep.Len() == 0 {
+		return nil, nil
+	}
+
+	result := &Graph{Path: g.Path}
+	for _, v := range keep.List() {
+		result.Add(v)
+	}
+	for _, e := range g.Edges() {
+		if keep.Include(e.Source()) && keep.Include(e.Target()) {
+			result.Connect(e)
+		}
+	}
+
+	return result, nil
+}
+
+// newDebugGraphJSON returns the JSON representation of g.
+func newDebugGraphJSON(name string, g *Graph) *DebugGraphJSON {
+	result := &DebugGraphJSON{
+		Name:  name,
+		Nodes: []DebugGraphJSONNode{},
+		Edges: []DebugGraphJSONEdge{},
+	}
+
+	for _, v := range g.Vertices() {
+		n := DebugGraphJSONNode{
+			ID:   dag.VertexName(v),
+			Type: reflect.Indirect(reflect.ValueOf(v)).Type().Name(),
+		}
+		if dn, ok := v.(dag.GraphNodeDotter); ok {
+			if node := dn.DotNode(n.ID, &dag.DotOpts{Verbose: true}); node != nil {
+				n.Attributes = node.Attrs
+			}
+		}
+		result.Nodes = append(result.Nodes, n)
+	}
+	sort.Slice(result.Nodes, func(i, j int) bool {
+		return result.Nodes[i].ID < result.Nodes[j].ID
+	})
+
+	for _, e := range g.Edges() {
+		result.Edges = append(result.Edges, DebugGraphJSONEdge{
+			From: dag.VertexName(e.Source()),
+			To:   dag.VertexName(e.Target()),
+		})
+	}
+	sort.Slice(result.Edges, func(i, j int) bool {
+		if result.Edges[i].From != result.Edges[j].From {
+			return result.Edges[i].From < result.Edges[j].From
+		}
+		return result.Edges[i].To < result.Edges[j].To
+	})
+
+	return result
+}
+
+// debugGraphComponents returns the weakly connected components of g, each as
+// a new graph. The components are ordered by the name of their first vertex,
+// so the numbering is stable between runs of the same configuration.
+func debugGraphComponents(g *Graph) []*Graph {
+	// union-find over the vertices, ignoring edge direction
+	parent := make(map[dag.Vertex]dag.Vertex)
+	var find func(v dag.Vertex) dag.Vertex
+	find = func(v dag.Vertex) dag.Vertex {
+		if p := parent[v]; p != v {
+			parent[v] = find(p)
+		}
+		return parent[v]
+	}
+
+	vs := g.Vertices()
+	for _, v := range vs {
+		parent[v] = v
+	}
+	for _, e := range g.Edges() {
+		parent[find(e.Source())] = find(e.Target())
+	}
+
+	sort.Slice(vs, func(i, j int) bool {
+		return dag.VertexName(vs[i]) < dag.VertexName(vs[j])
+	})
+
+	var result []*Graph
+	byRoot := make(map[dag.Vertex]*Graph)
+	for _, v := range vs {
+		root := find(v)
+		c, ok := byRoot[root]
+		if !ok {
+			c = &Graph{Path: g.Path}
+			byRoot[root] = c
+			result = append(result, c)
+		}
+		c.Add(v)
+	}
+	for _, e := range g.Edges() {
+		byRoot[find(e.Source())].Connect(e)
+	}
+
+	return result
+}
+
+// debugGraphNodeCategory returns the category of the graph node v, or an
+// empty string if it's not in one of the categories that can be omitted.
+func debugGraphNodeCategory(v dag.Vertex) string {
+	switch v.(type) {
+	case graphNodeRoot:
+		return DebugGraphNodeRoot
+	case GraphNodeProvider, GraphNodeCloseProvider:
+		return DebugGraphNodeProvider
+	case GraphNodeProvisioner, GraphNodeCloseProvisioner:
+		return DebugGraphNodeProvisioner
+	case *NodeRootVariable, *NodeApplyableModuleVariable, *NodeDestroyableModuleVariable:
+		return DebugGraphNodeVariable
+	case *NodeApplyableOutput, *NodeOutputOrphan:
+		return DebugGraphNodeOutput
+	case *NodeCountBoundary:
+		return DebugGraphNodeMeta
+	}
+
+	return ""
+}
+
+// debugGraphOmit returns a copy of g with the vertices in the omitted
+// categories removed. The edges through each removed vertex are replaced by
+// edges from its dependents to its dependencies, so that transitive
+// relationships are preserved.
+func debugGraphOmit(g *Graph, omit map[string]bool) *Graph {
+	result := &Graph{Path: g.Path}
+	for _, v := range g.Vertices() {
+		result.Add(v)
+	}
+	for _, e := range g.Edges() {
+		result.Connect(e)
+	}
+
+	// Sort the vertices so the result doesn't depend on iteration order
+	vs := result.Vertices()
+	sort.Slice(vs, func(i, j int) bool {
+		return dag.VertexName(vs[i]) < dag.VertexName(vs[j])
+	})
+
+	for _, v := range vs {
+		if !omit[debugGraphNodeCategory(v)] {
+			continue
+		}
+
+		for _, up := range result.UpEdges(v).List() {
+			for _, down := range result.DownEdges(v).List() {
+				if up != down {
+					result.Connect(dag.BasicEdge(up, down))
+				}
+			}
+		}
+		result.Remove(v)
+	}
+
+	return result
+}
+
+// writeGraphCounts writes nodes.csv. The lock must be held.
+func (d *debugInfo) writeGraphCounts() error {
+	if len(d.graphCounts) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"phase", "step", "name", "vertices", "edges"})
+	for _, c := range d.graphCounts {
+		w.Write([]string{
+			c.Phase,
+			strconv.Itoa(c.Step),
+			c.Name,
+			strconv.Itoa(c.Vertices),
+			strconv.Itoa(c.Edges),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return d.writeFile("nodes.csv", buf.Bytes())
+}
@@ -0,0 +1,43 @@
+package terraform
+
+import (
+	"encoding/json"
+)
+
+// recordInstanceID records the provider-assigned ID of an instance after it
+// was applied or refreshed. An instance without an ID, such as one that
+// failed to be created or was destroyed, is recorded with no ID. Only the
+// last ID recorded for each instance is kept.
+func (d *debugInfo) recordInstanceID(ii *InstanceInfo, is *InstanceState) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.instanceIDs == nil {
+		d.instanceIDs = make(map[string]*string)
+	}
+
+	var id *string
+	if is != nil && is.ID != "" {
+		id = &is.ID
+	}
+	d.instanceIDs[ii.HumanId()] = id
+}
+
+// writeInstanceIDs writes ids.json, mapping the address of each instance
+// that was applied or refreshed to its ID, or null if it has none. The lock
+// must be held.
+func (d *debugInfo) writeInstanceIDs() error {
+	if len(d.instanceIDs) == 0 {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(d.instanceIDs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("ids.json", js)
+}
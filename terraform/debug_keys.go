@@ -0,0 +1,109 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// debugKeyChurn is an entry of key-churn.json: a resource whose instance
+// keys in the prior state differ from those planned. Keys are count
+// indexes, with an instance without an index taken as index 0, as Terraform
+// does when the count changes to or from 1.
+type debugKeyChurn struct {
+	Address string
+
+	Prior   []int
+	Planned []int
+
+	// Added are the keys only planned, which will be created, and Removed
+	// the keys only in the prior state, which will be destroyed.
+	Added   []int
+	Removed []int
+}
+
+// recordKeyChurn records the instance keys of the resource at addr in state,
+// along with those planned for its count. The state must be locked for
+// reading.
+func (d *debugInfo) recordKeyChurn(addr *ResourceAddress, count int, state *State) {
+	if d == nil || addr == nil {
+		return
+	}
+
+	prior := make(map[int]bool)
+	if state != nil {
+		if ms := state.ModuleByPath(normalizeModulePath(addr.Path)); ms != nil {
+			for key := range ms.Resources {
+				a, err := parseResourceAddressInternal(key)
+				if err != nil {
+					continue
+				}
+				a.Path = ms.Path[1:]
+
+				index := a.Index
+				a.Index = -1
+				if !a.Equals(addr) {
+					continue
+				}
+				if index < 0 {
+					index = 0
+				}
+				prior[index] = true
+			}
+		}
+	}
+
+	churn := &debugKeyChurn{
+		Address: addr.String(),
+		Prior:   []int{},
+		Planned: []int{},
+		Added:   []int{},
+		Removed: []int{},
+	}
+	for i := 0; i < count; i++ {
+		churn.Planned = append(churn.Planned, i)
+		if !prior[i] {
+			churn.Added = append(churn.Added, i)
+		}
+	}
+	for i := range prior {
+		churn.Prior = append(churn.Prior, i)
+		if i >= count {
+			churn.Removed = append(churn.Removed, i)
+		}
+	}
+	sort.Ints(churn.Prior)
+	sort.Ints(churn.Removed)
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.keyChurn == nil {
+		d.keyChurn = make(map[string]*debugKeyChurn)
+	}
+	d.keyChurn[churn.Address] = churn
+}
+
+// writeKeyChurn writes key-churn.json, the resources whose instance keys
+// changed between the prior state and the plan, sorted by address, if there
+// were any. The lock must be held.
+func (d *debugInfo) writeKeyChurn() error {
+	var churn []*debugKeyChurn
+	for _, c := range d.keyChurn {
+		if len(c.Added) > 0 || len(c.Removed) > 0 {
+			churn = append(churn, c)
+		}
+	}
+	if len(churn) == 0 {
+		return nil
+	}
+	sort.Slice(churn, func(i, j int) bool {
+		return churn[i].Address < churn[j].Address
+	})
+
+	js, err := json.MarshalIndent(churn, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("key-churn.json", js)
+}
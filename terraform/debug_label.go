@@ -0,0 +1,43 @@
+package terraform
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// debugArchiveMetaFile is the name of the entry recording the label and
+// correlation ID of the archive, relative to the archive root. Like the
+// format-version file it has no step and phase prefix. It's written at the
+// start of each archive part once either is set, and again whenever they
+// change, and applies to the entries after it, so the entries themselves are
+// unchanged.
+const debugArchiveMetaFile = "archive-meta.json"
+
+// debugArchiveMeta is the content of the archive metadata entry.
+type debugArchiveMeta struct {
+	Label         string `json:",omitempty"`
+	CorrelationID string `json:",omitempty"`
+}
+
+// debugLabelMaxLen is the maximum length of a label in bytes.
+const debugLabelMaxLen = 128
+
+// debugLabelPattern matches a valid label, such as "tenant-a/run-1234".
+var debugLabelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:/@=+-]*$`)
+
+// validateDebugLabel returns an error if label isn't a valid label. The empty
+// label is valid, and means entries aren't labeled.
+func validateDebugLabel(label string) error {
+	switch {
+	case label == "":
+		return nil
+	case len(label) > debugLabelMaxLen:
+		return fmt.Errorf(
+			"invalid debug label %q, must be at most %d bytes", label, debugLabelMaxLen)
+	case !debugLabelPattern.MatchString(label):
+		return fmt.Errorf(
+			"invalid debug label %q, must start with a letter or digit, followed "+
+				"by letters, digits or any of \"_.:/@=+-\"", label)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The levels of detail recorded in the debug archive, set with
+// DebugConfig.Level and PhaseLevels. At DebugLevelFull, the default,
+// everything is recorded. At DebugLevelSummary, the per-event hook files and
+// graph dumps are left out, but the events are still accounted for in the
+// summary files written when the archive is closed, such as timings.json and
+// nodes.csv.
+const (
+	DebugLevelFull    = "full"
+	DebugLevelSummary = "summary"
+)
+
+// parseDebugLevels parses a level spec such as "refresh:summary,apply:full"
+// into the level for each phase. An entry without a phase sets the default
+// level for the phases that aren't listed.
+func parseDebugLevels(spec string) (string, map[string]string, error) {
+	def := ""
+	var phases map[string]string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		idx := strings.Index(entry, ":")
+		if idx < 0 {
+			def = entry
+			continue
+		}
+
+		phase := strings.TrimSpace(entry[:idx])
+		if phase == "" {
+			return "", nil, fmt.Errorf("invalid debug level %q, missing phase", entry)
+		}
+		if phases == nil {
+			phases = make(map[string]string)
+		}
+		phases[phase] = strings.TrimSpace(entry[idx+1:])
+	}
+
+	return def, phases, nil
+}
+
+// validateDebugLevel returns an error if level isn't a known level. The
+// empty level is the default, DebugLevelFull.
+func validateDebugLevel(level string) error {
+	switch level {
+	case "", DebugLevelFull, DebugLevelSummary:
+		return nil
+	}
+	return fmt.Errorf(
+		"invalid debug level %q, must be %q or %q",
+		level, DebugLevelFull, DebugLevelSummary)
+}
+
+// levelFor returns the level of detail recorded during the given phase.
+func (d *debugInfo) levelFor(phase string) string {
+	if level, ok := d.phaseLevels[phase]; ok && level != "" {
+		return level
+	}
+	if d.level != "" {
+		return d.level
+	}
+	return DebugLevelFull
+}
@@ -0,0 +1,68 @@
+package terraform
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DebugLockWait describes time spent waiting to acquire a state lock, as
+// recorded in lock-waits.json of the debug archive. Waits are only recorded
+// when the lock wasn't acquired on the first attempt.
+type DebugLockWait struct {
+	// Operation is the operation requesting the lock.
+	Operation string
+
+	// HolderID and HolderOperation describe the lock that was held when the
+	// last attempt failed.
+	HolderID        string `json:",omitempty"`
+	HolderOperation string `json:",omitempty"`
+
+	// Start is the time of the first attempt, and Wait is the total time
+	// until the lock was acquired or the attempt was abandoned.
+	Start    time.Time
+	Wait     time.Duration
+	Attempts int
+
+	// Acquired is true if the lock was eventually acquired.
+	Acquired bool
+	Error    string `json:",omitempty"`
+}
+
+// RecordDebugLockWait records a wait for a state lock in the debug archive.
+func RecordDebugLockWait(w DebugLockWait) {
+	dbug.recordLockWait(w)
+}
+
+func (d *debugInfo) recordLockWait(w DebugLockWait) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	d.lockWaits = append(d.lockWaits, w)
+}
+
+// writeLockWaits writes lock-waits.json. The times are left out of sorted
+// archives. The lock must be held.
+func (d *debugInfo) writeLockWaits() error {
+	if len(d.lockWaits) == 0 {
+		return nil
+	}
+
+	waits := d.lockWaits
+	if d.sorted {
+		waits = make([]DebugLockWait, len(d.lockWaits))
+		for i, w := range d.lockWaits {
+			w.Start, w.Wait = time.Time{}, 0
+			waits[i] = w
+		}
+	}
+
+	js, err := json.MarshalIndent(waits, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("lock-waits.json", js)
+}
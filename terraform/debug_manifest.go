@@ -0,0 +1,90 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"time"
+)
+
+// DebugManifestEntry describes a single file of a debug archive.
+type DebugManifestEntry struct {
+	// Name, Step and Phase are as in DebugArchiveFile.
+	Name  string
+	Step  int
+	Phase string
+
+	// Size is the size of the file in bytes, and Checksum the hex encoded
+	// SHA-256 checksum of its content.
+	Size     int64
+	Checksum string
+
+	// ModTime is the time the file was written, if it was recorded.
+	ModTime time.Time
+
+	// CorrelationID is the correlation ID of the run that wrote the file,
+	// if it was recorded.
+	CorrelationID string `json:",omitempty"`
+}
+
+// debugManifestColumns are the columns of the CSV manifest.
+var debugManifestColumns = []string{"name", "step", "phase", "size", "checksum", "modtime", "correlation_id"}
+
+// Manifest returns an entry for each file in the archive, in the order
+// they were written.
+func (r *DebugArchiveReader) Manifest() []DebugManifestEntry {
+	result := make([]DebugManifestEntry, len(r.Files))
+	for i, f := range r.Files {
+		sum := sha256.Sum256(f.Data)
+		result[i] = DebugManifestEntry{
+			Name:          f.Name,
+			Step:          f.Step,
+			Phase:         f.Phase,
+			Size:          int64(len(f.Data)),
+			Checksum:      hex.EncodeToString(sum[:]),
+			ModTime:       f.ModTime,
+			CorrelationID: f.CorrelationID,
+		}
+	}
+
+	return result
+}
+
+// WriteManifestCSV writes the manifest to w as CSV, with a header row. Values
+// that aren't known, such as the step of a file written outside of a step or
+// the modification time of a file from an older archive, are left empty.
+func (r *DebugArchiveReader) WriteManifestCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(debugManifestColumns); err != nil {
+		return err
+	}
+
+	for _, e := range r.Manifest() {
+		step := ""
+		if e.Step >= 0 {
+			step = strconv.Itoa(e.Step)
+		}
+		modTime := ""
+		if !e.ModTime.IsZero() {
+			modTime = e.ModTime.UTC().Format(time.RFC3339)
+		}
+
+		err := cw.Write([]string{
+			e.Name,
+			step,
+			e.Phase,
+			strconv.FormatInt(e.Size, 10),
+			e.Checksum,
+			modTime,
+			e.CorrelationID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,58 @@
+package terraform
+
+import "fmt"
+
+// debugMaxFilesMarker is the file written once when the archive reaches
+// DebugConfig.MaxFiles entries.
+const debugMaxFilesMarker = "max-files-reached.txt"
+
+// WriteResourceFile writes a per-resource file, such as the connection
+// settings of a provisioner, to the debug archive, unless it has reached its
+// maximum number of entries.
+func (d *debugInfo) WriteResourceFile(name string, data []byte) error {
+	if d == nil {
+		return nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	return d.writeResourceFile(name, data)
+}
+
+// writeResourceFile writes a per-resource file, unless the archive has
+// reached maxFiles files, in which case the file is dropped. Files smaller
+// than bundleSize are added to a bundle rather than written as entries,
+// unless the archive is sorted, and count towards maxFiles as they're added,
+// so a bundle can't take the archive past it. The first file dropped writes
+// the marker instead, so readers know files are missing.
+// Summaries are written with writeFile, and aren't limited. Files are also
+// dropped while the memory limit is exceeded. The lock must be held.
+func (d *debugInfo) writeResourceFile(name string, data []byte) error {
+	if d.underMemoryPressure() {
+		return nil
+	}
+	if d.maxFiles == 0 || d.files < d.maxFiles {
+		// sorted archives hold every file until Close anyway, and a
+		// bundle would keep them in the order they were written
+		if d.bundleSize > 0 && !d.sorted && len(data) < d.bundleSize {
+			return d.bundleFile(name, data)
+		}
+		return d.writeFile(name, data)
+	}
+
+	if d.maxFilesReached {
+		return nil
+	}
+	d.maxFilesReached = true
+
+	msg := fmt.Sprintf(
+		"The archive reached %d files while writing %s.\n"+
+			"No more per-resource files were written after this point, but\n"+
+			"summary files were still written.\n",
+		d.maxFiles, name)
+	return d.writeFile(debugMaxFilesMarker, []byte(msg))
+}
@@ -0,0 +1,105 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// debugMemoryMarker is the file written once when the archive first switches
+// to summary-only writes because of DebugConfig.MemoryLimit. It's written
+// right away, rather than with the summaries, since a process under memory
+// pressure may well be killed before the archive is closed.
+const debugMemoryMarker = "memory-pressure.txt"
+
+// debugMemoryCheckInterval is how often the memory in use is checked if
+// DebugConfig.MemoryCheckInterval isn't set.
+const debugMemoryCheckInterval = time.Second
+
+// debugHeapAlloc returns the bytes of allocated heap objects. It's a variable
+// so tests can simulate memory pressure.
+var debugHeapAlloc = func() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// debugMemoryTransition is an entry in memory-pressure.json: the archive
+// switching to summary-only writes because the heap exceeded the memory
+// limit, or switching back once it no longer did.
+type debugMemoryTransition struct {
+	Throttled bool
+	Phase     string
+	Step      int
+	Time      time.Time
+	HeapAlloc uint64
+	Limit     int
+}
+
+// underMemoryPressure returns whether only summaries are written because the
+// heap exceeded memoryLimit when it was last checked, checking it again if
+// memoryCheckInterval has passed since. Each change is recorded, and files
+// held in the current bundle are written as soon as the limit is exceeded, to
+// release them. The lock must be held.
+func (d *debugInfo) underMemoryPressure() bool {
+	if d.memoryLimit == 0 {
+		return false
+	}
+
+	interval := d.memoryCheckInterval
+	if interval == 0 {
+		interval = debugMemoryCheckInterval
+	}
+	now := time.Now()
+	if !d.memoryChecked.IsZero() && now.Sub(d.memoryChecked) < interval {
+		return d.memoryThrottled
+	}
+	d.memoryChecked = now
+
+	heap := debugHeapAlloc()
+	throttled := heap > uint64(d.memoryLimit)
+	if throttled == d.memoryThrottled {
+		return throttled
+	}
+	d.memoryThrottled = throttled
+	d.memoryTransitions = append(d.memoryTransitions, debugMemoryTransition{
+		Throttled: throttled,
+		Phase:     d.phase,
+		Step:      d.step,
+		Time:      d.entryTime(),
+		HeapAlloc: heap,
+		Limit:     d.memoryLimit,
+	})
+	if !throttled {
+		return false
+	}
+
+	// like the hook events, errors writing these are ignored
+	d.flushBundle()
+	if len(d.memoryTransitions) == 1 {
+		msg := fmt.Sprintf(
+			"The heap reached %d bytes during the %s phase, exceeding the limit of\n"+
+				"%d bytes. Only summary files were written while it was above the\n"+
+				"limit. See memory-pressure.json for when it was.\n",
+			heap, d.phase, d.memoryLimit)
+		d.writeFile(debugMemoryMarker, []byte(msg))
+	}
+	return true
+}
+
+// writeMemoryPressure writes memory-pressure.json, if the memory limit was
+// ever exceeded, with each switch to and from summary-only writes, in the
+// order they happened. The lock must be held.
+func (d *debugInfo) writeMemoryPressure() error {
+	if len(d.memoryTransitions) == 0 {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(d.memoryTransitions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("memory-pressure.json", js)
+}
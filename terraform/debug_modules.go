@@ -0,0 +1,125 @@
+package terraform
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/config/module"
+)
+
+// DebugModule is a module call of the configuration, as recorded in
+// modules.json of the debug archive.
+type DebugModule struct {
+	// Address is the address of the module instance, i.e.
+	// "module.network.module.subnets".
+	Address string
+
+	// Source is the source as written in the configuration, and Resolved
+	// the source address it was loaded from after detection, i.e.
+	// "github.com/hashicorp/example?ref=v1.0.0" and
+	// "git::https://github.com/hashicorp/example.git?ref=v1.0.0".
+	Source   string
+	Resolved string
+
+	// Kind is the kind of source: "local", "git", "mercurial", "s3",
+	// "http", or "other" for anything else.
+	Kind string
+
+	// Version is the revision requested from a version control source, if
+	// any. Other sources aren't versioned.
+	Version string `json:",omitempty"`
+}
+
+// writeModules writes modules.json, describing every module call of the
+// configuration. The modules are only written once per archive, for the
+// first configuration with any.
+func (d *debugInfo) writeModules(mod *module.Tree) error {
+	if d == nil || mod == nil {
+		return nil
+	}
+
+	var modules []DebugModule
+	debugModuleCalls(mod, &modules)
+	if len(modules) == 0 {
+		return nil
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Address < modules[j].Address
+	})
+
+	js, err := json.MarshalIndent(modules, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.modulesWritten {
+		return nil
+	}
+	d.modulesWritten = true
+
+	return d.writeFile("modules.json", js)
+}
+
+// debugModuleCalls appends the module calls of t and its descendents to
+// modules.
+func debugModuleCalls(t *module.Tree, modules *[]DebugModule) {
+	c := t.Config()
+	if c == nil {
+		return
+	}
+
+	children := t.Children()
+	for _, m := range c.Modules {
+		child, ok := children[m.Name]
+		if !ok {
+			continue
+		}
+
+		dm := DebugModule{
+			Address:  strings.TrimSuffix(debugModulePrefix(child.Path()), "."),
+			Source:   m.Source,
+			Resolved: child.Source(),
+		}
+		dm.Kind, dm.Version = debugModuleSourceKind(dm.Resolved)
+		*modules = append(*modules, dm)
+
+		debugModuleCalls(child, modules)
+	}
+}
+
+// debugModuleSourceKind returns the kind of a resolved module source, and
+// the version requested if it's a version control source.
+func debugModuleSourceKind(source string) (string, string) {
+	forced := ""
+	if idx := strings.Index(source, "::"); idx >= 0 {
+		forced, source = source[:idx], source[idx+2:]
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return "other", ""
+	}
+	if forced == "" {
+		forced = u.Scheme
+	}
+
+	switch forced {
+	case "file":
+		return "local", ""
+	case "git":
+		return "git", u.Query().Get("ref")
+	case "hg":
+		return "mercurial", u.Query().Get("rev")
+	case "s3":
+		return "s3", ""
+	case "http", "https":
+		return "http", ""
+	}
+
+	return "other", ""
+}
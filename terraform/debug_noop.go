@@ -0,0 +1,49 @@
+package terraform
+
+import (
+	"bytes"
+	"sort"
+)
+
+// recordDiff records whether the diff computed for an instance was empty.
+// An instance is only a no-op if every diff computed for it was empty, since
+// the same instance is diffed again during apply.
+func (d *debugInfo) recordDiff(ii *InstanceInfo, id *InstanceDiff) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.noops == nil {
+		d.noops = make(map[string]bool)
+	}
+
+	addr := ii.HumanId()
+	if noop, ok := d.noops[addr]; ok && !noop {
+		return
+	}
+	d.noops[addr] = id.Empty()
+}
+
+// writeNoops writes noops.txt, the sorted addresses of the instances that
+// needed no changes, if there were any. The lock must be held.
+func (d *debugInfo) writeNoops() error {
+	var addrs []string
+	for addr, noop := range d.noops {
+		if noop {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	sort.Strings(addrs)
+
+	var buf bytes.Buffer
+	for _, addr := range addrs {
+		buf.WriteString(addr + "\n")
+	}
+
+	return d.writeFile("noops.txt", buf.Bytes())
+}
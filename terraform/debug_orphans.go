@@ -0,0 +1,53 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// recordOrphan records a resource in the state with no configuration, which
+// will be destroyed, along with the ID of its primary instance.
+func (d *debugInfo) recordOrphan(addr *ResourceAddress, rs *ResourceState) {
+	if d == nil || addr == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.orphans == nil {
+		d.orphans = make(map[string]string)
+	}
+
+	id := ""
+	if rs != nil && rs.Primary != nil {
+		id = rs.Primary.ID
+	}
+	d.orphans[addr.String()] = id
+}
+
+// writeOrphans writes orphans.txt, the sorted addresses of the resources
+// removed from the configuration, each followed by its ID, or "-" if it has
+// no primary instance, if there were any. The lock must be held.
+func (d *debugInfo) writeOrphans() error {
+	if len(d.orphans) == 0 {
+		return nil
+	}
+
+	addrs := make([]string, 0, len(d.orphans))
+	for addr := range d.orphans {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var buf bytes.Buffer
+	for _, addr := range addrs {
+		id := d.orphans[addr]
+		if id == "" {
+			id = "-"
+		}
+		fmt.Fprintf(&buf, "%s %s\n", addr, id)
+	}
+
+	return d.writeFile("orphans.txt", buf.Bytes())
+}
@@ -0,0 +1,63 @@
+package terraform
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// debugOutcomeFile is the name of the file recording the outcome of the run.
+const debugOutcomeFile = "outcome.json"
+
+// debugOutcome is the content of outcome.json: whether the command the
+// archive was written for succeeded, its exit code, and the last error it
+// reported, if any.
+type debugOutcome struct {
+	Success  bool
+	ExitCode int
+	Error    string `json:",omitempty"`
+}
+
+// SetDebugOutcome records the exit code of the command and the top-level
+// error it reported, if it failed, to be written to outcome.json as the final
+// file of the archive when it's closed by CloseDebugInfo. This is called by
+// the CLI as it exits, whether or not the command succeeded. The last call
+// before the archive is closed wins.
+func SetDebugOutcome(code int, errMsg string) {
+	dbug.setOutcome(code, errMsg)
+}
+
+func (d *debugInfo) setOutcome(code int, errMsg string) {
+	if d == nil {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.outcome = &debugOutcome{
+		Success:  code == 0,
+		ExitCode: code,
+	}
+
+	// commands may report errors they recover from, so the error is only
+	// the cause of the outcome if the command failed
+	if code != 0 {
+		d.outcome.Error = strings.TrimSpace(errMsg)
+	}
+}
+
+// writeOutcome writes outcome.json, if the outcome was recorded. This is
+// written after everything else, so it's always the final file of the
+// archive. The lock must be held.
+func (d *debugInfo) writeOutcome() error {
+	if d.outcome == nil {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(d.outcome, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile(debugOutcomeFile, js)
+}
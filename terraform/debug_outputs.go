@@ -0,0 +1,147 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// debugOutputSensitive replaces the values of sensitive outputs in
+// outputs-changed.json.
+const debugOutputSensitive = "(sensitive)"
+
+// debugOutputValue is the value of a root output, encoded as JSON so it can't
+// change after it's recorded.
+type debugOutputValue struct {
+	sensitive bool
+	value     []byte
+}
+
+// debugOutputChange is an entry of outputs-changed.json: a root output whose
+// value changed during the run. Old is left out for outputs that were added,
+// and New for outputs that were removed. The values of outputs that are
+// sensitive before or after the change are replaced with "(sensitive)".
+type debugOutputChange struct {
+	Name      string
+	Old       json.RawMessage `json:",omitempty"`
+	New       json.RawMessage `json:",omitempty"`
+	Sensitive bool            `json:",omitempty"`
+}
+
+// recordPriorOutputs records the root outputs of s as they were before the
+// run, the first time it's called.
+func (d *debugInfo) recordPriorOutputs(s *State) {
+	if d == nil {
+		return
+	}
+	outputs := debugRootOutputs(s)
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.priorOutputs == nil {
+		d.priorOutputs = outputs
+	}
+}
+
+// recordOutputs records the root outputs of s as the latest values.
+func (d *debugInfo) recordOutputs(s *State) {
+	if d == nil {
+		return
+	}
+	outputs := debugRootOutputs(s)
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.outputs = outputs
+}
+
+// debugRootOutputs returns the values of the root outputs of s. The result
+// is never nil, so a state without outputs is distinguished from none.
+func debugRootOutputs(s *State) map[string]*debugOutputValue {
+	result := make(map[string]*debugOutputValue)
+	if s == nil {
+		return result
+	}
+	root := s.ModuleByPath(rootModulePath)
+	if root == nil {
+		return result
+	}
+
+	for k, o := range root.Outputs {
+		if o == nil {
+			continue
+		}
+		o.Lock()
+		js, err := json.Marshal(o.Value)
+		o.Unlock()
+		if err != nil {
+			js, _ = json.Marshal(fmt.Sprintf("%#v", o.Value))
+		}
+		result[k] = &debugOutputValue{sensitive: o.Sensitive, value: js}
+	}
+	return result
+}
+
+// writeOutputsChanged writes outputs-changed.json, the root outputs whose
+// values changed between the start of the run and the last state recorded,
+// sorted by name, if any changed. The lock must be held.
+func (d *debugInfo) writeOutputsChanged() error {
+	if d.priorOutputs == nil || d.outputs == nil {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for k := range d.priorOutputs {
+		names[k] = true
+	}
+	for k := range d.outputs {
+		names[k] = true
+	}
+
+	sensitive, _ := json.Marshal(debugOutputSensitive)
+
+	var changes []*debugOutputChange
+	for k := range names {
+		before, after := d.priorOutputs[k], d.outputs[k]
+		if before != nil && after != nil && before.sensitive == after.sensitive &&
+			bytes.Equal(before.value, after.value) {
+			continue
+		}
+
+		c := &debugOutputChange{
+			Name: k,
+			Sensitive: (before != nil && before.sensitive) ||
+				(after != nil && after.sensitive),
+		}
+		for _, v := range []struct {
+			src *debugOutputValue
+			dst *json.RawMessage
+		}{{before, &c.Old}, {after, &c.New}} {
+			switch {
+			case v.src == nil:
+			case c.Sensitive:
+				*v.dst = sensitive
+			default:
+				*v.dst = v.src.value
+			}
+		}
+		changes = append(changes, c)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+
+	js, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("outputs-changed.json", js)
+}
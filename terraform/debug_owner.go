@@ -0,0 +1,63 @@
+package terraform
+
+import (
+	"archive/tar"
+	"fmt"
+	"regexp"
+)
+
+// debugOwnerNameMaxLen is the maximum length of a user or group name, the
+// size of the fields in a ustar header.
+const debugOwnerNameMaxLen = 32
+
+// debugOwnerNamePattern matches a valid user or group name, such as
+// "terraform" or "ci-runner".
+var debugOwnerNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*\$?$`)
+
+// debugOwner is the ownership recorded in the tar header of each entry of
+// the archive, so extracting the archive as root preserves it. The zero
+// value leaves the ownership zeroed.
+type debugOwner struct {
+	uid, gid     int
+	uname, gname string
+}
+
+// apply sets the ownership fields of hdr.
+func (o debugOwner) apply(hdr *tar.Header) {
+	hdr.Uid = o.uid
+	hdr.Gid = o.gid
+	hdr.Uname = o.uname
+	hdr.Gname = o.gname
+}
+
+// validateDebugOwner returns an error if the IDs and names aren't valid for
+// the ownership of the archive's entries. Empty names are valid, and mean
+// only the IDs are recorded.
+func validateDebugOwner(uid, gid int, uname, gname string) error {
+	if uid < 0 {
+		return fmt.Errorf("invalid debug archive uid %d", uid)
+	}
+	if gid < 0 {
+		return fmt.Errorf("invalid debug archive gid %d", gid)
+	}
+
+	for _, n := range []struct{ kind, name string }{
+		{"user", uname},
+		{"group", gname},
+	} {
+		switch {
+		case n.name == "":
+		case len(n.name) > debugOwnerNameMaxLen:
+			return fmt.Errorf(
+				"invalid debug archive %s name %q, must be at most %d bytes",
+				n.kind, n.name, debugOwnerNameMaxLen)
+		case !debugOwnerNamePattern.MatchString(n.name):
+			return fmt.Errorf(
+				"invalid debug archive %s name %q, must start with a letter or "+
+					"underscore, followed by letters, digits or any of \"_.-\"",
+				n.kind, n.name)
+		}
+	}
+
+	return nil
+}
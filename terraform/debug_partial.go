@@ -0,0 +1,112 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// debugPartialState is an instance that was applied without an error, but
+// whose resulting state is tainted, or is missing attributes the diff set.
+type debugPartialState struct {
+	Tainted    bool
+	Incomplete []string
+}
+
+// recordApplyDiff records the diff an instance is about to be applied with,
+// so its resulting state can be checked by recordPartialState.
+func (d *debugInfo) recordApplyDiff(ii *InstanceInfo, id *InstanceDiff) {
+	if d == nil || ii == nil || id == nil || id.GetDestroy() {
+		return
+	}
+
+	attrs := make(map[string]string)
+	for k, ad := range id.CopyAttributes() {
+		// the keys of computed set elements aren't known until applied
+		if ad == nil || ad.NewComputed || ad.NewRemoved || ad.New == "" ||
+			strings.Contains(k, "~") {
+			continue
+		}
+		attrs[k] = ad.New
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.applyDiffs == nil {
+		d.applyDiffs = make(map[string]map[string]string)
+	}
+	d.applyDiffs[ii.HumanId()] = attrs
+}
+
+// recordPartialState records an instance that was applied without an error
+// but is tainted, or lacks attributes its diff set to known values. Those
+// are the resources that look fine, but were only partly created or
+// configured. Applies that returned an error are reported elsewhere, and
+// values the provider normalized don't count as missing.
+func (d *debugInfo) recordPartialState(ii *InstanceInfo, is *InstanceState, err error) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	id := ii.HumanId()
+	planned, ok := d.applyDiffs[id]
+	if !ok {
+		return
+	}
+	delete(d.applyDiffs, id)
+
+	if err != nil || is == nil || is.ID == "" {
+		return
+	}
+
+	p := &debugPartialState{Tainted: is.Tainted}
+	for k := range planned {
+		if _, ok := is.Attributes[k]; !ok {
+			p.Incomplete = append(p.Incomplete, k)
+		}
+	}
+	if !p.Tainted && len(p.Incomplete) == 0 {
+		return
+	}
+	sort.Strings(p.Incomplete)
+
+	if d.partialStates == nil {
+		d.partialStates = make(map[string]*debugPartialState)
+	}
+	d.partialStates[id] = p
+}
+
+// writePartialStates writes partial-states.txt, the sorted addresses of the
+// instances recorded by recordPartialState, each followed by whether it's
+// tainted and the attributes it's missing. The lock must be held.
+func (d *debugInfo) writePartialStates() error {
+	if len(d.partialStates) == 0 {
+		return nil
+	}
+
+	addrs := make([]string, 0, len(d.partialStates))
+	for addr := range d.partialStates {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var buf bytes.Buffer
+	for _, addr := range addrs {
+		p := d.partialStates[addr]
+
+		var details []string
+		if p.Tainted {
+			details = append(details, "tainted")
+		}
+		if len(p.Incomplete) > 0 {
+			details = append(details, "incomplete: "+strings.Join(p.Incomplete, ", "))
+		}
+		fmt.Fprintf(&buf, "%s %s\n", addr, strings.Join(details, "; "))
+	}
+
+	return d.writeFile("partial-states.txt", buf.Bytes())
+}
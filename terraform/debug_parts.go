@@ -0,0 +1,126 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// debugNextPartFile is the name of the entry at the end of each rotated
+// archive part, containing the file name of the part that follows it.
+const debugNextPartFile = "next-part"
+
+// debugPartIndex is the content of index.json, written to the last part of
+// an archive that's split into parts. Along with the next-part pointers,
+// this allows the complete archive to be reassembled from any of its parts.
+type debugPartIndex struct {
+	Parts []debugPartInfo
+}
+
+type debugPartInfo struct {
+	Part int
+
+	// File is the file name of the part
+	File string
+
+	// Entries is the number of files written to the part
+	Entries int
+
+	// Resources are the instances with hook events recorded in the part
+	Resources []string
+}
+
+// debugPartFile returns the file name of the given part of the named
+// archive. The first part has no part number, so that an archive that's
+// never rotated has the same name as before parts were introduced.
+func debugPartFile(name string, part int) string {
+	if part == 0 {
+		return name + ".tar.gz"
+	}
+	return fmt.Sprintf("%s.part%d.tar.gz", name, part)
+}
+
+// recordPartResource records that a hook event for the instance ii is being
+// written to the current part. The lock must be held.
+func (d *debugInfo) recordPartResource(ii *InstanceInfo) {
+	if ii == nil {
+		return
+	}
+
+	for len(d.partResources) <= d.part {
+		d.partResources = append(d.partResources, make(map[string]bool))
+	}
+	d.partResources[d.part][ii.HumanId()] = true
+}
+
+// writeNextPart writes the pointer from the current part to the next. It's
+// written as a plain entry, so it isn't counted towards the part's entries
+// and can't cause a rotation itself. The lock must be held.
+func (d *debugInfo) writeNextPart() error {
+	d.partEntryCounts = append(d.partEntryCounts, d.partCount)
+	return d.writeEntry(debugNextPartFile, []byte(debugPartFile(d.file, d.part+1)))
+}
+
+// writePartIndex writes index.json to the last part, if the archive is being
+// split into parts. The lock must be held.
+func (d *debugInfo) writePartIndex() error {
+	if d.partEntries == 0 || d.nextPart == nil {
+		return nil
+	}
+
+	counts := append(d.partEntryCounts, d.partCount)
+
+	var index debugPartIndex
+	for i := 0; i <= d.part; i++ {
+		info := debugPartInfo{
+			Part:      i,
+			File:      debugPartFile(d.file, i),
+			Entries:   counts[i],
+			Resources: []string{},
+		}
+		if i < len(d.partResources) {
+			for r := range d.partResources[i] {
+				info.Resources = append(info.Resources, r)
+			}
+			sort.Strings(info.Resources)
+		}
+		index.Parts = append(index.Parts, info)
+	}
+
+	js, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeEntry("index.json", js)
+}
+
+// NextPart returns the file name of the archive part following this one, or
+// an empty string if this is the last part, or the archive isn't split.
+func (r *DebugArchiveReader) NextPart() string {
+	if f := r.File(debugNextPartFile); f != nil {
+		return string(f.Data)
+	}
+	return ""
+}
+
+// PartFiles returns the file names of all the parts of the archive, in
+// order, if this is the last part of an archive that's split into parts.
+// Otherwise it returns nil, and NextPart can be followed to the last part.
+func (r *DebugArchiveReader) PartFiles() ([]string, error) {
+	f := r.File("index.json")
+	if f == nil {
+		return nil, nil
+	}
+
+	var index debugPartIndex
+	if err := json.Unmarshal(f.Data, &index); err != nil {
+		return nil, err
+	}
+
+	files := make([]string, len(index.Parts))
+	for i, p := range index.Parts {
+		files[i] = p.File
+	}
+	return files, nil
+}
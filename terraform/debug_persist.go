@@ -0,0 +1,63 @@
+package terraform
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// debugStatePersist is a single state update, as recorded in
+// state-persists.json. Only a summary of the state is kept, since the full
+// state could be huge.
+type debugStatePersist struct {
+	Time      time.Time
+	Phase     string
+	Serial    int64
+	Modules   int
+	Resources int
+}
+
+// recordStatePersist records a summary of the state after an update.
+func (d *debugInfo) recordStatePersist(s *State) {
+	if d == nil || s == nil {
+		return
+	}
+
+	p := debugStatePersist{
+		Time:    time.Now(),
+		Serial:  s.Serial,
+		Modules: len(s.Modules),
+	}
+	for _, m := range s.Modules {
+		p.Resources += len(m.Resources)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	p.Phase = d.phase
+	d.statePersists = append(d.statePersists, p)
+}
+
+// writeStatePersists writes state-persists.json, if the state was updated.
+// The times are left out of sorted archives. The lock must be held.
+func (d *debugInfo) writeStatePersists() error {
+	if len(d.statePersists) == 0 {
+		return nil
+	}
+
+	persists := d.statePersists
+	if d.sorted {
+		persists = make([]debugStatePersist, len(d.statePersists))
+		for i, p := range d.statePersists {
+			p.Time = time.Time{}
+			persists[i] = p
+		}
+	}
+
+	js, err := json.MarshalIndent(persists, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("state-persists.json", js)
+}
@@ -0,0 +1,84 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// DebugPlugin describes a single plugin that was resolved for this run, as
+// recorded in the plugins.json file of the debug archive.
+type DebugPlugin struct {
+	// Name is the name of the plugin, i.e. "aws".
+	Name string
+
+	// Kind is the plugin type, either "provider" or "provisioner".
+	Kind string
+
+	// Version is the plugin version, if known. Plugins compiled into the
+	// Terraform binary report the Terraform version, and other plugins the
+	// version in their file name, i.e. "terraform-provider-aws_v0.1.0".
+	Version string `json:",omitempty"`
+
+	// Path is the resolved path to the plugin binary. This is omitted when
+	// DebugConfig.OmitPluginPaths is set.
+	Path string `json:",omitempty"`
+
+	// Internal is true if the plugin is compiled into the Terraform binary.
+	Internal bool
+}
+
+var (
+	debugPluginsLock sync.Mutex
+	debugPlugins     []DebugPlugin
+)
+
+// SetDebugPlugins records the set of resolved plugins so that they are
+// written to the debug archive. This may be called before or after
+// SetDebugInfo; if the archive doesn't exist yet, the plugins are written
+// once it is created.
+func SetDebugPlugins(plugins []DebugPlugin) error {
+	debugPluginsLock.Lock()
+	debugPlugins = plugins
+	debugPluginsLock.Unlock()
+
+	return dbug.writePlugins()
+}
+
+// writePlugins writes the recorded plugins to plugins.json.
+func (d *debugInfo) writePlugins() error {
+	if d == nil {
+		return nil
+	}
+
+	debugPluginsLock.Lock()
+	plugins := make([]DebugPlugin, len(debugPlugins))
+	copy(plugins, debugPlugins)
+	debugPluginsLock.Unlock()
+
+	if len(plugins) == 0 {
+		return nil
+	}
+
+	// Plugin paths can reveal local filesystem layout, so allow them to be
+	// left out of the archive.
+	if d.omitPluginPaths {
+		for i := range plugins {
+			plugins[i].Path = ""
+		}
+	}
+
+	sort.Slice(plugins, func(i, j int) bool {
+		if plugins[i].Kind != plugins[j].Kind {
+			return plugins[i].Kind < plugins[j].Kind
+		}
+		return plugins[i].Name < plugins[j].Name
+	})
+
+	js, err := json.MarshalIndent(plugins, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.WriteFile("plugins.json", js)
+}
@@ -0,0 +1,67 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// debugProvenanceUnknown is the origin of vertices that weren't added by a
+// transform run while debugging, such as those of graphs built by hand.
+const debugProvenanceUnknown = "unknown"
+
+// graphTransformerName returns the name of the type of t without its
+// package, i.e. "ConfigTransformer".
+func graphTransformerName(t GraphTransformer) string {
+	name := fmt.Sprintf("%T", t)
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	return name
+}
+
+// transform runs t on g. When debugging, the name of t is recorded as the
+// origin of every vertex it adds, unless a transform nested within t, such
+// as one of the transforms of GraphTransformMulti, already claimed it.
+func (g *Graph) transform(t GraphTransformer) error {
+	if dbug == nil {
+		return t.Transform(g)
+	}
+
+	before := make(map[dag.Vertex]struct{})
+	for _, v := range g.Vertices() {
+		before[v] = struct{}{}
+	}
+
+	err := t.Transform(g)
+
+	name := graphTransformerName(t)
+	for _, v := range g.Vertices() {
+		if _, ok := before[v]; ok {
+			continue
+		}
+		if g.provenance == nil {
+			g.provenance = make(map[dag.Vertex]string)
+		}
+		if _, ok := g.provenance[v]; !ok {
+			g.provenance[v] = name
+		}
+	}
+
+	return err
+}
+
+// debugProvenance returns the name of the transform that added each vertex
+// of g, by vertex name.
+func debugProvenance(g *Graph) map[string]string {
+	result := make(map[string]string)
+	for _, v := range g.Vertices() {
+		origin, ok := g.provenance[v]
+		if !ok {
+			origin = debugProvenanceUnknown
+		}
+		result[dag.VertexName(v)] = origin
+	}
+	return result
+}
@@ -0,0 +1,289 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// debugProviderConfigFile is the name of the file recording the fingerprints
+// of the provider configurations.
+const debugProviderConfigFile = "provider-config-fingerprint.json"
+
+// debugProviderConfig is the content of provider-config-fingerprint.json.
+type debugProviderConfig struct {
+	// Providers are the fingerprints of the effective configuration of each
+	// provider, by address, i.e. "module.child.provider.aws.west".
+	Providers map[string]*debugProviderFingerprint
+
+	// Previous is the name of the archive of the previous run the
+	// fingerprints were compared with, and Changes the providers whose
+	// configuration differs from that run. Previous is empty if there was
+	// nothing to compare with.
+	Previous string                       `json:",omitempty"`
+	Changes  []*debugProviderConfigChange `json:",omitempty"`
+}
+
+// debugProviderFingerprint is the fingerprint of a provider's configuration.
+// Only hashes of the values are recorded, and attributes named like secrets
+// are excluded entirely, so rotating a credential isn't reported as a change.
+type debugProviderFingerprint struct {
+	// Fingerprint is a hash of all the attributes, and Attributes the hash
+	// of each attribute, by its flattened name, i.e. "assume_role.0.role_arn".
+	Fingerprint string
+	Attributes  map[string]string
+
+	// Excluded are the attributes left out as secrets.
+	Excluded []string `json:",omitempty"`
+}
+
+// debugProviderConfigChange describes how the configuration of a provider
+// differs from the previous run.
+type debugProviderConfigChange struct {
+	Provider string
+
+	// New is set if the provider wasn't configured in the previous run, and
+	// Changed, Added and Removed are the attributes that differ otherwise.
+	New     bool     `json:",omitempty"`
+	Changed []string `json:",omitempty"`
+	Added   []string `json:",omitempty"`
+	Removed []string `json:",omitempty"`
+}
+
+// recordProviderConfig records the fingerprint of the configuration the
+// provider n in the module at path is configured with. A provider configured
+// in several walks keeps the fingerprint of its last configuration.
+func (d *debugInfo) recordProviderConfig(path []string, n string, cfg *ResourceConfig) {
+	if d == nil || cfg == nil {
+		return
+	}
+
+	fp := newDebugProviderFingerprint(cfg.Config)
+
+	addr := "provider." + n
+	if len(path) > 1 {
+		addr = modulePrefixStr(path) + "." + addr
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.providerConfigs == nil {
+		d.providerConfigs = make(map[string]*debugProviderFingerprint)
+	}
+	d.providerConfigs[addr] = fp
+}
+
+// newDebugProviderFingerprint returns the fingerprint of the provider
+// configuration c.
+func newDebugProviderFingerprint(c map[string]interface{}) *debugProviderFingerprint {
+	flat := make(map[string]interface{})
+	for k, v := range c {
+		debugFlattenConfig(k, v, flat)
+	}
+
+	result := &debugProviderFingerprint{Attributes: make(map[string]string)}
+	excluded := make(map[string]bool)
+	for k, v := range flat {
+		if name := debugSecretAttr(k); name != "" {
+			excluded[name] = true
+			continue
+		}
+
+		js, err := json.Marshal(v)
+		if err != nil {
+			js = []byte(fmt.Sprintf("%#v", v))
+		}
+		sum := sha256.Sum256(js)
+		result.Attributes[k] = hex.EncodeToString(sum[:8])
+	}
+
+	keys := make([]string, 0, len(result.Attributes))
+	for k := range result.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\n", k, result.Attributes[k])
+	}
+	result.Fingerprint = hex.EncodeToString(h.Sum(nil))
+
+	for k := range excluded {
+		result.Excluded = append(result.Excluded, k)
+	}
+	sort.Strings(result.Excluded)
+
+	return result
+}
+
+// debugFlattenConfig flattens the configuration value v into flat, with the
+// elements of nested lists and maps named like the attributes of the state,
+// i.e. "assume_role.0.role_arn".
+func debugFlattenConfig(k string, v interface{}, flat map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for mk, mv := range v {
+			debugFlattenConfig(k+"."+mk, mv, flat)
+		}
+	case []map[string]interface{}:
+		for i, mv := range v {
+			debugFlattenConfig(fmt.Sprintf("%s.%d", k, i), mv, flat)
+		}
+	case []interface{}:
+		for i, lv := range v {
+			debugFlattenConfig(fmt.Sprintf("%s.%d", k, i), lv, flat)
+		}
+	default:
+		flat[k] = v
+	}
+}
+
+// writeProviderConfigs writes provider-config-fingerprint.json, if any
+// providers were configured, comparing the fingerprints with those of the
+// previous run with the same correlation ID, if there is one. The lock must
+// be held.
+func (d *debugInfo) writeProviderConfigs() error {
+	if len(d.providerConfigs) == 0 {
+		return nil
+	}
+
+	result := &debugProviderConfig{Providers: d.providerConfigs}
+	if name, prev := d.previousProviderConfigs(); prev != nil {
+		result.Previous = name
+		result.Changes = debugProviderConfigChanges(prev.Providers, d.providerConfigs)
+	}
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile(debugProviderConfigFile, js)
+}
+
+// previousProviderConfigs returns the name of the most recent archive in the
+// same directory written by a previous run with the same correlation ID, and
+// the provider fingerprints recorded in it. This is best-effort: nil is
+// returned if the archive isn't written to a directory, or no previous archive
+// with fingerprints can be read, which is always the case for a generated
+// correlation ID.
+func (d *debugInfo) previousProviderConfigs() (string, *debugProviderConfig) {
+	if d.path == "" || d.correlationID == "" {
+		return "", nil
+	}
+
+	dir := filepath.Dir(d.path)
+	paths, err := filepath.Glob(filepath.Join(dir, "debug-*-"+d.correlationID+"*.tar.gz"))
+	if err != nil {
+		return "", nil
+	}
+
+	type candidate struct {
+		path string
+		info os.FileInfo
+	}
+	var candidates []candidate
+	for _, p := range paths {
+		base := filepath.Base(p)
+		if strings.HasPrefix(base, d.file+".") {
+			continue
+		}
+		// only the archives of exactly this correlation ID, not of one it's
+		// a prefix of
+		name := strings.TrimSuffix(strings.SplitN(base, ".part", 2)[0], ".tar.gz")
+		if !strings.HasSuffix(name, "-"+d.correlationID) {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{p, info})
+	}
+
+	// the summaries are written to the last part, which is written last
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].info.ModTime().After(candidates[j].info.ModTime())
+	})
+
+	for _, c := range candidates {
+		f, err := os.Open(c.path)
+		if err != nil {
+			continue
+		}
+
+		var prev *debugProviderConfig
+		name := ""
+		err = walkDebugArchive(f, func(n string, _ int, af *DebugArchiveFile) error {
+			if af.Name != debugProviderConfigFile {
+				return nil
+			}
+			var pc debugProviderConfig
+			if err := json.Unmarshal(af.Data, &pc); err != nil {
+				return err
+			}
+			name, prev = n, &pc
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			log.Printf("[WARN] not comparing provider configurations with %s: %s", c.path, err)
+			continue
+		}
+		if prev != nil {
+			return name, prev
+		}
+	}
+
+	return "", nil
+}
+
+// debugProviderConfigChanges returns the providers whose configuration
+// differs between prev and current. Providers that are no longer configured
+// aren't reported, since a run doesn't necessarily configure every provider.
+func debugProviderConfigChanges(prev, current map[string]*debugProviderFingerprint) []*debugProviderConfigChange {
+	var result []*debugProviderConfigChange
+	for addr, fp := range current {
+		old, ok := prev[addr]
+		if !ok {
+			result = append(result, &debugProviderConfigChange{Provider: addr, New: true})
+			continue
+		}
+		if old.Fingerprint == fp.Fingerprint {
+			continue
+		}
+
+		change := &debugProviderConfigChange{Provider: addr}
+		for k, h := range fp.Attributes {
+			oh, ok := old.Attributes[k]
+			switch {
+			case !ok:
+				change.Added = append(change.Added, k)
+			case oh != h:
+				change.Changed = append(change.Changed, k)
+			}
+		}
+		for k := range old.Attributes {
+			if _, ok := fp.Attributes[k]; !ok {
+				change.Removed = append(change.Removed, k)
+			}
+		}
+		sort.Strings(change.Changed)
+		sort.Strings(change.Added)
+		sort.Strings(change.Removed)
+		result = append(result, change)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Provider < result[j].Provider
+	})
+	return result
+}
@@ -0,0 +1,246 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/config/module"
+)
+
+// DebugSchemaProvider is an optional interface implemented by resource
+// providers that can describe the schemas of their configuration, resource
+// types and data sources. The schemas are written to the debug archive when
+// DebugConfig.Schemas is set, so tools reading the archive can render
+// attributes with their types.
+type DebugSchemaProvider interface {
+	DebugSchemas() (*DebugProviderSchema, error)
+}
+
+// DebugProviderSchema describes the schemas of a provider.
+type DebugProviderSchema struct {
+	Provider      map[string]*DebugAttributeSchema
+	ResourceTypes map[string]map[string]*DebugAttributeSchema
+	DataSources   map[string]map[string]*DebugAttributeSchema
+}
+
+// DebugAttributeSchema describes the schema of a single attribute.
+type DebugAttributeSchema struct {
+	// Type is the type of the attribute: "bool", "int", "float", "string",
+	// "list", "set" or "map".
+	Type string
+
+	Optional  bool `json:",omitempty"`
+	Required  bool `json:",omitempty"`
+	Computed  bool `json:",omitempty"`
+	ForceNew  bool `json:",omitempty"`
+	Sensitive bool `json:",omitempty"`
+
+	// Elem is the schema of the elements of a list, set or map of
+	// primitives, and Block the attributes of the elements of a list or set
+	// of nested blocks.
+	Elem  *DebugAttributeSchema            `json:",omitempty"`
+	Block map[string]*DebugAttributeSchema `json:",omitempty"`
+}
+
+// debugProviderSchemas is the content of schemas/NAME.json. Identical
+// schemas are only written once, in Schemas, keyed by a hash of their
+// content, and each resource type and data source refers to its schema by
+// that key.
+type debugProviderSchemas struct {
+	Provider      string            `json:",omitempty"`
+	ResourceTypes map[string]string `json:",omitempty"`
+	DataSources   map[string]string `json:",omitempty"`
+
+	Schemas map[string]map[string]*DebugAttributeSchema
+}
+
+// add adds a schema to s, returning its key.
+func (s *debugProviderSchemas) add(schema map[string]*DebugAttributeSchema) (string, error) {
+	js, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(js)
+	key := hex.EncodeToString(sum[:6])
+	s.Schemas[key] = schema
+	return key, nil
+}
+
+// writeProviderSchemas reads the schema of each provider used by the
+// configuration, or of every provider if there's no configuration, so the
+// values of the attributes they mark sensitive can be redacted, and writes
+// them to schemas/NAME.json if DebugConfig.Schemas is set. Each provider's
+// schema is only read once per archive, and providers that can't describe
+// their schema are skipped.
+func (d *debugInfo) writeProviderSchemas(mod *module.Tree, providers map[string]ResourceProviderFactory) error {
+	if d == nil {
+		return nil
+	}
+
+	names := debugProviderNames(mod, providers)
+	for _, name := range names {
+		d.Lock()
+		_, read := d.providerSchemas[name]
+		d.Unlock()
+		if read {
+			continue
+		}
+
+		schema, err := debugProviderSchema(providers[name])
+		if err != nil {
+			log.Printf("[WARN] not reading the schema of provider %q for the debug archive: %s", name, err)
+			continue
+		}
+
+		var js []byte
+		if schema != nil {
+			if js, err = debugProviderSchemasJSON(schema); err != nil {
+				return err
+			}
+		}
+
+		d.Lock()
+		if d.providerSchemas == nil {
+			d.providerSchemas = make(map[string]*DebugProviderSchema)
+		}
+		if _, ok := d.providerSchemas[name]; !ok {
+			d.providerSchemas[name] = schema
+			if d.schemas && schema != nil && !d.closed {
+				err = d.writeFile(fmt.Sprintf("schemas/%s.json", name), js)
+			}
+		}
+		d.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resourceSchema returns the schema of the managed resource type typ, from
+// the schema of the provider read by writeProviderSchemas, or nil if it
+// wasn't described. The lock must be held.
+func (d *debugInfo) resourceSchema(typ string) map[string]*DebugAttributeSchema {
+	schema := d.providerSchemas[resourceProvider(typ, "")]
+	if schema == nil {
+		return nil
+	}
+	return schema.ResourceTypes[typ]
+}
+
+// debugSchemaSensitive returns whether the flattened attribute k, such as
+// "password" or "master.0.password", is, or is nested within, an attribute
+// the schema marks sensitive.
+func debugSchemaSensitive(schema map[string]*DebugAttributeSchema, k string) bool {
+	parts := strings.Split(k, ".")
+	for len(parts) > 0 {
+		s := schema[parts[0]]
+		if s == nil {
+			return false
+		}
+		if s.Sensitive {
+			return true
+		}
+
+		// skip the index or hash of the nested block
+		if s.Block == nil || len(parts) < 3 {
+			return false
+		}
+		schema, parts = s.Block, parts[2:]
+	}
+	return false
+}
+
+// debugProviderNames returns the sorted names of the providers the
+// configuration uses, or of all providers if mod is nil.
+func debugProviderNames(mod *module.Tree, providers map[string]ResourceProviderFactory) []string {
+	used := make(map[string]bool)
+	if mod == nil {
+		for name := range providers {
+			used[name] = true
+		}
+	} else {
+		debugModuleProviders(mod, used)
+	}
+
+	var result []string
+	for name := range used {
+		if _, ok := providers[name]; ok {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// debugModuleProviders adds the providers configured or used by the
+// resources of t and its descendents to used.
+func debugModuleProviders(t *module.Tree, used map[string]bool) {
+	if c := t.Config(); c != nil {
+		for _, p := range c.ProviderConfigs {
+			used[p.Name] = true
+		}
+		for _, r := range c.Resources {
+			used[resourceProvider(r.Type, "")] = true
+		}
+	}
+
+	for _, child := range t.Children() {
+		debugModuleProviders(child, used)
+	}
+}
+
+// debugProviderSchema returns the schema of the provider created by f, or
+// nil if it can't describe its schema.
+func debugProviderSchema(f ResourceProviderFactory) (*DebugProviderSchema, error) {
+	p, err := f()
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := p.(ResourceProviderCloser); ok {
+		defer c.Close()
+	}
+
+	sp, ok := p.(DebugSchemaProvider)
+	if !ok {
+		return nil, nil
+	}
+
+	return sp.DebugSchemas()
+}
+
+// debugProviderSchemasJSON returns the content of schemas/NAME.json for
+// schema, with identical schemas deduplicated.
+func debugProviderSchemasJSON(schema *DebugProviderSchema) ([]byte, error) {
+	result := &debugProviderSchemas{
+		ResourceTypes: make(map[string]string),
+		DataSources:   make(map[string]string),
+		Schemas:       make(map[string]map[string]*DebugAttributeSchema),
+	}
+
+	var err error
+	if schema.Provider != nil {
+		if result.Provider, err = result.add(schema.Provider); err != nil {
+			return nil, err
+		}
+	}
+	for name, s := range schema.ResourceTypes {
+		if result.ResourceTypes[name], err = result.add(s); err != nil {
+			return nil, err
+		}
+	}
+	for name, s := range schema.DataSources {
+		if result.DataSources[name], err = result.add(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.MarshalIndent(result, "", "  ")
+}
@@ -0,0 +1,68 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// debugConnInfoKeys are the connection settings that are safe to record in
+// the debug archive. The values of any other settings, including passwords,
+// private keys and certificates, are redacted, since new settings are more
+// likely to be credentials than not.
+var debugConnInfoKeys = map[string]bool{
+	"type":         true,
+	"host":         true,
+	"port":         true,
+	"user":         true,
+	"agent":        true,
+	"timeout":      true,
+	"script_path":  true,
+	"https":        true,
+	"insecure":     true,
+	"bastion_host": true,
+	"bastion_port": true,
+	"bastion_user": true,
+}
+
+// debugConnInfo is the content of the files in the connections directory.
+type debugConnInfo struct {
+	Instance    string
+	Provisioner string
+
+	// Connection is the evaluated connection settings, with the values of
+	// anything credential-like replaced with "<redacted>".
+	Connection map[string]string
+
+	// Redacted are the names of the redacted settings.
+	Redacted []string `json:",omitempty"`
+}
+
+// writeConnInfo records the evaluated connection settings used by a
+// provisioner, with any credentials redacted, so that connection failures
+// can be traced to the host, port and user that were actually used.
+func (d *debugInfo) writeConnInfo(ii *InstanceInfo, provisioner string, connInfo map[string]string) error {
+	if d == nil {
+		return nil
+	}
+
+	info := debugConnInfo{
+		Instance:    ii.HumanId(),
+		Provisioner: provisioner,
+		Connection:  make(map[string]string),
+	}
+	for k, v := range connInfo {
+		if !debugConnInfoKeys[k] {
+			v = "<redacted>"
+			info.Redacted = append(info.Redacted, k)
+		}
+		info.Connection[k] = v
+	}
+	sort.Strings(info.Redacted)
+
+	js, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.WriteResourceFile("connections/"+info.Instance+"-"+provisioner+".json", js)
+}
@@ -0,0 +1,239 @@
+package terraform
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// debugArchiveFormatVersion is the version of the debug archive layout,
+// recorded in the format-version file at the start of each archive. This
+// must be incremented whenever the layout changes, and DebugArchiveReader
+// updated to read both layouts.
+//
+// Version 1 archives have no format-version file, and the step and phase
+// prefix is applied to the whole file name, i.e. "3-plan-graphs/plan.dot".
+// Version 2 archives apply the prefix to the base name only, so files can be
+// grouped in subdirectories, i.e. "graphs/3-plan-plan.dot". Version 3
+// archives may also combine small files into bundles, which are unpacked when
+// the archive is read. See DebugConfig.BundleSize.
+const debugArchiveFormatVersion = 3
+
+// debugArchiveVersionFile is the name of the file recording the format
+// version, relative to the archive root.
+const debugArchiveVersionFile = "format-version"
+
+// debugEntryPrefix matches the step and phase prefix of an archive entry.
+var debugEntryPrefix = regexp.MustCompile(`^(\d+)-([^-]*)-(.+)$`)
+
+// DebugArchiveReader reads the contents of a debug archive written by a
+// current or earlier version of Terraform.
+type DebugArchiveReader struct {
+	// Version is the format version of the archive. Archives written before
+	// the version was recorded are version 1.
+	Version int
+
+	// Name is the archive root directory name.
+	Name string
+
+	// CorrelationID is the correlation ID of the run that wrote the archive,
+	// or empty if it wasn't recorded. See DebugConfig.CorrelationID.
+	CorrelationID string
+
+	// Files are the files in the archive, in the order they were written.
+	// The files of a bundle are in the order the bundle was written, so
+	// their steps may be lower than the files before them.
+	Files []*DebugArchiveFile
+}
+
+// DebugArchiveFile is a single file read from a debug archive.
+type DebugArchiveFile struct {
+	// Name is the path of the file relative to the archive root, without
+	// the step and phase prefix, i.e. "graphs/plan.dot".
+	Name string
+
+	// Step and Phase are the step counter and operation phase when the file
+	// was written. Files that aren't written in a step, such as the
+	// format-version file, have a Step of -1.
+	Step  int
+	Phase string
+
+	// ModTime is the time the file was written. It's zero for files from
+	// archives written before the time was recorded.
+	ModTime time.Time
+
+	// Label is the label the entry was written with, if any, from the
+	// archive-meta.json entry before it. See DebugConfig.Label.
+	Label string
+
+	// CorrelationID is the correlation ID of the run the entry was written
+	// by, if any. See DebugConfig.CorrelationID.
+	CorrelationID string
+
+	// Wave is the wave of the apply graph the resource the entry was
+	// written for was in, if the archive is tagged by wave. It's joined from
+	// wave-entries.json, which is written last, so it's only set by
+	// NewDebugArchiveReader. See DebugConfig.Waves.
+	Wave int
+
+	Data []byte
+}
+
+// NewDebugArchiveReader reads the entire debug archive from r.
+func NewDebugArchiveReader(r io.Reader) (*DebugArchiveReader, error) {
+	result := &DebugArchiveReader{}
+	err := walkDebugArchive(r, func(name string, version int, f *DebugArchiveFile) error {
+		result.Name = name
+		result.Version = version
+		if result.CorrelationID == "" {
+			result.CorrelationID = f.CorrelationID
+		}
+		result.Files = append(result.Files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Version == 0 {
+		result.Version = 1
+	}
+	if err := joinDebugWaveEntries(result.Files); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// WalkDebugArchive calls fn for each file in the debug archive read from r,
+// in the order they were written, with bundles unpacked. Unlike
+// NewDebugArchiveReader, only one file is held in memory at a time, so this
+// is suitable for large archives, but the wave of each file isn't known.
+// Walking stops at the first error returned by fn.
+func WalkDebugArchive(r io.Reader, fn func(f *DebugArchiveFile) error) error {
+	return walkDebugArchive(r, func(_ string, _ int, f *DebugArchiveFile) error {
+		return fn(f)
+	})
+}
+
+// walkDebugArchive calls fn with the archive root directory name and format
+// version, along with each file. The format-version file is always the first
+// file of an archive that has one, so the version is known before any other
+// file names are parsed.
+func walkDebugArchive(r io.Reader, fn func(name string, version int, f *DebugArchiveFile) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+
+	name := ""
+	version := 1
+	var meta debugArchiveMeta
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		parts := strings.SplitN(strings.TrimSuffix(hdr.Name, "/"), "/", 2)
+		if name == "" {
+			name = parts[0]
+		}
+		if hdr.Typeflag == tar.TypeDir || len(parts) < 2 {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		if parts[1] == debugArchiveVersionFile {
+			v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				return fmt.Errorf("invalid debug archive format version %q", data)
+			}
+			if v > debugArchiveFormatVersion {
+				return fmt.Errorf(
+					"unsupported debug archive format version %d, this version "+
+						"of Terraform can read up to version %d",
+					v, debugArchiveFormatVersion)
+			}
+			version = v
+		}
+		if parts[1] == debugArchiveMetaFile {
+			meta = debugArchiveMeta{}
+			if err := json.Unmarshal(data, &meta); err != nil {
+				return fmt.Errorf("invalid debug archive metadata: %s", err)
+			}
+		}
+
+		f := &DebugArchiveFile{Data: data}
+		f.Name, f.Step, f.Phase = parseDebugEntryName(version, parts[1])
+		if hdr.ModTime.Unix() > 0 {
+			f.ModTime = hdr.ModTime
+		}
+		f.Label = meta.Label
+		f.CorrelationID = meta.CorrelationID
+
+		if !isDebugBundle(version, f) {
+			if err := fn(name, version, f); err != nil {
+				return err
+			}
+			continue
+		}
+
+		files, err := unbundleDebugFiles(f)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := fn(name, version, f); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseDebugEntryName splits the step and phase prefix from the name of an
+// entry relative to the archive root, according to the layout of the given
+// format version. The step is -1 if the entry has no prefix.
+func parseDebugEntryName(version int, rel string) (string, int, string) {
+	switch version {
+	case 1:
+		if m := debugEntryPrefix.FindStringSubmatch(rel); m != nil {
+			step, _ := strconv.Atoi(m[1])
+			return m[3], step, m[2]
+		}
+	default:
+		dir, file := path.Split(rel)
+		if m := debugEntryPrefix.FindStringSubmatch(file); m != nil {
+			step, _ := strconv.Atoi(m[1])
+			return dir + m[3], step, m[2]
+		}
+	}
+
+	return rel, -1, ""
+}
+
+// File returns the last file written with the given name, or nil if there
+// is no such file.
+func (r *DebugArchiveReader) File(name string) *DebugArchiveFile {
+	for i := len(r.Files) - 1; i >= 0; i-- {
+		if r.Files[i].Name == name {
+			return r.Files[i]
+		}
+	}
+	return nil
+}
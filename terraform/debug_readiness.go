@@ -0,0 +1,181 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// DebugReadiness records when a resource vertex of a graph walk was ready to
+// be evaluated and when its evaluation started, as recorded in
+// readiness.json of the debug archive.
+//
+// A vertex is ready once every vertex it depends on has finished. The walk
+// then schedules it, and it starts once a worker is free, so the time from
+// DependenciesDone to Start is spent waiting on the scheduler and on a
+// worker, rather than on dependencies.
+type DebugReadiness struct {
+	Phase   string
+	Vertex  string
+	Address string
+
+	// DependenciesDone is when the last dependency of the vertex finished,
+	// or when it was scheduled if it has no dependencies. Scheduled is when
+	// the walk began to evaluate it, and Start when it acquired a worker.
+	DependenciesDone time.Time
+	Scheduled        time.Time
+	Start            time.Time
+
+	// SchedulerLag is the time from DependenciesDone to Scheduled, and
+	// WorkerWait the time from Scheduled to Start.
+	SchedulerLag time.Duration
+	WorkerWait   time.Duration
+}
+
+// debugReadiness tracks the vertices of the graph walks.
+type debugReadiness struct {
+	// done is when each vertex finished, and resources the readiness of
+	// each resource vertex.
+	done      map[dag.Vertex]time.Time
+	resources map[dag.Vertex]*DebugReadiness
+}
+
+// recordVertexReady records that the walk of g scheduled v, which happens as
+// soon as the vertices it depends on have finished.
+func (d *debugInfo) recordVertexReady(g *Graph, v dag.Vertex) {
+	if d == nil {
+		return
+	}
+	rn, ok := v.(GraphNodeResource)
+	if !ok || rn.ResourceAddr() == nil {
+		return
+	}
+	now := time.Now()
+	deps := g.DownEdges(v).List()
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.readiness == nil {
+		d.readiness = &debugReadiness{
+			done:      make(map[dag.Vertex]time.Time),
+			resources: make(map[dag.Vertex]*DebugReadiness),
+		}
+	}
+
+	r := &DebugReadiness{
+		Phase:            d.phase,
+		Vertex:           dag.VertexName(v),
+		Address:          debugResourceAddr(rn.ResourceAddr()),
+		DependenciesDone: now,
+		Scheduled:        now,
+	}
+	if len(deps) > 0 {
+		r.DependenciesDone = time.Time{}
+		for _, dep := range deps {
+			if t := d.readiness.done[dep]; t.After(r.DependenciesDone) {
+				r.DependenciesDone = t
+			}
+		}
+	}
+	d.readiness.resources[v] = r
+}
+
+// recordVertexStart records that v acquired a worker to be evaluated.
+func (d *debugInfo) recordVertexStart(v dag.Vertex) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.readiness == nil {
+		return
+	}
+	if r, ok := d.readiness.resources[v]; ok && r.Start.IsZero() {
+		r.Start = time.Now()
+	}
+}
+
+// recordVertexDone records that the walk of v finished, so the vertices that
+// depend on it may be scheduled.
+func (d *debugInfo) recordVertexDone(v dag.Vertex) {
+	if d == nil {
+		return
+	}
+	now := time.Now()
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.readiness == nil {
+		d.readiness = &debugReadiness{
+			done:      make(map[dag.Vertex]time.Time),
+			resources: make(map[dag.Vertex]*DebugReadiness),
+		}
+	}
+	d.readiness.done[v] = now
+}
+
+// writeReadiness writes readiness.json for the resource vertices that were
+// evaluated, in the order they started. Sorted archives leave the times out,
+// and list the vertices by phase and name instead. The lock must be held.
+func (d *debugInfo) writeReadiness() error {
+	if d.readiness == nil {
+		return nil
+	}
+
+	var result []*DebugReadiness
+	for _, r := range d.readiness.resources {
+		// vertices that didn't evaluate anything never needed a worker
+		if r.Start.IsZero() {
+			continue
+		}
+		r.SchedulerLag = r.Scheduled.Sub(r.DependenciesDone)
+		r.WorkerWait = r.Start.Sub(r.Scheduled)
+		result = append(result, r)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Start.Equal(result[j].Start) {
+			return result[i].Start.Before(result[j].Start)
+		}
+		return result[i].Vertex < result[j].Vertex
+	})
+	if d.sorted {
+		for i, r := range result {
+			sr := *r
+			sr.DependenciesDone, sr.Scheduled, sr.Start = time.Time{}, time.Time{}, time.Time{}
+			sr.SchedulerLag, sr.WorkerWait = 0, 0
+			result[i] = &sr
+		}
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].Phase != result[j].Phase {
+				return result[i].Phase < result[j].Phase
+			}
+			return result[i].Vertex < result[j].Vertex
+		})
+	}
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("readiness.json", js)
+}
+
+// debugResourceAddr returns the instance address of a resource in the form
+// used by the hooks, i.e. "module.child.aws_instance.foo.0".
+func debugResourceAddr(addr *ResourceAddress) string {
+	result := addr.stateId()
+	if len(addr.Path) > 0 {
+		result = "module." + strings.Join(addr.Path, ".") + "." + result
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package terraform
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// debugReconciliation compares the changes planned for an apply with the
+// applies that were actually made, as recorded in reconciliation.json.
+type debugReconciliation struct {
+	// Planned is the number of resource instances to be created, updated,
+	// destroyed, and replaced according to the diff. Data sources are not
+	// included, since reading them doesn't call the apply hooks.
+	Planned debugPlannedChanges
+
+	// Expected is the number of applies the planned changes require. A
+	// replacement is a destroy and a create, so it counts twice.
+	Expected int
+
+	// Applied is the number of applies that were made, and Errored is the
+	// number of those that returned an error.
+	Applied int
+	Errored int
+
+	// Status is "match" if Applied equals Expected, or "mismatch".
+	Status string
+}
+
+type debugPlannedChanges struct {
+	Create  int
+	Update  int
+	Destroy int
+	Replace int
+}
+
+// recordPlannedChanges records the number of changes in the diff that is
+// about to be applied.
+func (d *debugInfo) recordPlannedChanges(diff *Diff) {
+	if d == nil {
+		return
+	}
+
+	r := &debugReconciliation{}
+	if diff != nil {
+		for _, m := range diff.Modules {
+			for name, inst := range m.Resources {
+				if strings.HasPrefix(name, "data.") {
+					continue
+				}
+
+				switch inst.ChangeType() {
+				case DiffCreate:
+					r.Planned.Create++
+				case DiffUpdate:
+					r.Planned.Update++
+				case DiffDestroy:
+					r.Planned.Destroy++
+				case DiffDestroyCreate:
+					r.Planned.Replace++
+				}
+
+				// This matches the nodes added to the apply graph by the
+				// DiffTransformer, each of which calls the apply hooks once.
+				if inst.GetDestroy() || inst.GetDestroyDeposed() {
+					r.Expected++
+				}
+				if len(inst.Attributes) > 0 {
+					r.Expected++
+				}
+			}
+		}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.reconciliation = r
+}
+
+// recordApply records a completed apply, if the planned changes are being
+// tracked.
+func (d *debugInfo) recordApply(err error) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.reconciliation == nil {
+		return
+	}
+
+	d.reconciliation.Applied++
+	if err != nil {
+		d.reconciliation.Errored++
+	}
+}
+
+// writeReconciliation writes reconciliation.json, if an apply was made. The
+// lock must be held.
+func (d *debugInfo) writeReconciliation() error {
+	r := d.reconciliation
+	if r == nil {
+		return nil
+	}
+
+	r.Status = "match"
+	if r.Applied != r.Expected {
+		r.Status = "mismatch"
+	}
+
+	js, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("reconciliation.json", js)
+}
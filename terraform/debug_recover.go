@@ -0,0 +1,126 @@
+package terraform
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// DebugRecovery describes the result of RecoverDebugArchive.
+type DebugRecovery struct {
+	// Entries is the number of files recovered, and Bytes is their total
+	// size.
+	Entries int
+	Bytes   int64
+
+	// Err is the error that stopped the recovery, or nil if the entire
+	// source archive was read.
+	Err error
+}
+
+// RecoverDebugArchive reads the intact entries of a debug archive that may
+// not have been closed, such as the archive of a run that crashed, and
+// writes them to a new, complete archive on w. Reading stops at the first
+// entry that can't be read in full. The recovered archive is written in the
+// current format, regardless of the format of the source.
+//
+// An error is only returned if nothing could be read, or the new archive
+// couldn't be written. Otherwise the reason reading stopped is recorded in
+// the returned DebugRecovery.
+func RecoverDebugArchive(r io.Reader, w io.Writer) (*DebugRecovery, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a debug archive: %s", err)
+	}
+	tr := tar.NewReader(gz)
+
+	result := &DebugRecovery{}
+	var d *debugInfo
+	version := 1
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Err = err
+			break
+		}
+
+		parts := strings.SplitN(strings.TrimSuffix(hdr.Name, "/"), "/", 2)
+		if d == nil {
+			// the archive header is written along with the first entry
+			d, err = newDebugInfo(parts[0], w)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if hdr.Typeflag == tar.TypeDir || len(parts) < 2 {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			result.Err = err
+			break
+		}
+
+		if parts[1] == debugArchiveVersionFile {
+			version, err = strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil || version > debugArchiveFormatVersion {
+				d.Close()
+				return nil, fmt.Errorf(
+					"unsupported debug archive format version %q", data)
+			}
+			continue
+		}
+		if parts[1] == debugArchiveMetaFile {
+			var meta debugArchiveMeta
+			if err := json.Unmarshal(data, &meta); err != nil {
+				result.Err = fmt.Errorf("invalid debug archive metadata: %s", err)
+				break
+			}
+			d.label = meta.Label
+			d.correlationID = meta.CorrelationID
+			if err := d.writeMeta(); err != nil {
+				d.Close()
+				return nil, err
+			}
+			continue
+		}
+
+		// Rewrite the entry with its original step and phase, after the
+		// metadata that applies to it. Entries without them keep the step
+		// they'd have been written at.
+		name, step, phase := parseDebugEntryName(version, parts[1])
+		if step >= 0 {
+			d.step = step
+			d.phase = phase
+		}
+		if err := d.WriteFile(name, data); err != nil {
+			d.Close()
+			return nil, err
+		}
+
+		result.Entries++
+		result.Bytes += int64(len(data))
+	}
+
+	if d == nil {
+		if result.Err == nil {
+			result.Err = fmt.Errorf("archive is empty")
+		}
+		return nil, fmt.Errorf("no entries could be recovered: %s", result.Err)
+	}
+
+	if err := d.Close(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
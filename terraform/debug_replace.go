@@ -0,0 +1,120 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// debugReplacement is an entry in replacements.json: an instance that was
+// planned for replacement, and why.
+type debugReplacement struct {
+	Address string
+
+	// Tainted is true if the instance is replaced because it's tainted.
+	Tainted bool `json:",omitempty"`
+
+	// Attributes are the changed attributes that forced the replacement.
+	Attributes []debugReplacementAttr
+}
+
+// debugReplacementAttr is a change to an attribute that forced replacement.
+// The values of sensitive attributes are scrubbed, and new values that
+// aren't known until apply are "<computed>".
+type debugReplacementAttr struct {
+	Name      string
+	Old       string
+	New       string
+	Sensitive bool `json:",omitempty"`
+}
+
+// recordDiffState records whether the instance about to be diffed exists, so
+// that a diff forcing a new instance can be told apart from a create.
+func (d *debugInfo) recordDiffState(ii *InstanceInfo, is *InstanceState) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.diffExists == nil {
+		d.diffExists = make(map[string]bool)
+	}
+	d.diffExists[ii.HumanId()] = is != nil && is.ID != ""
+}
+
+// recordReplacement records the attributes that forced the replacement of an
+// existing instance. Only the last diff of each instance is kept, so an
+// instance diffed again during apply is only recorded if it's still being
+// replaced.
+func (d *debugInfo) recordReplacement(ii *InstanceInfo, id *InstanceDiff) {
+	if d == nil || ii == nil {
+		return
+	}
+
+	addr := ii.HumanId()
+	var r *debugReplacement
+	if id.RequiresNew() {
+		r = &debugReplacement{
+			Address:    addr,
+			Tainted:    id.GetDestroyTainted(),
+			Attributes: []debugReplacementAttr{},
+		}
+		for k, ad := range id.CopyAttributes() {
+			if ad == nil || !ad.RequiresNew {
+				continue
+			}
+
+			attr := debugReplacementAttr{
+				Name:      k,
+				Old:       ad.Old,
+				New:       ad.New,
+				Sensitive: ad.Sensitive,
+			}
+			if ad.NewComputed {
+				attr.New = "<computed>"
+			}
+			if ad.Sensitive {
+				attr.Old, attr.New = "<sensitive>", "<sensitive>"
+			}
+			r.Attributes = append(r.Attributes, attr)
+		}
+		sort.Slice(r.Attributes, func(i, j int) bool {
+			return r.Attributes[i].Name < r.Attributes[j].Name
+		})
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if r == nil || !d.diffExists[addr] {
+		delete(d.replacements, addr)
+		return
+	}
+	if d.replacements == nil {
+		d.replacements = make(map[string]*debugReplacement)
+	}
+	d.replacements[addr] = r
+}
+
+// writeReplacements writes replacements.json, sorted by address, if any
+// instances were replaced. The lock must be held.
+func (d *debugInfo) writeReplacements() error {
+	if len(d.replacements) == 0 {
+		return nil
+	}
+
+	result := make([]*debugReplacement, 0, len(d.replacements))
+	for _, r := range d.replacements {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("replacements.json", js)
+}
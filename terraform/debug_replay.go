@@ -0,0 +1,234 @@
+package terraform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DebugHookEvent is a hook event recorded in a debug archive.
+type DebugHookEvent struct {
+	// Name is the name of the Hook method, i.e. "PostApply".
+	Name string
+
+	// Address is the human readable address of the instance.
+	Address string
+
+	// Step and Phase are the step counter and operation phase when the
+	// event was recorded.
+	Step  int
+	Phase string
+}
+
+// ReplayHooks calls the methods of h for each hook event recorded in the
+// archive, in the order the events were recorded, simulating the hook
+// sequence of the original run. If filter isn't nil, only the events it
+// returns true for are replayed. Replaying stops when h returns
+// HookActionHalt or an error.
+//
+// This is intended for testing tools that consume hook events, using stored
+// archives as fixtures, and isn't used by Terraform itself. The arguments are
+// reconstructed from what the archive records, so they're incomplete:
+// instance states only have their ID, attributes and tainted flag,
+// PostProvision is always called with a nil error, and PostStateUpdate is
+// never called since its state isn't recorded. Archives written with the
+// summary debug level have no hook events to replay.
+func (r *DebugArchiveReader) ReplayHooks(h Hook, filter func(*DebugHookEvent) bool) error {
+	for _, f := range r.Files {
+		name := strings.TrimPrefix(f.Name, "datasources/")
+		if !strings.HasPrefix(name, "hook-") {
+			continue
+		}
+
+		addr, body := string(f.Data), ""
+		if idx := strings.Index(addr, "\n"); idx >= 0 {
+			addr, body = addr[:idx], addr[idx+1:]
+		}
+
+		e := &DebugHookEvent{
+			Name:    strings.TrimPrefix(name, "hook-"),
+			Address: addr,
+			Step:    f.Step,
+			Phase:   f.Phase,
+		}
+		if filter != nil && !filter(e) {
+			continue
+		}
+
+		action, err := replayDebugHook(h, e, body)
+		if err != nil {
+			return fmt.Errorf("error replaying %s of %s: %s", e.Name, e.Address, err)
+		}
+		if action == HookActionHalt {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// replayDebugHook calls the method of h for a single event, with the
+// arguments parsed from the recorded body.
+func replayDebugHook(h Hook, e *DebugHookEvent, body string) (HookAction, error) {
+	ii := debugReplayInfo(e.Address)
+
+	switch e.Name {
+	case "PreApply":
+		is, rest := debugReplayState(body)
+		id, err := debugReplayDiff(rest)
+		if err != nil {
+			return HookActionHalt, err
+		}
+		return h.PreApply(ii, is, id)
+	case "PostApply":
+		is, rest := debugReplayState(body)
+		var err error
+		if rest != "" {
+			err = errors.New(rest)
+		}
+		return h.PostApply(ii, is, err)
+	case "PreDiff":
+		is, _ := debugReplayState(body)
+		return h.PreDiff(ii, is)
+	case "PostDiff":
+		id, err := debugReplayDiff(body)
+		if err != nil {
+			return HookActionHalt, err
+		}
+		return h.PostDiff(ii, id)
+	case "PreProvisionResource":
+		is, _ := debugReplayState(body)
+		return h.PreProvisionResource(ii, is)
+	case "PostProvisionResource":
+		is, _ := debugReplayState(body)
+		return h.PostProvisionResource(ii, is)
+	case "PreProvision":
+		return h.PreProvision(ii, strings.TrimSuffix(body, "\n"))
+	case "PostProvision":
+		return h.PostProvision(ii, strings.TrimSuffix(body, "\n"), nil)
+	case "ProvisionOutput":
+		id, output := body, ""
+		if idx := strings.Index(body, "\n"); idx >= 0 {
+			id, output = body[:idx], body[idx+1:]
+		}
+		h.ProvisionOutput(ii, id, strings.TrimSuffix(output, "\n"))
+		return HookActionContinue, nil
+	case "PreRefresh":
+		is, _ := debugReplayState(body)
+		return h.PreRefresh(ii, is)
+	case "PostRefresh":
+		is, _ := debugReplayState(body)
+		return h.PostRefresh(ii, is)
+	case "PreImportState":
+		return h.PreImportState(ii, strings.TrimSuffix(body, "\n"))
+	case "PostImportState":
+		var iss []*InstanceState
+		for {
+			is, rest := debugReplayState(body)
+			if is == nil {
+				break
+			}
+			iss = append(iss, is)
+			body = rest
+		}
+		return h.PostImportState(ii, iss)
+	}
+
+	// events from newer versions of Terraform are skipped
+	return HookActionContinue, nil
+}
+
+// debugReplayInfo returns the InstanceInfo for a human readable instance
+// address, i.e. "module.child.aws_instance.foo.0". The module path can't be
+// told apart from the resource address in general, so the resource address
+// is taken to be the last two parts, plus the index and data source prefix.
+func debugReplayInfo(addr string) *InstanceInfo {
+	parts := strings.Split(addr, ".")
+	i := len(parts) - 2
+	if _, err := strconv.Atoi(parts[len(parts)-1]); err == nil && len(parts) > 2 {
+		i--
+	}
+	if i > 0 && parts[i-1] == "data" {
+		i--
+	}
+	if i < 0 {
+		return &InstanceInfo{Id: addr, ModulePath: []string{"root"}}
+	}
+
+	path := []string{"root"}
+	if i > 1 && parts[0] == "module" {
+		path = append([]string{"root"}, parts[1:i]...)
+	} else {
+		i = 0
+	}
+
+	typ := parts[i]
+	if typ == "data" && i+1 < len(parts) {
+		typ = parts[i+1]
+	}
+
+	return &InstanceInfo{
+		Id:         strings.Join(parts[i:], "."),
+		ModulePath: path,
+		Type:       typ,
+	}
+}
+
+// debugReplayState parses an instance state written with
+// InstanceState.String from the start of text, returning it along with the
+// rest of the text. The state is nil if text doesn't start with one.
+func debugReplayState(text string) (*InstanceState, string) {
+	if strings.HasPrefix(text, "<not created>\n") {
+		return &InstanceState{}, debugReplayRest(text, len("<not created>\n"))
+	}
+	if !strings.HasPrefix(text, "ID = ") {
+		return nil, text
+	}
+
+	is := &InstanceState{Attributes: make(map[string]string)}
+	key := ""
+	for offset := 0; offset < len(text); {
+		line := text[offset:]
+		next := len(text)
+		if idx := strings.Index(line, "\n"); idx >= 0 {
+			line = line[:idx]
+			next = offset + idx + 1
+		}
+
+		switch {
+		case strings.HasPrefix(line, "Tainted = "):
+			is.Tainted = line == "Tainted = true"
+			return is, debugReplayRest(text, next)
+		case strings.HasPrefix(line, "ID = ") && is.ID == "":
+			is.ID = strings.TrimPrefix(line, "ID = ")
+			is.Attributes["id"] = is.ID
+		case strings.Contains(line, " = "):
+			idx := strings.Index(line, " = ")
+			key = line[:idx]
+			is.Attributes[key] = line[idx+3:]
+		case key != "":
+			// the continuation of a multi-line value
+			is.Attributes[key] += "\n" + line
+		}
+		offset = next
+	}
+
+	return is, ""
+}
+
+// debugReplayRest returns the text following a state ending at offset,
+// without the newline the hooks write after each state.
+func debugReplayRest(text string, offset int) string {
+	return strings.TrimPrefix(text[offset:], "\n")
+}
+
+// debugReplayDiff parses the JSON encoded instance diff in text.
+func debugReplayDiff(text string) (*InstanceDiff, error) {
+	var id *InstanceDiff
+	if err := json.Unmarshal([]byte(text), &id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
@@ -0,0 +1,168 @@
+package terraform
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// debugRPCRedacted replaces the values of sensitive attributes in the
+// captured provider calls.
+const debugRPCRedacted = "<redacted>"
+
+// debugApplyRPC is the content of rpc/ADDR-apply.json: the arguments of a
+// provider's Apply and its result, as sent over the plugin RPC connection,
+// with sensitive values redacted.
+type debugApplyRPC struct {
+	Resource string
+	Phase    string
+	Duration time.Duration
+
+	Request  debugApplyRPCRequest
+	Response debugApplyRPCResponse
+
+	// Redacted are the attributes whose values were redacted
+	Redacted []string `json:",omitempty"`
+
+	// schema is the schema of the resource type, if the provider described
+	// it, which marks the attributes that are sensitive whether or not
+	// they change
+	schema map[string]*DebugAttributeSchema
+	start  time.Time
+}
+
+type debugApplyRPCRequest struct {
+	Info  *InstanceInfo
+	State *InstanceState
+	Diff  *InstanceDiff
+}
+
+type debugApplyRPCResponse struct {
+	State *InstanceState
+	Error string `json:",omitempty"`
+}
+
+// newApplyRPC captures the arguments of a provider's Apply, if DebugConfig.RPC
+// is set, to be written with the result by writeApplyRPC. The arguments are
+// copied, so the provider can't change what's captured. Nil is returned if
+// calls aren't captured.
+func (d *debugInfo) newApplyRPC(ii *InstanceInfo, is *InstanceState, id *InstanceDiff) *debugApplyRPC {
+	if d == nil || ii == nil {
+		return nil
+	}
+
+	d.Lock()
+	enabled := d.rpc && !d.closed
+	phase := d.phase
+	schema := d.resourceSchema(ii.Type)
+	d.Unlock()
+	if !enabled {
+		return nil
+	}
+
+	diff, err := id.Copy()
+	if err != nil {
+		log.Printf("[WARN] not capturing the apply of %s: %s", ii.HumanId(), err)
+		return nil
+	}
+
+	return &debugApplyRPC{
+		Resource: ii.HumanId(),
+		Phase:    phase,
+		Request: debugApplyRPCRequest{
+			Info:  ii,
+			State: is.DeepCopy(),
+			Diff:  diff,
+		},
+		schema: schema,
+		start:  time.Now(),
+	}
+}
+
+// writeApplyRPC writes the captured call with the result of the Apply to
+// rpc/ADDR-apply.json, with the values of sensitive attributes redacted.
+func (d *debugInfo) writeApplyRPC(call *debugApplyRPC, is *InstanceState, err error) error {
+	if d == nil || call == nil {
+		return nil
+	}
+
+	// sorted archives must be reproducible, so the duration is left out
+	if !d.sorted {
+		call.Duration = time.Since(call.start)
+	}
+	if is != nil {
+		call.Response.State = is.DeepCopy()
+	}
+	if err != nil {
+		call.Response.Error = err.Error()
+	}
+	call.redact()
+
+	js, err := json.MarshalIndent(call, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.WriteResourceFile("rpc/"+call.Resource+"-apply.json", js)
+}
+
+// redact replaces the values of the attributes the diff or the schema marks
+// sensitive, and of any attributes named like secrets, along with the
+// attributes nested within them. Only the attributes that change are marked
+// in a diff, so the schema, if the provider described it, also covers those
+// that don't.
+func (c *debugApplyRPC) redact() {
+	sensitive := make(map[string]bool)
+	if c.Request.Diff != nil {
+		for k, ad := range c.Request.Diff.Attributes {
+			if ad != nil && ad.Sensitive {
+				sensitive[k] = true
+			}
+		}
+	}
+
+	isSensitive := func(k string) bool {
+		for s := range sensitive {
+			if k == s || strings.HasPrefix(k, s+".") {
+				return true
+			}
+		}
+		return debugSchemaSensitive(c.schema, k) || debugSecretAttr(k) != ""
+	}
+
+	redacted := make(map[string]bool)
+	for _, is := range []*InstanceState{c.Request.State, c.Response.State} {
+		if is == nil {
+			continue
+		}
+		for k := range is.Attributes {
+			if isSensitive(k) {
+				is.Attributes[k] = debugRPCRedacted
+				redacted[k] = true
+			}
+		}
+	}
+	if c.Request.Diff != nil {
+		for k, ad := range c.Request.Diff.Attributes {
+			if ad == nil || !isSensitive(k) {
+				continue
+			}
+			if ad.Old != "" {
+				ad.Old = debugRPCRedacted
+			}
+			if ad.New != "" {
+				ad.New = debugRPCRedacted
+			}
+			ad.NewExtra = nil
+			redacted[k] = true
+		}
+	}
+
+	c.Redacted = nil
+	for k := range redacted {
+		c.Redacted = append(c.Redacted, k)
+	}
+	sort.Strings(c.Redacted)
+}
@@ -0,0 +1,153 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// debugSchemaVersions is the content of schema-versions.json. Providers
+// built with helper/schema record the schema version of each resource in
+// the instance state meta, and migrate older states when they're refreshed
+// or applied. Comparing the version before and after each operation shows
+// which states were upgraded during the run.
+type debugSchemaVersions struct {
+	// Mismatches are the instances whose schema version changed
+	Mismatches []debugSchemaMismatch
+
+	// Matches maps each resource type with no changes to the schema
+	// versions seen for it.
+	Matches map[string][]string
+}
+
+type debugSchemaMismatch struct {
+	Instance string
+	Type     string
+
+	// State is the schema version in the state before the operation, and
+	// Provider is the version the provider recorded afterwards.
+	State    string
+	Provider string
+}
+
+// debugSchemaVersion tracks the schema versions seen for one instance, and
+// the number of attributes it had before the first operation.
+type debugSchemaVersion struct {
+	typ    string
+	before string
+	after  string
+	attrs  int
+
+	// upgraded is set once the upgrade of the instance is written to the
+	// upgrades directory
+	upgraded bool
+}
+
+// instanceSchemaVersion returns the schema version recorded in the instance
+// state, following the same rules as helper/schema.
+func instanceSchemaVersion(is *InstanceState) string {
+	if v, ok := is.Meta["schema_version"].(string); ok {
+		return v
+	}
+	return "0"
+}
+
+// recordSchemaBefore records the schema version of an instance before an
+// operation that may upgrade it. Only the first version seen is kept, since
+// that's the version read from the state.
+func (d *debugInfo) recordSchemaBefore(ii *InstanceInfo, is *InstanceState) {
+	if d == nil || ii == nil || is == nil || is.ID == "" {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.schemaVersions == nil {
+		d.schemaVersions = make(map[string]*debugSchemaVersion)
+	}
+
+	id := ii.HumanId()
+	if _, ok := d.schemaVersions[id]; ok {
+		return
+	}
+
+	v := instanceSchemaVersion(is)
+	d.schemaVersions[id] = &debugSchemaVersion{
+		typ:    ii.Type,
+		before: v,
+		after:  v,
+		attrs:  len(is.Attributes),
+	}
+}
+
+// recordSchemaAfter records the schema version of an instance after the
+// provider has refreshed or applied it, and writes its upgrade to the
+// upgrades directory if the provider migrated it.
+func (d *debugInfo) recordSchemaAfter(ii *InstanceInfo, is *InstanceState) {
+	if d == nil || ii == nil || is == nil || is.ID == "" {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	v, ok := d.schemaVersions[ii.HumanId()]
+	if !ok {
+		// a new instance has nothing to be upgraded from
+		return
+	}
+	v.after = instanceSchemaVersion(is)
+	d.writeProviderUpgrade(ii.HumanId(), v, len(is.Attributes))
+}
+
+// writeSchemaVersions writes schema-versions.json, if any instances were
+// recorded. The lock must be held.
+func (d *debugInfo) writeSchemaVersions() error {
+	if len(d.schemaVersions) == 0 {
+		return nil
+	}
+
+	result := debugSchemaVersions{
+		Matches: make(map[string][]string),
+	}
+
+	mismatched := make(map[string]bool)
+	for id, v := range d.schemaVersions {
+		if v.before != v.after {
+			mismatched[v.typ] = true
+			result.Mismatches = append(result.Mismatches, debugSchemaMismatch{
+				Instance: id,
+				Type:     v.typ,
+				State:    v.before,
+				Provider: v.after,
+			})
+		}
+	}
+
+	for _, v := range d.schemaVersions {
+		if mismatched[v.typ] {
+			continue
+		}
+
+		found := false
+		for _, existing := range result.Matches[v.typ] {
+			if existing == v.after {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Matches[v.typ] = append(result.Matches[v.typ], v.after)
+			sort.Strings(result.Matches[v.typ])
+		}
+	}
+
+	sort.Slice(result.Mismatches, func(i, j int) bool {
+		return result.Mismatches[i].Instance < result.Mismatches[j].Instance
+	})
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("schema-versions.json", js)
+}
@@ -0,0 +1,315 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/config/module"
+)
+
+// debugSensitivity is the content of sensitivity.json: the graph of how
+// sensitive values flow through the configuration. The sources are the
+// resource attributes the provider's schema marks sensitive, or that the
+// provider marked sensitive in the plan, and the outputs marked sensitive in
+// the configuration. Only addresses are
+// recorded, never values.
+type debugSensitivity struct {
+	Nodes []debugSensitivityNode
+	Edges []debugSensitivityEdge
+
+	// Leaks are the outputs that sensitive values flow into which aren't
+	// themselves marked sensitive, so are shown in plain text.
+	Leaks []string
+}
+
+type debugSensitivityNode struct {
+	// Address is the address of the attribute, module variable, output or
+	// resource, i.e. "module.db.aws_db_instance.main.password",
+	// "module.db.var.password" or "module.db.output.dsn".
+	Address string
+
+	// Kind is "attribute", "variable", "output" or "resource".
+	Kind string
+
+	// Sensitive is true for the sources of sensitive values.
+	Sensitive bool
+}
+
+// debugSensitivityEdge is a reference in the configuration of To to the
+// sensitive value of From.
+type debugSensitivityEdge struct {
+	From string
+	To   string
+}
+
+// debugSensitivityGraph accumulates the sensitivity graph.
+type debugSensitivityGraph struct {
+	// attrs are the sensitive attributes of each resource, by resource
+	// address
+	attrs map[string][]string
+
+	nodes map[string]debugSensitivityNode
+	edges map[debugSensitivityEdge]bool
+}
+
+// writeSensitivity writes sensitivity.json for the plan of the given
+// configuration, if anything in it is sensitive.
+func (d *debugInfo) writeSensitivity(mod *module.Tree, diff *Diff) error {
+	if d == nil || mod == nil {
+		return nil
+	}
+
+	d.Lock()
+	attrs := debugSensitiveAttrs(mod, diff, d.providerSchemas)
+	d.Unlock()
+
+	g := &debugSensitivityGraph{
+		attrs: attrs,
+		nodes: make(map[string]debugSensitivityNode),
+		edges: make(map[debugSensitivityEdge]bool),
+	}
+	for res, attrs := range g.attrs {
+		for _, attr := range attrs {
+			g.addNode(res+"."+attr, "attribute", true)
+		}
+	}
+	g.walk(mod)
+
+	if len(g.nodes) == 0 {
+		return nil
+	}
+
+	result := debugSensitivity{
+		Nodes: []debugSensitivityNode{},
+		Edges: []debugSensitivityEdge{},
+		Leaks: []string{},
+	}
+	for _, n := range g.nodes {
+		result.Nodes = append(result.Nodes, n)
+		if n.Kind == "output" && !n.Sensitive {
+			result.Leaks = append(result.Leaks, n.Address)
+		}
+	}
+	for e := range g.edges {
+		result.Edges = append(result.Edges, e)
+	}
+	sort.Slice(result.Nodes, func(i, j int) bool {
+		return result.Nodes[i].Address < result.Nodes[j].Address
+	})
+	sort.Slice(result.Edges, func(i, j int) bool {
+		if result.Edges[i].From != result.Edges[j].From {
+			return result.Edges[i].From < result.Edges[j].From
+		}
+		return result.Edges[i].To < result.Edges[j].To
+	})
+	sort.Strings(result.Leaks)
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.WriteFile("sensitivity.json", js)
+}
+
+// debugSensitiveAttrs returns the names of the attributes of the resources
+// in the configuration that the schemas of their providers mark sensitive,
+// and of those marked sensitive in diff, by resource address. The schemas
+// cover attributes that don't change, so the flows of sensitive values are
+// still recorded when nothing is planned, while the diff covers providers
+// that can't describe their schema.
+func debugSensitiveAttrs(mod *module.Tree, diff *Diff, schemas map[string]*DebugProviderSchema) map[string][]string {
+	sensitive := make(map[string]map[string]bool)
+	add := func(res, attr string) {
+		if sensitive[res] == nil {
+			sensitive[res] = make(map[string]bool)
+		}
+		sensitive[res][attr] = true
+	}
+
+	debugSchemaSensitiveAttrs(mod, schemas, add)
+
+	if diff != nil {
+		for _, m := range diff.Modules {
+			if len(m.Path) == 0 {
+				continue
+			}
+			prefix := debugModulePrefix(m.Path[1:])
+			for k, id := range m.Resources {
+				key, err := ParseResourceStateKey(k)
+				if err != nil || id == nil {
+					continue
+				}
+				res := prefix + key.Type + "." + key.Name
+				if key.Mode == config.DataResourceMode {
+					res = prefix + "data." + key.Type + "." + key.Name
+				}
+
+				for attr, ad := range id.Attributes {
+					if ad != nil && ad.Sensitive {
+						add(res, attr)
+					}
+				}
+			}
+		}
+	}
+
+	result := make(map[string][]string)
+	for res, attrs := range sensitive {
+		for attr := range attrs {
+			result[res] = append(result[res], attr)
+		}
+		sort.Strings(result[res])
+	}
+	return result
+}
+
+// debugSchemaSensitiveAttrs calls add with the address and the name of each
+// top-level attribute the provider schemas mark sensitive, of the resources
+// of t and its descendents.
+func debugSchemaSensitiveAttrs(t *module.Tree, schemas map[string]*DebugProviderSchema, add func(res, attr string)) {
+	if t == nil {
+		return
+	}
+
+	if c := t.Config(); c != nil {
+		prefix := debugModulePrefix(t.Path())
+		for _, r := range c.Resources {
+			schema := schemas[resourceProvider(r.Type, "")]
+			if schema == nil {
+				continue
+			}
+			attrs := schema.ResourceTypes[r.Type]
+			if r.Mode == config.DataResourceMode {
+				attrs = schema.DataSources[r.Type]
+			}
+
+			for name, s := range attrs {
+				if s != nil && s.Sensitive {
+					add(prefix+r.Id(), name)
+				}
+			}
+		}
+	}
+
+	for _, child := range t.Children() {
+		debugSchemaSensitiveAttrs(child, schemas, add)
+	}
+}
+
+// debugModulePrefix returns the address prefix of the resources and outputs
+// of the module at path, i.e. "module.a.module.b.".
+func debugModulePrefix(path []string) string {
+	var prefix string
+	for _, name := range path {
+		prefix += "module." + name + "."
+	}
+	return prefix
+}
+
+// walk adds the flows of sensitive values within t and its children. The
+// inputs of the child modules are added before the children are walked, and
+// the children before the outputs and resources of t, so that the
+// sensitivity of everything referenced is known when it's referenced.
+func (g *debugSensitivityGraph) walk(t *module.Tree) {
+	c := t.Config()
+	prefix := debugModulePrefix(t.Path())
+
+	if c != nil {
+		for _, m := range c.Modules {
+			for k, v := range m.RawConfig.Raw {
+				raw, err := config.NewRawConfig(map[string]interface{}{k: v})
+				if err != nil {
+					continue
+				}
+				addr := prefix + "module." + m.Name + ".var." + k
+				g.addFlows(addr, "variable", false, g.sources(prefix, raw))
+			}
+		}
+	}
+
+	children := t.Children()
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.walk(children[name])
+	}
+
+	if c == nil {
+		return
+	}
+
+	for _, o := range c.Outputs {
+		addr := prefix + "output." + o.Name
+		g.addFlows(addr, "output", o.Sensitive, g.sources(prefix, o.RawConfig))
+	}
+	for _, r := range c.Resources {
+		addr := prefix + r.Id()
+		g.addFlows(addr, "resource", false, g.sources(prefix, r.RawConfig))
+	}
+}
+
+// addFlows adds a node with an edge from each of its sensitive sources, if
+// it's sensitive itself or has any.
+func (g *debugSensitivityGraph) addFlows(addr, kind string, sensitive bool, sources []string) {
+	if !sensitive && len(sources) == 0 {
+		return
+	}
+
+	g.addNode(addr, kind, sensitive)
+	for _, src := range sources {
+		g.edges[debugSensitivityEdge{From: src, To: addr}] = true
+	}
+}
+
+// sources returns the addresses of the sensitive values referenced by raw,
+// in the module with the given prefix.
+func (g *debugSensitivityGraph) sources(prefix string, raw *config.RawConfig) []string {
+	if raw == nil {
+		return nil
+	}
+
+	var result []string
+	for _, v := range raw.Variables {
+		switch v := v.(type) {
+		case *config.ResourceVariable:
+			res := prefix + v.ResourceId()
+			for _, attr := range g.attrs[res] {
+				if attr == v.Field || strings.HasPrefix(attr, v.Field+".") {
+					result = append(result, res+"."+attr)
+				}
+			}
+		case *config.ModuleVariable:
+			addr := prefix + "module." + v.Name + ".output." + v.Field
+			if _, ok := g.nodes[addr]; ok {
+				result = append(result, addr)
+			}
+		case *config.UserVariable:
+			addr := prefix + "var." + v.Name
+			if _, ok := g.nodes[addr]; ok {
+				result = append(result, addr)
+			}
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// addNode adds a node to the graph. A node that's already sensitive stays
+// sensitive.
+func (g *debugSensitivityGraph) addNode(addr, kind string, sensitive bool) {
+	if n, ok := g.nodes[addr]; ok && n.Sensitive {
+		return
+	}
+	g.nodes[addr] = debugSensitivityNode{
+		Address:   addr,
+		Kind:      kind,
+		Sensitive: sensitive,
+	}
+}
@@ -0,0 +1,74 @@
+package terraform
+
+import (
+	"encoding/json"
+)
+
+// debugSerialIncrement is a change of the state's serial during the run, as
+// recorded in serial-history.json. Each increment is a checkpoint the state
+// was persisted at, from which a partial apply can be recovered.
+type debugSerialIncrement struct {
+	Phase string
+	Old   int64
+	New   int64
+}
+
+// debugSerialHistory is the content of serial-history.json.
+type debugSerialHistory struct {
+	// Checkpoints is the number of increments, and Initial and Final the
+	// serial before and after them.
+	Checkpoints int
+	Initial     int64
+	Final       int64
+
+	Increments []*debugSerialIncrement
+}
+
+// recordSerial records the serial of s, and the change from the serial it
+// last recorded, if it changed. The first call records the serial the run
+// starts from.
+//
+// The serial is incremented when the state is persisted, which happens after
+// the state update hooks, so an increment is seen by the next update, or
+// once the walk is done, in the phase it's seen in.
+func (d *debugInfo) recordSerial(s *State) {
+	if d == nil || s == nil {
+		return
+	}
+	s.Lock()
+	serial := s.Serial
+	s.Unlock()
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.serial == nil {
+		d.serial = &debugSerialHistory{Initial: serial, Final: serial}
+		return
+	}
+	if serial == d.serial.Final {
+		return
+	}
+	d.serial.Increments = append(d.serial.Increments, &debugSerialIncrement{
+		Phase: d.phase,
+		Old:   d.serial.Final,
+		New:   serial,
+	})
+	d.serial.Final = serial
+	d.serial.Checkpoints++
+}
+
+// writeSerialHistory writes serial-history.json, if the serial changed
+// during the run. The lock must be held.
+func (d *debugInfo) writeSerialHistory() error {
+	if d.serial == nil || d.serial.Checkpoints == 0 {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(d.serial, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("serial-history.json", js)
+}
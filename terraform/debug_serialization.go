@@ -0,0 +1,92 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// debugSerialization is an entry in serialization.json: a resource whose
+// apply couldn't start as soon as it was scheduled, because a limit on how
+// many operations may run at once was reached.
+//
+// Only the limits enforced by Terraform itself are seen. Providers that
+// serialize their own API calls, such as with a mutex per network, do so in
+// the plugin process, so their resources aren't recorded.
+type debugSerialization struct {
+	Resource string
+	Vertex   string
+	Phase    string
+
+	// Reason describes the limit the apply was gated by, and Wait is how
+	// long it waited for it.
+	Reason string
+	Wait   time.Duration
+}
+
+// recordSerialized records that the apply of the resource vertex v had to
+// wait on the walk's parallelism semaphore, whose limit is limit. Only the
+// apply and destroy walks are recorded, once for each vertex.
+func (d *debugInfo) recordSerialized(op walkOperation, v dag.Vertex, limit int, wait time.Duration) {
+	if d == nil || (op != walkApply && op != walkDestroy) {
+		return
+	}
+	rn, ok := v.(GraphNodeResource)
+	if !ok || rn.ResourceAddr() == nil {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	name := dag.VertexName(v)
+	for _, s := range d.serializations {
+		if s.Vertex == name && s.Phase == d.phase {
+			return
+		}
+	}
+	d.serializations = append(d.serializations, &debugSerialization{
+		Resource: debugResourceAddr(rn.ResourceAddr()),
+		Vertex:   name,
+		Phase:    d.phase,
+		Reason: fmt.Sprintf(
+			"all %d parallel operations allowed by -parallelism were in progress", limit),
+		Wait: wait,
+	})
+}
+
+// writeSerialization writes serialization.json, if the apply of any
+// resources was gated, in the order they were. Sorted archives leave the
+// waits out, and list the resources by phase and vertex instead. The lock
+// must be held.
+func (d *debugInfo) writeSerialization() error {
+	if len(d.serializations) == 0 {
+		return nil
+	}
+
+	result := d.serializations
+	if d.sorted {
+		result = make([]*debugSerialization, len(d.serializations))
+		for i, s := range d.serializations {
+			ss := *s
+			ss.Wait = 0
+			result[i] = &ss
+		}
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].Phase != result[j].Phase {
+				return result[i].Phase < result[j].Phase
+			}
+			return result[i].Vertex < result[j].Vertex
+		})
+	}
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("serialization.json", js)
+}
@@ -0,0 +1,148 @@
+package terraform
+
+import (
+	"bytes"
+	"sort"
+	"time"
+)
+
+// debugSortedEntry is an entry held until the archive is closed, when the
+// archive is sorted, along with the phase it was written in. The step is the
+// step it was written at until renumberSorted assigns its final step.
+type debugSortedEntry struct {
+	name  string
+	phase string
+	step  int
+	data  []byte
+}
+
+// entryTime returns the modification time of a new entry: the current time,
+// or the Unix epoch if the archive is sorted, so identical runs produce
+// identical archives. Readers treat the epoch as no time recorded.
+func (d *debugInfo) entryTime() time.Time {
+	if d.sorted {
+		return time.Unix(0, 0)
+	}
+	return time.Now()
+}
+
+// debugSortedArchiveName returns the root directory name of a sorted
+// archive, which is named for the Unix epoch, like the modification times of
+// its entries. The archive file is still named for the current time, so
+// sorted archives written to the same directory don't collide.
+func debugSortedArchiveName(correlationID string) string {
+	return debugArchiveNameAt(time.Unix(0, 0).UTC(), correlationID)
+}
+
+// sortEntry holds the file name, to be written by flushSorted. The step it's
+// written at is advanced as if it had been written, so anything recording
+// steps still counts it. The lock must be held.
+func (d *debugInfo) sortEntry(name string, data []byte) {
+	if d.sortedPhases == nil {
+		d.sortedPhases = make(map[string]int)
+	}
+	if _, ok := d.sortedPhases[d.phase]; !ok {
+		d.sortedPhases[d.phase] = len(d.sortedPhases)
+	}
+
+	d.sortedEntries = append(d.sortedEntries, &debugSortedEntry{
+		name:  name,
+		phase: d.phase,
+		step:  d.step,
+		data:  append([]byte(nil), data...),
+	})
+	d.recordEntryWave(name)
+	d.step++
+	d.recordEstimate(data)
+}
+
+// sortEntries sorts entries by name, then by the order their phases began
+// in, which is the same for identical runs, then by content, so the order
+// doesn't depend on the order concurrent hooks were written in.
+func (d *debugInfo) sortEntries(entries []*debugSortedEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if pa, pb := d.sortedPhases[a.phase], d.sortedPhases[b.phase]; pa != pb {
+			return pa < pb
+		}
+		return bytes.Compare(a.data, b.data) < 0
+	})
+}
+
+// renumberSorted sorts the entries held so far, and reassigns the steps they
+// were written at in sorted order, so the steps are the same for identical
+// runs, whatever order concurrent hooks fired in. The steps recorded in the
+// summaries are remapped to match. This is called on Close, before the
+// summaries are written, which then take the steps that follow. The lock
+// must be held.
+func (d *debugInfo) renumberSorted() {
+	if !d.sorted || d.sortedFlushed || len(d.sortedEntries) == 0 {
+		return
+	}
+
+	steps := make([]int, len(d.sortedEntries))
+	for i, e := range d.sortedEntries {
+		steps[i] = e.step
+	}
+	sort.Ints(steps)
+
+	d.sortEntries(d.sortedEntries)
+	remap := make(map[int]int, len(steps))
+	for i, e := range d.sortedEntries {
+		remap[e.step] = steps[i]
+		e.step = steps[i]
+	}
+
+	for i := range d.waveEntries {
+		if s, ok := remap[d.waveEntries[i].Step]; ok {
+			d.waveEntries[i].Step = s
+		}
+	}
+	for i := range d.graphCounts {
+		if s, ok := remap[d.graphCounts[i].Step]; ok {
+			d.graphCounts[i].Step = s
+		}
+	}
+	for i := range d.memoryTransitions {
+		if s, ok := remap[d.memoryTransitions[i].Step]; ok {
+			d.memoryTransitions[i].Step = s
+		}
+	}
+}
+
+// flushSorted writes the entries held while the archive is sorted, in sorted
+// order with the steps assigned to them, counting them towards the parts as
+// if they had been written directly. Anything written afterwards, such as
+// the part index, is written directly. The lock must be held.
+func (d *debugInfo) flushSorted() error {
+	if !d.sorted || d.sortedFlushed {
+		return nil
+	}
+	entries := d.sortedEntries
+	d.sortedFlushed = true
+	d.sortedEntries = nil
+
+	d.sortEntries(entries)
+
+	for _, e := range entries {
+		if err := d.tar.WriteHeader(d.entryHeaderAt(e.name, e.phase, e.step, len(e.data))); err != nil {
+			return err
+		}
+		if _, err := d.tar.Write(e.data); err != nil {
+			return err
+		}
+		d.flush()
+		d.partCount++
+
+		if d.partEntries > 0 && d.nextPart != nil && d.partCount >= d.partEntries {
+			if err := d.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
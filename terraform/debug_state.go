@@ -0,0 +1,55 @@
+package terraform
+
+import (
+	"bytes"
+	"log"
+)
+
+// debugStateFile is the name of the file the snapshot of the state is
+// written to, if DebugConfig.State is set.
+const debugStateFile = "state.tfstate"
+
+// recordStateSnapshot records s as the state at the end of a walk, if
+// DebugConfig.State is set, to be written to state.tfstate when the archive
+// is closed. The state is serialized right away, since it may change later
+// in the run, and the last snapshot recorded is the one written.
+func (d *debugInfo) recordStateSnapshot(s *State) {
+	if d == nil || s == nil {
+		return
+	}
+	d.Lock()
+	enabled := d.captureState
+	d.Unlock()
+	if !enabled {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := WriteState(s, &buf); err != nil {
+		log.Printf("[WARN] not recording the state in the debug archive: %s", err)
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.stateSnapshot = buf.Bytes()
+}
+
+// writeStateSnapshot writes state.tfstate, if a snapshot of the state was
+// recorded. The lock must be held.
+func (d *debugInfo) writeStateSnapshot() error {
+	if d.stateSnapshot == nil {
+		return nil
+	}
+	return d.writeFile(debugStateFile, d.stateSnapshot)
+}
+
+// State returns the snapshot of the state at the end of the run recorded in
+// the archive, or nil if the archive was written without DebugConfig.State.
+func (r *DebugArchiveReader) State() (*State, error) {
+	f := r.File(debugStateFile)
+	if f == nil {
+		return nil, nil
+	}
+	return ReadState(bytes.NewReader(f.Data))
+}
@@ -0,0 +1,424 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+const (
+	// debugStreamBufferSize is the maximum amount of archive data held in
+	// memory while the collector is unreachable or slow. Data written beyond
+	// this is dropped.
+	debugStreamBufferSize = 16 << 20
+
+	// debugStreamTimeout bounds each connection attempt and each network
+	// write, so a stalled connection is abandoned and re-established.
+	debugStreamTimeout = 10 * time.Second
+
+	// debugStreamMaxBackoff is the longest wait between reconnect attempts.
+	debugStreamMaxBackoff = 5 * time.Second
+
+	// debugStreamDrainTimeout is how long Close waits for buffered data to be
+	// delivered before giving up on it.
+	debugStreamDrainTimeout = 10 * time.Second
+)
+
+// DebugStreamWriter is an io.WriteCloser that streams the debug archive to a
+// collector service as it is written, so the collector has the data even if
+// the machine running Terraform is destroyed.
+//
+// Write never blocks on the network. Data is queued in memory and sent from a
+// separate goroutine, which reconnects with a backoff if the connection
+// fails. If the queue fills up, further writes are dropped and counted until
+// there is room again. The counts are available from Stats, and are written
+// to stream.json in the archive when it's closed.
+type DebugStreamWriter struct {
+	dial func(offset int64, stats DebugStreamStats) (io.WriteCloser, error)
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    [][]byte
+	buffered int
+	closed   bool
+	stats    DebugStreamStats
+
+	// dropping is set while consecutive writes are being dropped, so that
+	// each gap is only counted once.
+	dropping bool
+
+	// written is the number of bytes accepted by Write, including any that
+	// were later dropped. The collector can use this offset to detect gaps
+	// when a connection is re-established.
+	written int64
+
+	done chan struct{}
+}
+
+// DebugStreamStats records how well the stream kept up with the archive.
+type DebugStreamStats struct {
+	// Address is the collector address the archive was streamed to, with
+	// any credentials and query parameters removed.
+	Address string
+
+	// Sent is the number of bytes delivered to the collector.
+	Sent int64
+
+	// Dropped is the number of bytes discarded because the buffer was full
+	// or the data could not be delivered before the stream was closed, and
+	// Drops is the number of separate gaps this caused in the stream.
+	Dropped int64
+	Drops   int
+
+	// Reconnects is the number of times the connection was re-established
+	// after a failure.
+	Reconnects int
+
+	// LastError is the most recent connection or write error, if any.
+	LastError string `json:",omitempty"`
+}
+
+// NewDebugStreamWriter returns a DebugStreamWriter for the collector at addr.
+// An http or https URL streams the archive as the body of a POST request. A
+// tcp URL, or a bare host:port, streams the archive over a plain TCP
+// connection. The connection is made in the background, so an unreachable
+// collector isn't reported here.
+func NewDebugStreamWriter(addr string) (*DebugStreamWriter, error) {
+	s := &DebugStreamWriter{
+		done: make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	s.stats.Address = debugRedactAddr(addr)
+
+	u, err := parseDebugStreamAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		s.dial = func(int64, DebugStreamStats) (io.WriteCloser, error) {
+			return dialDebugStreamTCP(u.Host)
+		}
+	default:
+		s.dial = func(offset int64, stats DebugStreamStats) (io.WriteCloser, error) {
+			return dialDebugStreamHTTP(u.String(), offset, stats)
+		}
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// parseDebugStreamAddr parses a collector address, which is either a
+// host:port for TCP, or an http or https URL.
+func parseDebugStreamAddr(addr string) (*url.URL, error) {
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		// not a URL, so it should be a host:port
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid debug stream address %q: %s", addr, err)
+		}
+		return &url.URL{Scheme: "tcp", Host: addr}, nil
+	}
+
+	switch u.Scheme {
+	case "tcp", "http", "https":
+		return u, nil
+	}
+
+	return nil, fmt.Errorf(
+		"invalid debug stream address %q: unsupported scheme %q", addr, u.Scheme)
+}
+
+// Write queues p to be sent to the collector. It never blocks on the network
+// and never returns an error, so a failing collector can't affect the run.
+func (s *DebugStreamWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.written += int64(len(p))
+
+	if s.closed || s.buffered+len(p) > debugStreamBufferSize {
+		s.drop(len(p))
+		return len(p), nil
+	}
+
+	// the caller may reuse p once we return
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	s.queue = append(s.queue, buf)
+	s.buffered += len(buf)
+	s.dropping = false
+	s.cond.Signal()
+
+	return len(p), nil
+}
+
+// drop records n bytes that will never reach the collector. Consecutive
+// drops are counted as a single gap. The lock must be held.
+func (s *DebugStreamWriter) drop(n int) {
+	if !s.dropping {
+		s.stats.Drops++
+	}
+	s.stats.Dropped += int64(n)
+	s.dropping = true
+}
+
+// dropQueue discards everything still queued. The lock must be held.
+func (s *DebugStreamWriter) dropQueue() {
+	if len(s.queue) > 0 {
+		s.dropping = false
+		s.drop(s.buffered)
+	}
+	s.queue = nil
+	s.buffered = 0
+}
+
+// Stats returns the current delivery statistics for the stream.
+func (s *DebugStreamWriter) Stats() DebugStreamStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Close waits a limited time for the queued data to be sent, then closes the
+// connection. Anything still queued after that is counted as dropped.
+func (s *DebugStreamWriter) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(debugStreamDrainTimeout):
+		s.mu.Lock()
+		s.dropQueue()
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// run sends the queued data to the collector until the stream is closed and
+// the queue is empty.
+func (s *DebugStreamWriter) run() {
+	defer close(s.done)
+
+	var conn io.WriteCloser
+	backoff := 100 * time.Millisecond
+	connected := false
+
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		buf := s.queue[0]
+		offset := s.written - int64(s.buffered)
+		stats := s.stats
+		closed := s.closed
+		s.mu.Unlock()
+
+		if conn == nil {
+			var err error
+			conn, err = s.dial(offset, stats)
+			if err != nil {
+				s.setError(err)
+				if closed {
+					// there's no point retrying while the drain times out
+					s.mu.Lock()
+					s.dropQueue()
+					s.mu.Unlock()
+					return
+				}
+				time.Sleep(backoff)
+				if backoff *= 2; backoff > debugStreamMaxBackoff {
+					backoff = debugStreamMaxBackoff
+				}
+				continue
+			}
+
+			if connected {
+				s.mu.Lock()
+				s.stats.Reconnects++
+				s.mu.Unlock()
+			}
+			connected = true
+			backoff = 100 * time.Millisecond
+		}
+
+		if _, err := conn.Write(buf); err != nil {
+			// retry the same data on a new connection
+			s.setError(err)
+			conn.Close()
+			conn = nil
+			time.Sleep(backoff)
+			continue
+		}
+
+		s.mu.Lock()
+		s.queue = s.queue[1:]
+		s.buffered -= len(buf)
+		s.stats.Sent += int64(len(buf))
+		s.mu.Unlock()
+	}
+}
+
+func (s *DebugStreamWriter) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LastError = err.Error()
+}
+
+// debugStreamConn is a TCP connection where each write must complete within
+// debugStreamTimeout.
+type debugStreamConn struct {
+	net.Conn
+}
+
+func dialDebugStreamTCP(addr string) (io.WriteCloser, error) {
+	conn, err := net.DialTimeout("tcp", addr, debugStreamTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &debugStreamConn{Conn: conn}, nil
+}
+
+func (c *debugStreamConn) Write(p []byte) (int, error) {
+	c.Conn.SetWriteDeadline(time.Now().Add(debugStreamTimeout))
+	return c.Conn.Write(p)
+}
+
+// debugStreamRequest streams data as the body of an HTTP request.
+type debugStreamRequest struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// dialDebugStreamHTTP starts a POST request whose body is the data written
+// to the returned writer. The byte offset of the first write within the
+// archive, and the stream statistics so far, are sent as headers so the
+// collector can detect a gap after a reconnect.
+func dialDebugStreamHTTP(u string, offset int64, stats DebugStreamStats) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest("POST", u, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("X-Terraform-Debug-Offset", strconv.FormatInt(offset, 10))
+	req.Header.Set("X-Terraform-Debug-Dropped", strconv.FormatInt(stats.Dropped, 10))
+
+	client := cleanhttp.DefaultClient()
+	r := &debugStreamRequest{
+		pw:   pw,
+		done: make(chan error, 1),
+	}
+
+	go func() {
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("debug stream collector returned %s", resp.Status)
+			}
+		}
+
+		// fail any pending or future writes
+		if err != nil {
+			pr.CloseWithError(err)
+		} else {
+			pr.Close()
+		}
+		r.done <- err
+	}()
+
+	return r, nil
+}
+
+func (r *debugStreamRequest) Write(p []byte) (int, error) {
+	// the pipe blocks until the transport reads the data, so bound how long
+	// a stalled collector can hold up the stream.
+	timer := time.AfterFunc(debugStreamTimeout, func() {
+		r.pw.CloseWithError(fmt.Errorf("timeout writing to debug stream collector"))
+	})
+	defer timer.Stop()
+
+	return r.pw.Write(p)
+}
+
+func (r *debugStreamRequest) Close() error {
+	r.pw.Close()
+
+	select {
+	case err := <-r.done:
+		return err
+	case <-time.After(debugStreamTimeout):
+		return fmt.Errorf("timeout waiting for debug stream collector")
+	}
+}
+
+// debugTeeWriter writes the archive to a local file, and a copy to a
+// DebugStreamWriter. Closing it only closes the file, since the stream
+// continues across archive parts.
+type debugTeeWriter struct {
+	io.Writer
+	stream *DebugStreamWriter
+}
+
+func (t *debugTeeWriter) Write(p []byte) (int, error) {
+	n, err := t.Writer.Write(p)
+	t.stream.Write(p[:n])
+	return n, err
+}
+
+func (t *debugTeeWriter) Sync() error {
+	if s, ok := t.Writer.(syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+func (t *debugTeeWriter) Close() error {
+	if c, ok := t.Writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// writeStreamStats writes the delivery statistics for the stream to
+// stream.json, so any gaps in the collector's copy are recorded. The lock
+// must be held.
+func (d *debugInfo) writeStreamStats() error {
+	if d.stream == nil {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(d.stream.Stats(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("stream.json", js)
+}
@@ -0,0 +1,57 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// debugTargets is the content of targets.json, recording the resources
+// planned when targeting: those targeted, and everything they depend on.
+type debugTargets struct {
+	// Targets are the targets as requested with -target.
+	Targets []string
+
+	// Closure are the addresses of the resources that were planned: the
+	// targeted resources and their dependencies, or the resources depending
+	// on them when destroying.
+	Closure []string
+}
+
+// writeTargets writes targets.json for the targets of a plan and the
+// vertices selected by them.
+func (d *debugInfo) writeTargets(targets []ResourceAddress, selected *dag.Set) error {
+	if d == nil || len(targets) == 0 {
+		return nil
+	}
+
+	result := &debugTargets{
+		Targets: make([]string, len(targets)),
+		Closure: []string{},
+	}
+	for i, t := range targets {
+		result.Targets[i] = t.String()
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range selected.List() {
+		r, ok := v.(GraphNodeResource)
+		if !ok {
+			continue
+		}
+		addr := r.ResourceAddr().String()
+		if !seen[addr] {
+			seen[addr] = true
+			result.Closure = append(result.Closure, addr)
+		}
+	}
+	sort.Strings(result.Closure)
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.WriteFile("targets.json", js)
+}
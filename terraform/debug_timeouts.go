@@ -0,0 +1,161 @@
+package terraform
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// debugTimeoutMetaKey is the key helper/schema stores the configured
+// timeouts of a resource under in the instance diff meta, as
+// schema.TimeoutKey. The values are durations in nanoseconds, keyed by
+// operation.
+const debugTimeoutMetaKey = "e2bfb730-ecaa-11e6-8f88-34363bc7c4c0"
+
+// debugErrorClasses classifies apply errors by matching their messages.
+// The first matching pattern wins.
+var debugErrorClasses = []struct {
+	class   string
+	pattern *regexp.Regexp
+}{
+	// helper/resource.StateChangeConf and WaitForState
+	{"timeout", regexp.MustCompile(`timeout while waiting for`)},
+	{"timeout", regexp.MustCompile(`context deadline exceeded`)},
+	{"timeout", regexp.MustCompile(`(?i)\btimed out\b`)},
+}
+
+// debugErrorClass returns the class of err, or "" if it's unclassified.
+func debugErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	msg := err.Error()
+	for _, c := range debugErrorClasses {
+		if c.pattern.MatchString(msg) {
+			return c.class
+		}
+	}
+	return ""
+}
+
+// DebugTimeout is an apply that failed with a timeout, as recorded in
+// timeouts.json of the debug archive.
+type DebugTimeout struct {
+	Resource string
+
+	// Operation is "create", "update" or "delete".
+	Operation string
+
+	// Timeout is the timeout configured for the operation, or zero if the
+	// provider didn't record one.
+	Timeout time.Duration
+
+	Error string
+}
+
+// debugApplyTimeout is the operation and configured timeout of an apply in
+// progress.
+type debugApplyTimeout struct {
+	operation string
+	timeout   time.Duration
+}
+
+// recordTimeoutStart records the operation and configured timeout of an
+// apply, so that they can be reported if it times out.
+func (d *debugInfo) recordTimeoutStart(ii *InstanceInfo, is *InstanceState, id *InstanceDiff) {
+	if d == nil || ii == nil || id == nil {
+		return
+	}
+
+	op := "update"
+	switch {
+	case id.GetDestroy() && !id.RequiresNew():
+		op = "delete"
+	case is == nil || is.ID == "" || id.RequiresNew():
+		op = "create"
+	}
+
+	var timeout time.Duration
+	if times, ok := id.Meta[debugTimeoutMetaKey].(map[string]interface{}); ok {
+		timeout = debugTimeoutDuration(times[op])
+		if timeout == 0 {
+			timeout = debugTimeoutDuration(times["default"])
+		}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.applyTimeouts == nil {
+		d.applyTimeouts = make(map[string]debugApplyTimeout)
+	}
+	d.applyTimeouts[ii.HumanId()] = debugApplyTimeout{
+		operation: op,
+		timeout:   timeout,
+	}
+}
+
+// recordTimeoutEnd records the apply of an instance as timed out, if err
+// is a timeout.
+func (d *debugInfo) recordTimeoutEnd(ii *InstanceInfo, err error) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	id := ii.HumanId()
+	start, ok := d.applyTimeouts[id]
+	if !ok {
+		return
+	}
+	delete(d.applyTimeouts, id)
+
+	if debugErrorClass(err) != "timeout" {
+		return
+	}
+
+	d.timeouts = append(d.timeouts, DebugTimeout{
+		Resource:  id,
+		Operation: start.operation,
+		Timeout:   start.timeout,
+		Error:     err.Error(),
+	})
+}
+
+// debugTimeoutDuration converts a timeout recorded in the diff meta, which
+// may have been through a JSON round trip, to a duration.
+func debugTimeoutDuration(v interface{}) time.Duration {
+	switch raw := v.(type) {
+	case time.Duration:
+		return raw
+	case int64:
+		return time.Duration(raw)
+	case float64:
+		return time.Duration(int64(raw))
+	}
+	return 0
+}
+
+// writeTimeouts writes timeouts.json, if any applies timed out. The lock
+// must be held.
+func (d *debugInfo) writeTimeouts() error {
+	if len(d.timeouts) == 0 {
+		return nil
+	}
+
+	timeouts := make([]DebugTimeout, len(d.timeouts))
+	copy(timeouts, d.timeouts)
+	sort.Slice(timeouts, func(i, j int) bool {
+		return timeouts[i].Resource < timeouts[j].Resource
+	})
+
+	js, err := json.MarshalIndent(timeouts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("timeouts.json", js)
+}
@@ -0,0 +1,218 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// DebugTiming is a single timed operation, as recorded in timings.json of
+// the debug archive.
+type DebugTiming struct {
+	// Operation is the hook operation that was timed, i.e. "apply", "diff",
+	// "refresh" or "provision", or "phase" for an entire operational phase.
+	Operation string
+
+	// Address is the human readable address of the instance, or the name of
+	// the phase for phase timings.
+	Address string
+
+	// Phase is the operational phase the operation was part of.
+	Phase string
+
+	Start    time.Time
+	Duration time.Duration
+}
+
+// debugTimingStart is an operation that has started but not finished.
+type debugTimingStart struct {
+	phase string
+	start time.Time
+}
+
+// recordTimingStart records the start of an operation on an instance.
+func (d *debugInfo) recordTimingStart(op string, ii *InstanceInfo) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.timingStarts == nil {
+		d.timingStarts = make(map[string]debugTimingStart)
+	}
+	d.timingStarts[op+" "+ii.HumanId()] = debugTimingStart{
+		phase: d.phase,
+		start: time.Now(),
+	}
+}
+
+// recordTimingEnd records the end of an operation on an instance, if its
+// start was recorded.
+func (d *debugInfo) recordTimingEnd(op string, ii *InstanceInfo) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	id := ii.HumanId()
+	s, ok := d.timingStarts[op+" "+id]
+	if !ok {
+		return
+	}
+	delete(d.timingStarts, op+" "+id)
+
+	d.timings = append(d.timings, DebugTiming{
+		Operation: op,
+		Address:   id,
+		Phase:     s.phase,
+		Start:     s.start,
+		Duration:  time.Since(s.start),
+	})
+}
+
+// recordPhaseEnd records the timing of the current phase, if there is one.
+// The lock must be held.
+func (d *debugInfo) recordPhaseEnd() {
+	if d.phase == "" || d.phaseStart.IsZero() {
+		return
+	}
+
+	d.timings = append(d.timings, DebugTiming{
+		Operation: "phase",
+		Address:   d.phase,
+		Phase:     d.phase,
+		Start:     d.phaseStart,
+		Duration:  time.Since(d.phaseStart),
+	})
+	d.phaseStart = time.Time{}
+}
+
+// writeTimings writes timings.json, if anything was timed. Sorted archives
+// leave the times out, and list the operations by phase, operation and
+// address instead. The lock must be held.
+func (d *debugInfo) writeTimings() error {
+	d.recordPhaseEnd()
+	if len(d.timings) == 0 {
+		return nil
+	}
+
+	timings := make([]DebugTiming, len(d.timings))
+	copy(timings, d.timings)
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Start.Before(timings[j].Start)
+	})
+	if d.sorted {
+		for i := range timings {
+			timings[i].Start, timings[i].Duration = time.Time{}, 0
+		}
+		sort.SliceStable(timings, func(i, j int) bool {
+			a, b := timings[i], timings[j]
+			if a.Phase != b.Phase {
+				return a.Phase < b.Phase
+			}
+			if a.Operation != b.Operation {
+				return a.Operation < b.Operation
+			}
+			return a.Address < b.Address
+		})
+	}
+
+	js, err := json.MarshalIndent(timings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("timings.json", js)
+}
+
+// Timings returns the operation timings recorded in the archive, or nil if
+// none were recorded.
+func (r *DebugArchiveReader) Timings() ([]DebugTiming, error) {
+	f := r.File("timings.json")
+	if f == nil {
+		return nil, nil
+	}
+
+	var timings []DebugTiming
+	if err := json.Unmarshal(f.Data, &timings); err != nil {
+		return nil, err
+	}
+
+	return timings, nil
+}
+
+// debugQueueing is an entry in queueing.json: the time between the diff of a
+// resource being computed and its apply starting. Large gaps show that the
+// apply was waiting for the walk to reach it, i.e. parallelism was saturated.
+type debugQueueing struct {
+	Address    string
+	DiffEnd    time.Time
+	ApplyStart time.Time
+	Gap        time.Duration
+}
+
+// writeQueueing writes queueing.json for the resources that were both
+// diffed and applied, longest gap first. Sorted archives leave the times
+// out, and list the resources by address instead. The lock must be held.
+func (d *debugInfo) writeQueueing() error {
+	// the first apply of each resource, and the diffs that ended before it
+	applies := make(map[string]DebugTiming)
+	for _, t := range d.timings {
+		if t.Operation != "apply" {
+			continue
+		}
+		if a, ok := applies[t.Address]; !ok || t.Start.Before(a.Start) {
+			applies[t.Address] = t
+		}
+	}
+
+	diffEnds := make(map[string]time.Time)
+	for _, t := range d.timings {
+		a, ok := applies[t.Address]
+		if t.Operation != "diff" || !ok {
+			continue
+		}
+		end := t.Start.Add(t.Duration)
+		if end.After(a.Start) {
+			continue
+		}
+		if e, ok := diffEnds[t.Address]; !ok || end.After(e) {
+			diffEnds[t.Address] = end
+		}
+	}
+
+	if len(diffEnds) == 0 {
+		return nil
+	}
+
+	result := make([]debugQueueing, 0, len(diffEnds))
+	for addr, end := range diffEnds {
+		start := applies[addr].Start
+		result = append(result, debugQueueing{
+			Address:    addr,
+			DiffEnd:    end,
+			ApplyStart: start,
+			Gap:        start.Sub(end),
+		})
+	}
+	if d.sorted {
+		for i := range result {
+			result[i].DiffEnd, result[i].ApplyStart, result[i].Gap = time.Time{}, time.Time{}, 0
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Gap != result[j].Gap {
+			return result[i].Gap > result[j].Gap
+		}
+		return result[i].Address < result[j].Address
+	})
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("queueing.json", js)
+}
@@ -0,0 +1,221 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// debugTraceEvent is an event of the Chrome Trace Event Format, as loaded by
+// chrome://tracing. Only complete events ("X"), with a start and duration,
+// and the metadata events ("M") naming the process and threads are written.
+type debugTraceEvent struct {
+	Name     string            `json:"name"`
+	Category string            `json:"cat,omitempty"`
+	Phase    string            `json:"ph"`
+	Time     int64             `json:"ts"`
+	Duration int64             `json:"dur,omitempty"`
+	PID      int               `json:"pid"`
+	TID      int               `json:"tid"`
+	Args     map[string]string `json:"args,omitempty"`
+}
+
+// debugTrace is a trace in the JSON object format of the Chrome Trace Event
+// Format.
+type debugTrace struct {
+	TraceEvents     []debugTraceEvent `json:"traceEvents"`
+	DisplayTimeUnit string            `json:"displayTimeUnit"`
+}
+
+// debugTracePhaseTrack is the thread of the trace showing the phases. The
+// worker tracks follow it.
+const debugTracePhaseTrack = 0
+
+// WriteChromeTrace writes the operation timings recorded in the archive in
+// the Chrome Trace Event Format, to be loaded into chrome://tracing. The
+// phases are shown on a track of their own, and the operations on each
+// instance on the tracks of the workers that evaluated them, if the worker
+// assignments were recorded with DebugConfig.WorkerAssignments, along with
+// the vertices each worker evaluated. Otherwise the operations are laid out
+// on as few tracks as possible without overlapping. Times are relative to
+// the start of the first timing. It returns false if the archive has no
+// timings.
+func (r *DebugArchiveReader) WriteChromeTrace(w io.Writer) (bool, error) {
+	timings, err := r.Timings()
+	if err != nil || timings == nil {
+		return false, err
+	}
+	assignments, err := r.WorkerAssignments()
+	if err != nil {
+		return false, err
+	}
+
+	var start time.Time
+	for _, t := range timings {
+		if start.IsZero() || t.Start.Before(start) {
+			start = t.Start
+		}
+	}
+	for _, a := range assignments {
+		if a.Start.Before(start) {
+			start = a.Start
+		}
+	}
+	ts := func(t time.Time) int64 {
+		return int64(t.Sub(start) / time.Microsecond)
+	}
+
+	trace := &debugTrace{DisplayTimeUnit: "ms"}
+	trace.TraceEvents = append(trace.TraceEvents,
+		debugTraceEvent{
+			Name:  "process_name",
+			Phase: "M",
+			Args:  map[string]string{"name": "terraform"},
+		},
+		debugTraceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			TID:   debugTracePhaseTrack,
+			Args:  map[string]string{"name": "phases"},
+		},
+	)
+
+	// the workers are numbered from zero, and follow the phase track
+	workers := 0
+	for _, a := range assignments {
+		if a.Worker >= workers {
+			workers = a.Worker + 1
+		}
+		trace.TraceEvents = append(trace.TraceEvents, debugTraceEvent{
+			Name:     a.Vertex,
+			Category: "vertex",
+			Phase:    "X",
+			Time:     ts(a.Start),
+			Duration: debugTraceDuration(a.Duration),
+			TID:      a.Worker + 1,
+			Args:     map[string]string{"operation": a.Operation},
+		})
+	}
+
+	// operations that can't be matched to a worker are packed onto lanes
+	// after the workers
+	var lanes []time.Time
+	for _, t := range timings {
+		e := debugTraceEvent{
+			Name:     t.Address,
+			Category: t.Operation,
+			Phase:    "X",
+			Time:     ts(t.Start),
+			Duration: debugTraceDuration(t.Duration),
+			Args:     map[string]string{"phase": t.Phase},
+		}
+
+		switch {
+		case t.Operation == "phase":
+			e.TID = debugTracePhaseTrack
+		default:
+			e.Name = t.Operation + " " + t.Address
+			e.Args["address"] = t.Address
+			if worker, ok := debugTraceWorker(t, assignments); ok {
+				e.TID = worker + 1
+				break
+			}
+
+			lane := len(lanes)
+			for i, free := range lanes {
+				if !t.Start.Before(free) {
+					lane = i
+					break
+				}
+			}
+			if lane == len(lanes) {
+				lanes = append(lanes, time.Time{})
+			}
+			lanes[lane] = t.Start.Add(t.Duration)
+			e.TID = workers + lane + 1
+		}
+
+		trace.TraceEvents = append(trace.TraceEvents, e)
+	}
+
+	for worker := 0; worker < workers; worker++ {
+		trace.TraceEvents = append(trace.TraceEvents, debugTraceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			TID:   worker + 1,
+			Args:  map[string]string{"name": fmt.Sprintf("worker %d", worker)},
+		})
+	}
+	for lane := range lanes {
+		trace.TraceEvents = append(trace.TraceEvents, debugTraceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			TID:   workers + lane + 1,
+			Args:  map[string]string{"name": fmt.Sprintf("operations %d", lane)},
+		})
+	}
+
+	// sorted for stable output, with the metadata first
+	sort.SliceStable(trace.TraceEvents, func(i, j int) bool {
+		a, b := trace.TraceEvents[i], trace.TraceEvents[j]
+		if (a.Phase == "M") != (b.Phase == "M") {
+			return a.Phase == "M"
+		}
+		if a.TID != b.TID {
+			return a.TID < b.TID
+		}
+		return a.Time < b.Time
+	})
+
+	js, err := json.MarshalIndent(trace, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	_, err = w.Write(append(js, '\n'))
+	return true, err
+}
+
+// debugTraceDuration returns d in microseconds, rounded up so that short
+// operations are still visible.
+func debugTraceDuration(d time.Duration) int64 {
+	us := int64(d / time.Microsecond)
+	if us == 0 && d > 0 {
+		us = 1
+	}
+	return us
+}
+
+// debugTraceWorker returns the worker that evaluated the vertex of the
+// instance the timing t is for, while t was running.
+func debugTraceWorker(t DebugTiming, assignments []DebugWorkerAssignment) (int, bool) {
+	// vertices are named for the resource, without the index of the
+	// instance, and may have a suffix such as " (destroy)"
+	names := map[string]bool{t.Address: true}
+	if i := strings.LastIndex(t.Address, "."); i > 0 {
+		if _, err := strconv.Atoi(t.Address[i+1:]); err == nil {
+			names[t.Address[:i]] = true
+			names[fmt.Sprintf("%s[%s]", t.Address[:i], t.Address[i+1:])] = true
+		}
+	}
+
+	end := t.Start.Add(t.Duration)
+	for _, a := range assignments {
+		name := a.Vertex
+		if i := strings.Index(name, " ("); i > 0 {
+			name = name[:i]
+		}
+		if !names[name] {
+			continue
+		}
+		if t.Start.Before(a.Start) || end.After(a.Start.Add(a.Duration)) {
+			continue
+		}
+		return a.Worker, true
+	}
+
+	return 0, false
+}
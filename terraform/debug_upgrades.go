@@ -0,0 +1,125 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// debugStateUpgrade is an entry in the upgrades directory of the archive: a
+// transformation of the state by Terraform or a provider, with summaries of
+// the state before and after it, rather than the full states.
+type debugStateUpgrade struct {
+	// Kind is "format" for an upgrade of the state file format when it's
+	// read, or "provider" for a provider migrating the state of an instance
+	// to its current schema version.
+	Kind string
+
+	// Upgraders are the upgrades that ran, in order, i.e. "v1-to-v2".
+	Upgraders []string
+
+	// Instance and Type identify the instance of a provider upgrade.
+	Instance string `json:",omitempty"`
+	Type     string `json:",omitempty"`
+
+	Before debugUpgradeSummary
+	After  debugUpgradeSummary
+}
+
+// debugUpgradeSummary summarizes a state for debugStateUpgrade: the format
+// version, serial and size of a whole state, or the schema version and
+// number of attributes of an instance.
+type debugUpgradeSummary struct {
+	FormatVersion int    `json:",omitempty"`
+	Serial        int64  `json:",omitempty"`
+	Modules       int    `json:",omitempty"`
+	Resources     int    `json:",omitempty"`
+	SchemaVersion string `json:",omitempty"`
+	Attributes    int    `json:",omitempty"`
+}
+
+// debugStateUpgradeSummary summarizes s.
+func debugStateUpgradeSummary(s *State) debugUpgradeSummary {
+	result := debugUpgradeSummary{
+		FormatVersion: s.Version,
+		Serial:        s.Serial,
+		Modules:       len(s.Modules),
+	}
+	for _, m := range s.Modules {
+		result.Resources += len(m.Resources)
+	}
+	return result
+}
+
+// debugStateV1UpgradeSummary summarizes a state in the version 1 format.
+func debugStateV1UpgradeSummary(s *stateV1) debugUpgradeSummary {
+	result := debugUpgradeSummary{
+		FormatVersion: s.Version,
+		Serial:        s.Serial,
+		Modules:       len(s.Modules),
+	}
+	for _, m := range s.Modules {
+		result.Resources += len(m.Resources)
+	}
+	return result
+}
+
+// recordFormatUpgrade writes upgrades/format-N.json for a state whose file
+// format was upgraded by the upgraders as it was read, numbered in the order
+// the states were read.
+func (d *debugInfo) recordFormatUpgrade(upgraders []string, before debugUpgradeSummary, after *State) {
+	if d == nil {
+		return
+	}
+
+	u := &debugStateUpgrade{
+		Kind:      "format",
+		Upgraders: upgraders,
+		Before:    before,
+		After:     debugStateUpgradeSummary(after),
+	}
+	js, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		log.Printf("[WARN] not recording the state upgrade: %s", err)
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.closed {
+		return
+	}
+	d.formatUpgrades++
+	if err := d.writeFile(fmt.Sprintf("upgrades/format-%d.json", d.formatUpgrades), js); err != nil {
+		log.Printf("[WARN] not recording the state upgrade: %s", err)
+	}
+}
+
+// writeProviderUpgrade writes upgrades/ID.json for the instance id, whose
+// state the provider migrated from schema version v.before to v.after,
+// once for each instance. The lock must be held.
+func (d *debugInfo) writeProviderUpgrade(id string, v *debugSchemaVersion, attrs int) {
+	if v.upgraded || v.before == v.after || d.closed {
+		return
+	}
+	v.upgraded = true
+
+	u := &debugStateUpgrade{
+		Kind:      "provider",
+		Upgraders: []string{fmt.Sprintf("schema-%s-to-%s", v.before, v.after)},
+		Instance:  id,
+		Type:      v.typ,
+		Before:    debugUpgradeSummary{SchemaVersion: v.before, Attributes: v.attrs},
+		After:     debugUpgradeSummary{SchemaVersion: v.after, Attributes: attrs},
+	}
+	js, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		log.Printf("[WARN] not recording the state upgrade of %s: %s", id, err)
+		return
+	}
+
+	if err := d.writeResourceFile("upgrades/"+id+".json", js); err != nil {
+		log.Printf("[WARN] not recording the state upgrade of %s: %s", id, err)
+	}
+}
@@ -0,0 +1,72 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// recordRefreshBefore records the ID of an instance before it's refreshed,
+// so its disappearance can be detected by recordRefreshAfter.
+func (d *debugInfo) recordRefreshBefore(ii *InstanceInfo, is *InstanceState) {
+	if d == nil || ii == nil || is == nil || is.ID == "" {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.refreshing == nil {
+		d.refreshing = make(map[string]string)
+	}
+	d.refreshing[ii.HumanId()] = is.ID
+}
+
+// recordRefreshAfter records an instance that had an ID before it was
+// refreshed, but none afterwards, meaning the provider no longer found it:
+// it was deleted outside of Terraform, and will be recreated.
+func (d *debugInfo) recordRefreshAfter(ii *InstanceInfo, is *InstanceState) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	id := ii.HumanId()
+	prior, ok := d.refreshing[id]
+	if !ok {
+		return
+	}
+	delete(d.refreshing, id)
+
+	if is != nil && is.ID != "" {
+		return
+	}
+
+	if d.deletedUpstream == nil {
+		d.deletedUpstream = make(map[string]string)
+	}
+	d.deletedUpstream[id] = prior
+}
+
+// writeDeletedUpstream writes deleted-upstream.txt, the sorted addresses of
+// the instances that were no longer found when they were refreshed, each
+// followed by the ID it had in the state, if there were any. The lock must
+// be held.
+func (d *debugInfo) writeDeletedUpstream() error {
+	if len(d.deletedUpstream) == 0 {
+		return nil
+	}
+
+	addrs := make([]string, 0, len(d.deletedUpstream))
+	for addr := range d.deletedUpstream {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var buf bytes.Buffer
+	for _, addr := range addrs {
+		fmt.Fprintf(&buf, "%s %s\n", addr, d.deletedUpstream[addr])
+	}
+
+	return d.writeFile("deleted-upstream.txt", buf.Bytes())
+}
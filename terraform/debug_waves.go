@@ -0,0 +1,267 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// The values of DebugConfig.Waves. With debugWavesTag, the entries written
+// for each resource while it's applied are listed in wave-entries.json with
+// the wave it's in. With debugWavesSplit, they're also written to the
+// waves/N directory of their wave rather than alongside everything else.
+const (
+	debugWavesTag   = "tag"
+	debugWavesSplit = "split"
+)
+
+// debugWaveEntriesFile is the name of the index of the entries written in a
+// wave. It's written when the archive is closed, and joined with the entries
+// on their step and name by NewDebugArchiveReader.
+const debugWaveEntriesFile = "wave-entries.json"
+
+// debugWaveKey identifies a resource node of the apply graph by the address
+// the hooks use for it. A resource that is replaced has both a destroy node
+// and a create node with the same address, which can be in different waves.
+type debugWaveKey struct {
+	addr    string
+	destroy bool
+}
+
+// debugWave is an entry in waves.json: the resource nodes of the apply graph
+// that are the same number of resources deep in their chain of dependencies.
+// The resources of a wave don't depend on each other, so they can be applied
+// concurrently once those of the earlier waves they depend on are applied.
+type debugWave struct {
+	Wave      int
+	Resources []debugWaveMember
+}
+
+type debugWaveMember struct {
+	Name    string
+	Address string
+	Destroy bool `json:",omitempty"`
+}
+
+// debugWaveEntry is an entry in wave-entries.json: an entry of the archive,
+// by the step it was written at and its name without the prefix, that was
+// written for a resource while it was applied in the given wave.
+type debugWaveEntry struct {
+	Step int
+	Name string
+	Wave int
+}
+
+// waves returns the wave of each node: the number of resource nodes on the
+// longest chain of dependencies from it, including itself. Nodes that aren't
+// resources pass on the wave of their dependencies, without adding to it.
+// Like criticalPath, any cycle is broken where it's found.
+func (g *debugPathGraph) waves() []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(g.nodes))
+	wave := make([]int, len(g.nodes))
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		for _, dep := range g.deps[i] {
+			switch state[dep] {
+			case visiting:
+				continue
+			case unvisited:
+				visit(dep)
+			}
+			if wave[dep] > wave[i] {
+				wave[i] = wave[dep]
+			}
+		}
+		if g.nodes[i].addr != "" {
+			wave[i]++
+		}
+		state[i] = visited
+	}
+
+	for i := range g.nodes {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+	return wave
+}
+
+// resourceWaves returns the wave of each resource node of the graph.
+func (g *debugPathGraph) resourceWaves() map[debugWaveKey]int {
+	result := make(map[debugWaveKey]int)
+	for i, w := range g.waves() {
+		n := g.nodes[i]
+		if n.addr != "" {
+			result[debugWaveKey{addr: n.addr, destroy: n.destroy}] = w
+		}
+	}
+	return result
+}
+
+// recordWaveStart records the wave of the instance about to be applied, so the
+// entries written for it until recordWaveEnd are written in that wave.
+func (d *debugInfo) recordWaveStart(ii *InstanceInfo, id *InstanceDiff) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.waves == "" || d.applyGraph == nil {
+		return
+	}
+	if d.resourceWaves == nil {
+		d.resourceWaves = d.applyGraph.resourceWaves()
+	}
+
+	addr := ii.HumanId()
+	wave := d.resourceWaves[debugWaveKey{addr: addr, destroy: id.GetDestroy()}]
+	if wave == 0 {
+		return
+	}
+	if d.applyWaves == nil {
+		d.applyWaves = make(map[string]int)
+	}
+	d.applyWaves[addr] = wave
+}
+
+// recordWaveEnd records that the instance is no longer being applied.
+func (d *debugInfo) recordWaveEnd(ii *InstanceInfo) {
+	if d == nil || ii == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	delete(d.applyWaves, ii.HumanId())
+}
+
+// writeInstanceFile writes a per-resource file for the instance, recording
+// the wave it's being applied in, if any, and in the directory of the wave if
+// the archive is split by wave. The lock must be held.
+func (d *debugInfo) writeInstanceFile(ii *InstanceInfo, name string, data []byte) error {
+	wave := 0
+	if ii != nil {
+		wave = d.applyWaves[ii.HumanId()]
+	}
+	if wave == 0 {
+		return d.writeResourceFile(name, data)
+	}
+
+	if d.waves == debugWavesSplit {
+		name = fmt.Sprintf("waves/%d/%s", wave, name)
+	}
+	d.entryWave = wave
+	defer func() { d.entryWave = 0 }()
+	return d.writeResourceFile(name, data)
+}
+
+// recordEntryWave records the wave of the file about to be written at the
+// current step, either as an entry or in a bundle, if it's written for a
+// resource being applied. Bundles themselves aren't recorded, since they're
+// never read as files. The lock must be held.
+func (d *debugInfo) recordEntryWave(name string) {
+	if d.entryWave == 0 || name == debugBundleFile {
+		return
+	}
+	d.waveEntries = append(d.waveEntries, debugWaveEntry{
+		Step: d.step,
+		Name: name,
+		Wave: d.entryWave,
+	})
+}
+
+// writeWaveEntries writes wave-entries.json, the entries written in each
+// wave, if any were. The lock must be held.
+func (d *debugInfo) writeWaveEntries() error {
+	if len(d.waveEntries) == 0 {
+		return nil
+	}
+
+	js, err := json.MarshalIndent(d.waveEntries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile(debugWaveEntriesFile, js)
+}
+
+// joinDebugWaveEntries sets the wave of the files listed in the
+// wave-entries.json of the archive, if there is one.
+func joinDebugWaveEntries(files []*DebugArchiveFile) error {
+	var index *DebugArchiveFile
+	for _, f := range files {
+		if f.Name == debugWaveEntriesFile {
+			index = f
+		}
+	}
+	if index == nil {
+		return nil
+	}
+
+	var entries []debugWaveEntry
+	if err := json.Unmarshal(index.Data, &entries); err != nil {
+		return fmt.Errorf("invalid %s: %s", debugWaveEntriesFile, err)
+	}
+	byStep := make(map[int]debugWaveEntry, len(entries))
+	for _, e := range entries {
+		byStep[e.Step] = e
+	}
+
+	for _, f := range files {
+		if e, ok := byStep[f.Step]; ok && f.Step >= 0 && e.Name == f.Name {
+			f.Wave = e.Wave
+		}
+	}
+	return nil
+}
+
+// writeWaves writes waves.json, the resources of each wave of the last apply
+// graph written, if the archive is tagged by wave. The lock must be held.
+func (d *debugInfo) writeWaves() error {
+	if d.waves == "" || d.applyGraph == nil {
+		return nil
+	}
+
+	g := d.applyGraph
+	byWave := make(map[int]*debugWave)
+	var result []*debugWave
+	for i, w := range g.waves() {
+		n := g.nodes[i]
+		if n.addr == "" {
+			continue
+		}
+		wave, ok := byWave[w]
+		if !ok {
+			wave = &debugWave{Wave: w}
+			byWave[w] = wave
+			result = append(result, wave)
+		}
+		wave.Resources = append(wave.Resources, debugWaveMember{
+			Name:    n.name,
+			Address: n.addr,
+			Destroy: n.destroy,
+		})
+	}
+	if len(result) == 0 {
+		return nil
+	}
+
+	// the nodes are sorted by name, so only the waves need sorting
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Wave < result[j].Wave
+	})
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("waves.json", js)
+}
@@ -0,0 +1,180 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// DebugWorkerAssignment records the evaluation of a single vertex by a
+// worker, as recorded in worker-assignments.json of the debug archive.
+//
+// The graph walk runs each vertex in its own goroutine, but only as many as
+// the context's parallelism can evaluate at once. Worker is the index of the
+// parallelism slot the vertex held, which is always the lowest free slot, so
+// a slot consistently taking longer than the others shows up as a slow
+// worker.
+type DebugWorkerAssignment struct {
+	Operation string
+	Vertex    string
+	Worker    int
+	Start     time.Time
+	Duration  time.Duration
+}
+
+// debugWorkerTotals summarizes the assignments of one worker.
+type debugWorkerTotals struct {
+	Worker   int
+	Vertices int
+	Busy     time.Duration
+}
+
+// debugWorkerAssignments is the content of worker-assignments.json.
+type debugWorkerAssignments struct {
+	Workers     []debugWorkerTotals
+	Assignments []DebugWorkerAssignment
+}
+
+// debugWorkers tracks the slots in use during a walk.
+type debugWorkers struct {
+	// busy is the slots in use, and running maps each vertex being
+	// evaluated to its slot and start time.
+	busy    []bool
+	running map[string]debugWorkerStart
+
+	assignments []DebugWorkerAssignment
+}
+
+type debugWorkerStart struct {
+	worker int
+	start  time.Time
+}
+
+// recordWorkerStart assigns the lowest free worker to a vertex whose
+// evaluation is starting. This is called with the walk's semaphore held.
+func (d *debugInfo) recordWorkerStart(op walkOperation, v dag.Vertex) {
+	if d == nil || !d.recordWorkers {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.workers == nil {
+		d.workers = &debugWorkers{running: make(map[string]debugWorkerStart)}
+	}
+	w := d.workers
+
+	worker := len(w.busy)
+	for i, busy := range w.busy {
+		if !busy {
+			worker = i
+			break
+		}
+	}
+	if worker == len(w.busy) {
+		w.busy = append(w.busy, false)
+	}
+	w.busy[worker] = true
+
+	w.running[op.String()+" "+dag.VertexName(v)] = debugWorkerStart{
+		worker: worker,
+		start:  time.Now(),
+	}
+}
+
+// recordWorkerEnd records the completed evaluation of a vertex, and frees
+// its worker. This is called before the walk's semaphore is released.
+func (d *debugInfo) recordWorkerEnd(op walkOperation, v dag.Vertex) {
+	if d == nil || !d.recordWorkers {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.workers == nil {
+		return
+	}
+	w := d.workers
+
+	name := dag.VertexName(v)
+	s, ok := w.running[op.String()+" "+name]
+	if !ok {
+		return
+	}
+	delete(w.running, op.String()+" "+name)
+	w.busy[s.worker] = false
+
+	w.assignments = append(w.assignments, DebugWorkerAssignment{
+		Operation: op.String(),
+		Vertex:    name,
+		Worker:    s.worker,
+		Start:     s.start,
+		Duration:  time.Since(s.start),
+	})
+}
+
+// writeWorkerAssignments writes worker-assignments.json, if any were
+// recorded. Sorted archives leave the times out, and list the assignments by
+// operation and vertex instead. The workers vertices are assigned to still
+// depend on the order they were scheduled in. The lock must be held.
+func (d *debugInfo) writeWorkerAssignments() error {
+	if d.workers == nil || len(d.workers.assignments) == 0 {
+		return nil
+	}
+
+	result := debugWorkerAssignments{
+		Workers:     make([]debugWorkerTotals, len(d.workers.busy)),
+		Assignments: make([]DebugWorkerAssignment, len(d.workers.assignments)),
+	}
+	for i := range result.Workers {
+		result.Workers[i].Worker = i
+	}
+	for i, a := range d.workers.assignments {
+		result.Assignments[i] = a
+		result.Workers[a.Worker].Vertices++
+		result.Workers[a.Worker].Busy += a.Duration
+	}
+	sort.SliceStable(result.Assignments, func(i, j int) bool {
+		return result.Assignments[i].Start.Before(result.Assignments[j].Start)
+	})
+	if d.sorted {
+		for i := range result.Workers {
+			result.Workers[i].Busy = 0
+		}
+		for i := range result.Assignments {
+			result.Assignments[i].Start, result.Assignments[i].Duration = time.Time{}, 0
+		}
+		sort.SliceStable(result.Assignments, func(i, j int) bool {
+			a, b := result.Assignments[i], result.Assignments[j]
+			if a.Operation != b.Operation {
+				return a.Operation < b.Operation
+			}
+			return a.Vertex < b.Vertex
+		})
+	}
+
+	js, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return d.writeFile("worker-assignments.json", js)
+}
+
+// WorkerAssignments returns the worker assignments recorded in the archive,
+// or nil if none were recorded.
+func (r *DebugArchiveReader) WorkerAssignments() ([]DebugWorkerAssignment, error) {
+	f := r.File("worker-assignments.json")
+	if f == nil {
+		return nil, nil
+	}
+
+	var result debugWorkerAssignments
+	if err := json.Unmarshal(f.Data, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Assignments, nil
+}
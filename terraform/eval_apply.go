@@ -54,7 +54,9 @@ func (n *EvalApply) Eval(ctx EvalContext) (interface{}, error) {
 
 	// With the completed diff, apply!
 	log.Printf("[DEBUG] apply: %s: executing Apply", n.Info.Id)
+	rpc := dbug.newApplyRPC(n.Info, state, diff)
 	state, err := provider.Apply(n.Info, state, diff)
+	dbug.writeApplyRPC(rpc, state, err)
 	if state == nil {
 		state = new(InstanceState)
 	}
@@ -306,6 +308,7 @@ func (n *EvalApplyProvisioners) apply(ctx EvalContext, provs []*config.Provision
 			}
 		}
 		state.Ephemeral.ConnInfo = overlay
+		dbug.writeConnInfo(n.Info, prov.Type, overlay)
 
 		{
 			// Call pre hook
@@ -153,6 +153,7 @@ func (ctx *BuiltinEvalContext) ConfigureProvider(
 	if err := ctx.SetProviderConfig(n, cfg); err != nil {
 		return nil
 	}
+	dbug.recordProviderConfig(ctx.Path(), n, cfg)
 
 	return p.Configure(cfg)
 }
@@ -302,6 +303,7 @@ func (ctx *BuiltinEvalContext) Interpolate(
 
 		// Do the interpolation
 		if err := cfg.Interpolate(vs); err != nil {
+			dbug.recordEvalError(ctx.Path(), r, err)
 			return nil, err
 		}
 	}
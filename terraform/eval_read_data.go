@@ -123,6 +123,7 @@ func (n *EvalReadDataApply) Eval(ctx EvalContext) (interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%s: %s", n.Info.Id, err)
 	}
+	dbug.recordDeferredProcessed(ctx.Path(), n.Info.Id)
 
 	err = ctx.Hook(func(h Hook) (HookAction, error) {
 		return h.PostRefresh(n.Info, state)
@@ -31,6 +31,10 @@ type Graph struct {
 	// to indicate what topmost builder was, and if this graph is a shadow or
 	// not.
 	debugName string
+
+	// provenance is the name of the transform that added each vertex,
+	// which is only recorded when debugging
+	provenance map[dag.Vertex]string
 }
 
 func (g *Graph) DirectedGraph() dag.Grapher {
@@ -72,6 +76,8 @@ func (g *Graph) walk(walker GraphWalker) error {
 	walkFn = func(v dag.Vertex) (rerr error) {
 		log.Printf("[DEBUG] vertex '%s.%s': walking", path, dag.VertexName(v))
 		g.DebugVisitInfo(v, g.debugName)
+		dbug.recordVertexReady(g, v)
+		defer dbug.recordVertexDone(v)
 
 		// If we have a panic wrap GraphWalker and a panic occurs, recover
 		// and call that. We ensure the return value is an error, however,
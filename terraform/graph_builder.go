@@ -1,9 +1,7 @@
 package terraform
 
 import (
-	"fmt"
 	"log"
-	"strings"
 )
 
 // GraphBuilder is an interface that can be implemented and used with
@@ -28,11 +26,11 @@ type BasicGraphBuilder struct {
 func (b *BasicGraphBuilder) Build(path []string) (*Graph, error) {
 	g := &Graph{Path: path}
 
-	debugName := "graph.json"
+	graphName := "graph"
 	if b.Name != "" {
-		debugName = b.Name + "-" + debugName
+		graphName = b.Name + "-" + graphName
 	}
-	debugBuf := dbug.NewFileWriter(debugName)
+	debugBuf := dbug.NewFileWriter(graphName + ".json")
 	g.SetDebugWriter(debugBuf)
 	defer debugBuf.Close()
 
@@ -41,14 +39,8 @@ func (b *BasicGraphBuilder) Build(path []string) (*Graph, error) {
 			continue
 		}
 
-		stepName := fmt.Sprintf("%T", step)
-		dot := strings.LastIndex(stepName, ".")
-		if dot >= 0 {
-			stepName = stepName[dot+1:]
-		}
-
-		debugOp := g.DebugOperation(stepName, "")
-		err := step.Transform(g)
+		debugOp := g.DebugOperation(graphTransformerName(step), "")
+		err := g.transform(step)
 
 		errMsg := ""
 		if err != nil {
@@ -65,6 +57,10 @@ func (b *BasicGraphBuilder) Build(path []string) (*Graph, error) {
 		}
 	}
 
+	// Record the completed graph. This is done before validation so that
+	// invalid graphs can be inspected.
+	dbug.WriteGraph(graphName, g)
+
 	// Validate the graph structure
 	if b.Validate {
 		if err := g.Validate(); err != nil {
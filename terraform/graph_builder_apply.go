@@ -115,7 +115,7 @@ func (b *ApplyGraphBuilder) Steps() []GraphTransformer {
 		&ModuleVariableTransformer{Module: b.Module},
 
 		// Connect references so ordering is correct
-		&ReferenceTransformer{},
+		&ReferenceTransformer{RecordDependsOn: true},
 
 		// Add the node to fix the state count boundaries
 		&CountBoundaryTransformer{},
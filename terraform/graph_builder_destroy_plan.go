@@ -57,7 +57,7 @@ func (b *DestroyPlanGraphBuilder) Steps() []GraphTransformer {
 
 		// Target. Note we don't set "Destroy: true" here since we already
 		// created proper destroy ordering.
-		&TargetsTransformer{Targets: b.Targets},
+		&TargetsTransformer{Targets: b.Targets, RecordTargets: true},
 
 		// Single root
 		&RootTransformer{},
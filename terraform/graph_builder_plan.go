@@ -40,6 +40,11 @@ type PlanGraphBuilder struct {
 	// Validate will do structural validation of the graph.
 	Validate bool
 
+	// RecordTargets records the effect of Targets in the debug archive.
+	// This is only set for planning, not for the graphs built from this
+	// one, like input and validate.
+	RecordTargets bool
+
 	// CustomConcrete can be set to customize the node types created
 	// for various parts of the plan. This is useful in order to customize
 	// the plan behavior.
@@ -111,10 +116,10 @@ func (b *PlanGraphBuilder) Steps() []GraphTransformer {
 
 		// Connect so that the references are ready for targeting. We'll
 		// have to connect again later for providers and so on.
-		&ReferenceTransformer{},
+		&ReferenceTransformer{RecordDependsOn: true},
 
 		// Target
-		&TargetsTransformer{Targets: b.Targets},
+		&TargetsTransformer{Targets: b.Targets, RecordTargets: b.RecordTargets},
 
 		// Close opened plugin connections
 		&CloseProviderTransformer{},
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/terraform/dag"
@@ -103,8 +104,15 @@ func (w *ContextGraphWalker) EnterEvalTree(v dag.Vertex, n EvalNode) EvalNode {
 	log.Printf("[TRACE] [%s] Entering eval tree: %s",
 		w.Operation, dag.VertexName(v))
 
-	// Acquire a lock on the semaphore
-	w.Context.parallelSem.Acquire()
+	// Acquire a lock on the semaphore, recording the resources that had to
+	// wait for it
+	if !w.Context.parallelSem.TryAcquire() {
+		start := time.Now()
+		w.Context.parallelSem.Acquire()
+		dbug.recordSerialized(w.Operation, v, cap(w.Context.parallelSem), time.Since(start))
+	}
+	dbug.recordWorkerStart(w.Operation, v)
+	dbug.recordVertexStart(v)
 
 	// We want to filter the evaluation tree to only include operations
 	// that belong in this operation.
@@ -117,6 +125,7 @@ func (w *ContextGraphWalker) ExitEvalTree(
 		w.Operation, dag.VertexName(v))
 
 	// Release the semaphore
+	dbug.recordWorkerEnd(w.Operation, v)
 	w.Context.parallelSem.Release()
 
 	if err == nil {
@@ -133,6 +142,7 @@ func (w *ContextGraphWalker) ExitEvalTree(
 	if !ok {
 		return err
 	}
+	dbug.recordDiagnostics(w.Operation, dag.VertexName(v), verr)
 
 	for _, msg := range verr.Warnings {
 		w.ValidationWarnings = append(
@@ -143,6 +143,7 @@ func (n *NodeRefreshableDataResourceInstance) EvalTree() EvalNode {
 			&EvalIf{
 				If: func(ctx EvalContext) (bool, error) {
 					if config.ComputedKeys != nil && len(config.ComputedKeys) > 0 {
+						dbug.recordDeferral(ctx.Path(), stateId, debugDeferralComputed(config))
 						return true, EvalEarlyExitError{}
 					}
 
@@ -150,6 +151,8 @@ func (n *NodeRefreshableDataResourceInstance) EvalTree() EvalNode {
 					// data source, assume the intention is to prevent
 					// refreshing ahead of that dependency.
 					if len(n.Config.DependsOn) > 0 {
+						dbug.recordDeferral(ctx.Path(), stateId,
+							"depends_on prevents reading ahead of its dependencies")
 						return true, EvalEarlyExitError{}
 					}
 
@@ -22,6 +22,7 @@ func (n *NodePlannableResource) DynamicExpand(ctx EvalContext) (*Graph, error) {
 	if err != nil {
 		return nil, err
 	}
+	dbug.recordKeyChurn(n.ResourceAddr(), count, state)
 
 	// The concrete resource factory we'll use
 	concreteResource := func(a *NodeAbstractResource) dag.Vertex {
@@ -91,6 +91,13 @@ func (n *NodePlannableResourceInstance) evalTreeDataResource(
 						return true, EvalEarlyExitError{}
 					}
 
+					// Otherwise the data source is read during apply
+					if computed {
+						dbug.recordDeferral(ctx.Path(), stateId, debugDeferralComputed(config))
+					} else {
+						dbug.recordDeferral(ctx.Path(), stateId, "not read during refresh")
+					}
+
 					return true, nil
 				},
 				Then: EvalNoop{},
@@ -1852,6 +1852,8 @@ func ReadState(src io.Reader) (*State, error) {
 		// increment the Serial whenever we upgrade state
 		v3State.Serial++
 		result = v3State
+		dbug.recordFormatUpgrade([]string{"v1-to-v2", "v2-to-v3"},
+			debugStateV1UpgradeSummary(v1State), v3State)
 	case 2:
 		v2State, err := ReadStateV2(jsonBytes)
 		if err != nil {
@@ -1864,6 +1866,8 @@ func ReadState(src io.Reader) (*State, error) {
 
 		v3State.Serial++
 		result = v3State
+		dbug.recordFormatUpgrade([]string{"v2-to-v3"},
+			debugStateUpgradeSummary(v2State), v3State)
 	case 3:
 		v3State, err := ReadStateV3(jsonBytes)
 		if err != nil {
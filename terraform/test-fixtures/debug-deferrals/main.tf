@@ -0,0 +1,11 @@
+resource "aws_instance" "foo" {
+  num = "2"
+}
+
+data "aws_vpc" "bar" {
+  foo = "${aws_instance.foo.id}"
+}
+
+data "aws_vpc" "baz" {
+  depends_on = ["aws_instance.foo"]
+}
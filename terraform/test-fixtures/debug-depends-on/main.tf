@@ -0,0 +1,10 @@
+resource "aws_instance" "web" {}
+
+resource "aws_instance" "app" {
+    foo = "${aws_instance.web.id}"
+}
+
+resource "aws_instance" "db" {
+    depends_on = ["aws_instance.web"]
+    foo = "${aws_instance.app.id}"
+}
@@ -0,0 +1,5 @@
+resource "aws_instance" "foo" {
+  num = "2"
+
+  foo = "${lookup(map("a", "b"), "c")}"
+}
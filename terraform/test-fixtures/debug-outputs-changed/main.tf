@@ -0,0 +1,16 @@
+output "unchanged" {
+  value = "a"
+}
+
+output "changed" {
+  value = "new"
+}
+
+output "added" {
+  value = ["y"]
+}
+
+output "secret" {
+  value     = "s2"
+  sensitive = true
+}
@@ -0,0 +1,14 @@
+variable "region" {
+    default = "us-east-1"
+}
+
+provider "aws" {
+    region     = "${var.region}"
+    secret_key = "hunter2"
+
+    assume_role {
+        role_arn = "arn:aws:iam::123456789012:role/admin"
+    }
+}
+
+resource "aws_instance" "foo" {}
@@ -0,0 +1,12 @@
+variable "secret" {}
+
+variable "name" {}
+
+output "dsn" {
+  value = "${var.name}:${var.secret}"
+}
+
+output "masked" {
+  value     = "${var.secret}"
+  sensitive = true
+}
@@ -0,0 +1,24 @@
+resource "aws_instance" "db" {}
+
+resource "aws_instance" "web" {
+  user_data = "${aws_instance.db.password}"
+}
+
+module "child" {
+  source = "./child"
+  secret = "${aws_instance.db.password}"
+  name   = "${aws_instance.db.id}"
+}
+
+output "dsn" {
+  value = "${module.child.dsn}"
+}
+
+output "masked" {
+  value     = "${module.child.masked}"
+  sensitive = true
+}
+
+output "id" {
+  value = "${aws_instance.db.id}"
+}
@@ -0,0 +1,4 @@
+resource "aws_instance" "foo" {
+  count = 3
+  num   = "2"
+}
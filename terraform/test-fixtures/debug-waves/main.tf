@@ -0,0 +1,13 @@
+resource "aws_instance" "a" {}
+
+resource "aws_instance" "b" {
+  foo = "${aws_instance.a.id}"
+}
+
+resource "aws_instance" "c" {
+  foo = "${aws_instance.a.id}"
+}
+
+resource "aws_instance" "d" {
+  foo = "${aws_instance.b.id}"
+}
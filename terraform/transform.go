@@ -37,7 +37,7 @@ type graphTransformerMulti struct {
 
 func (t *graphTransformerMulti) Transform(g *Graph) error {
 	for _, t := range t.Transforms {
-		if err := t.Transform(g); err != nil {
+		if err := g.transform(t); err != nil {
 			return err
 		}
 	}
@@ -72,6 +72,7 @@ func (t *OrphanResourceTransformer) transform(g *Graph, ms *ModuleState) error {
 
 		// Add it to the graph
 		g.Add(node)
+		dbug.recordOrphan(addr, ms.Resources[key])
 	}
 
 	return nil
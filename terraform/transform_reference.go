@@ -50,7 +50,13 @@ type GraphNodeReferenceGlobal interface {
 
 // ReferenceTransformer is a GraphTransformer that connects all the
 // nodes that reference each other in order to form the proper ordering.
-type ReferenceTransformer struct{}
+type ReferenceTransformer struct {
+	// RecordDependsOn records how the explicit dependencies of each
+	// resource were resolved into edges in the debug archive. This is set
+	// by the graph builders, and not for the subgraphs of expanded
+	// resources.
+	RecordDependsOn bool
+}
 
 func (t *ReferenceTransformer) Transform(g *Graph) error {
 	// Build a reference map so we can efficiently look up the references
@@ -58,6 +64,7 @@ func (t *ReferenceTransformer) Transform(g *Graph) error {
 	m := NewReferenceMap(vs)
 
 	// Find the things that reference things and connect them
+	var dependsOn []*debugDependsOn
 	for _, v := range vs {
 		parents, _ := m.References(v)
 		parentsDbg := make([]string, len(parents))
@@ -71,8 +78,15 @@ func (t *ReferenceTransformer) Transform(g *Graph) error {
 		for _, parent := range parents {
 			g.Connect(dag.BasicEdge(v, parent))
 		}
+
+		if t.RecordDependsOn && dbug != nil {
+			if d := newDebugDependsOn(m, v, parents); d != nil {
+				dependsOn = append(dependsOn, d)
+			}
+		}
 	}
 
+	dbug.writeDependsOn(dependsOn)
 	return nil
 }
 
@@ -95,41 +109,49 @@ func (m *ReferenceMap) References(v dag.Vertex) ([]dag.Vertex, []string) {
 
 	var matches []dag.Vertex
 	var missing []string
-	prefix := m.prefix(v)
 	for _, ns := range rn.References() {
-		found := false
-		for _, n := range strings.Split(ns, "/") {
-			n = prefix + n
-			parents, ok := m.references[n]
-			if !ok {
-				continue
-			}
-
-			// Mark that we found a match
-			found = true
+		parents, found := m.reference(v, ns)
+		if !found {
+			missing = append(missing, ns)
+		}
+		matches = append(matches, parents...)
+	}
 
-			// Make sure this isn't a self reference, which isn't included
-			selfRef := false
-			for _, p := range parents {
-				if p == v {
-					selfRef = true
-					break
-				}
-			}
-			if selfRef {
-				continue
-			}
+	return matches, missing
+}
 
-			matches = append(matches, parents...)
-			break
+// reference returns the vertices that a single reference made by v resolves
+// to, and whether the reference was found at all. A reference only to v
+// itself is found, but resolves to nothing.
+func (m *ReferenceMap) reference(v dag.Vertex, ns string) ([]dag.Vertex, bool) {
+	found := false
+	prefix := m.prefix(v)
+	for _, n := range strings.Split(ns, "/") {
+		n = prefix + n
+		parents, ok := m.references[n]
+		if !ok {
+			continue
 		}
 
-		if !found {
-			missing = append(missing, ns)
+		// Mark that we found a match
+		found = true
+
+		// Make sure this isn't a self reference, which isn't included
+		selfRef := false
+		for _, p := range parents {
+			if p == v {
+				selfRef = true
+				break
+			}
+		}
+		if selfRef {
+			continue
 		}
+
+		return parents, true
 	}
 
-	return matches, missing
+	return nil, found
 }
 
 // ReferencedBy returns the list of vertices that reference the
@@ -29,6 +29,11 @@ type TargetsTransformer struct {
 	// Set to true when we're in a `terraform destroy` or a
 	// `terraform plan -destroy`
 	Destroy bool
+
+	// RecordTargets records the targets and the resources they select in
+	// the debug archive. This is set by the graph builders for planning,
+	// and not for the subgraphs of expanded resources.
+	RecordTargets bool
 }
 
 func (t *TargetsTransformer) Transform(g *Graph) error {
@@ -47,6 +52,10 @@ func (t *TargetsTransformer) Transform(g *Graph) error {
 			return err
 		}
 
+		if t.RecordTargets {
+			dbug.writeTargets(t.ParsedTargets, targetedNodes)
+		}
+
 		for _, v := range g.Vertices() {
 			removable := false
 			if _, ok := v.(GraphNodeResource); ok {